@@ -0,0 +1,397 @@
+// file: cache.go
+//
+// Contains the Cache, which wraps Get and GetWithPSK to store the objects read from S3 on local disk,
+// revalidating them against S3 with If-None-Match on every read and evicting the least recently used ones
+// to keep the total size of the cache under a limit.
+//
+// Requires "s3:GetObject" action allowed by IAM policy for objects inside the bucket,
+// as defined by `read-{bucketName}-bucket` policies in dp-setup
+package s3
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// cacheFileSuffix is the suffix of the files that hold cached objects, which may be followed by '.tmp' while they are written
+const cacheFileSuffix = ".s3cache"
+
+// CacheOptions represents the configuration of a Cache
+type CacheOptions struct {
+	// Dir is the directory where the cached objects are stored. It is created if it does not exist,
+	// and any cached object left in it by a previous Cache is removed.
+	Dir string
+
+	// MaxSize is the maximum total size in bytes of the cached objects. Objects larger than it are read from S3 without being cached.
+	MaxSize int64
+
+	// AllowPSKPlaintext makes GetWithPSK store the decrypted content of objects, so that it is not decrypted on every read.
+	// Otherwise, objects are stored as they are in S3 and decrypted when they are read from the cache.
+	AllowPSKPlaintext bool
+}
+
+// Cache is a read-through cache of the objects of the bucket of a Client, stored on local disk.
+// Concurrent reads of an object that is not cached, or needs revalidation, share a single request to S3.
+type Cache struct {
+	cli      *Client
+	opts     CacheOptions
+	mutex    *sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List // of *cacheEntry, most recently used first
+	size     int64
+	inflight map[string]*cacheFill
+}
+
+// cacheEntry represents an object stored in the cache
+type cacheEntry struct {
+	id        string
+	path      string
+	etag      string
+	metadata  map[string]string
+	size      int64
+	decrypted bool
+}
+
+// cacheFill represents a request to S3 to cache an object, whose result is shared by all the reads waiting for it
+type cacheFill struct {
+	done  chan struct{}
+	entry *cacheEntry
+	err   error
+}
+
+// NewCache creates a new Cache for the objects of the bucket of the provided client, with the provided options.
+// An error is returned if the options are not valid or the cache directory cannot be prepared.
+func NewCache(cli *Client, opts CacheOptions) (*Cache, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"cache_dir":   opts.Dir,
+		"max_size":    opts.MaxSize,
+	}
+
+	if opts.Dir == "" {
+		return nil, NewError(errors.New("cache directory not provided"), logData)
+	}
+	if opts.MaxSize <= 0 {
+		return nil, NewError(errors.New("cache max size must be positive"), logData)
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, NewError(fmt.Errorf("error creating cache directory: %w", err), logData)
+	}
+	if err := removeCacheFiles(opts.Dir); err != nil {
+		return nil, NewError(fmt.Errorf("error removing stale cached objects: %w", err), logData)
+	}
+
+	return &Cache{
+		cli:      cli,
+		opts:     opts,
+		mutex:    &sync.Mutex{},
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		inflight: make(map[string]*cacheFill),
+	}, nil
+}
+
+// Get returns an io.ReadCloser instance for the given path (inside the bucket of the cache client) and the content length (size in bytes),
+// like Client.Get does. The content is read from the cache if S3 reports that the cached object has not changed,
+// or from a new copy of the object stored in the cache otherwise.
+//
+// The caller is responsible for closing the returned ReadCloser.
+// For example, it may be closed in a defer statement: defer r.Close()
+func (c *Cache) Get(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+	return c.get(ctx, key, nil)
+}
+
+// GetWithPSK returns an io.ReadCloser instance for the given path (inside the bucket of the cache client) and the content length (size in bytes),
+// decrypted with the provided psk like Client.GetWithPSK does, and cached like Get does.
+// Unless AllowPSKPlaintext is set, the cache only holds the encrypted content of the object.
+//
+// The caller is responsible for closing the returned ReadCloser.
+// For example, it may be closed in a defer statement: defer r.Close()
+func (c *Cache) GetWithPSK(ctx context.Context, key string, psk []byte) (io.ReadCloser, *int64, error) {
+	return c.get(ctx, key, psk)
+}
+
+func (c *Cache) get(ctx context.Context, key string, psk []byte) (io.ReadCloser, *int64, error) {
+	logData := log.Data{
+		"bucket_name": c.cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"user_psk":    psk != nil,
+	}
+
+	decrypt := psk != nil && c.opts.AllowPSKPlaintext
+	id := key
+	if decrypt {
+		// decrypted content is only served to callers with the same psk
+		id = key + "\x00" + crypto.KeyCheckValue(psk)
+	}
+
+	entry, err := c.fill(ctx, id, key, psk, decrypt, logData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if entry != nil {
+		body, length, ok, err := c.open(entry, psk, logData)
+		if ok || err != nil {
+			return body, length, err
+		}
+	}
+
+	// the object could not be cached, or it was evicted before it could be read
+	if psk == nil {
+		return c.cli.Get(ctx, key)
+	}
+	return c.cli.GetWithPSK(ctx, key, psk)
+}
+
+// fill returns the cache entry for the provided id, once it has been revalidated or obtained from S3.
+// If a request to S3 is already in progress for it, its result is waited for instead of sending another one.
+// The request is sent with the context of the read that started it, so if it fails because that context was cancelled
+// or timed out, it is sent again for the reads that were waiting for it. A nil entry is returned if the object cannot be cached.
+func (c *Cache) fill(ctx context.Context, id, key string, psk []byte, decrypt bool, logData log.Data) (*cacheEntry, error) {
+	c.mutex.Lock()
+	if f, ok := c.inflight[id]; ok {
+		c.mutex.Unlock()
+		select {
+		case <-f.done:
+			if ctx.Err() == nil && (errors.Is(f.err, context.Canceled) || errors.Is(f.err, context.DeadlineExceeded)) {
+				return c.fill(ctx, id, key, psk, decrypt, logData)
+			}
+			return f.entry, f.err
+		case <-ctx.Done():
+			return nil, NewError(fmt.Errorf("error waiting for object to be cached: %w", ctx.Err()), logData)
+		}
+	}
+
+	var cached *cacheEntry
+	if elem, ok := c.entries[id]; ok {
+		cached = elem.Value.(*cacheEntry)
+	}
+	f := &cacheFill{done: make(chan struct{})}
+	c.inflight[id] = f
+	c.mutex.Unlock()
+
+	f.entry, f.err = c.fetch(ctx, id, key, psk, decrypt, cached, logData)
+
+	c.mutex.Lock()
+	delete(c.inflight, id)
+	c.mutex.Unlock()
+	close(f.done)
+
+	return f.entry, f.err
+}
+
+// fetch revalidates the provided cached entry, if any, and stores a new copy of the object in the cache if it changed or was not cached
+func (c *Cache) fetch(ctx context.Context, id, key string, psk []byte, decrypt bool, cached *cacheEntry, logData log.Data) (*cacheEntry, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(c.cli.bucketName),
+		Key:    aws.String(key),
+	}
+	if cached != nil {
+		input.IfNoneMatch = aws.String(cached.etag)
+		logData["if_none_match"] = cached.etag
+	}
+
+	result, err := c.cli.sdkClient.GetObject(ctx, input)
+	if err != nil {
+		err = fmt.Errorf("error getting object from s3: %w", err)
+		condErr := conditionalError(err, logData)
+		if cached != nil {
+			var errNotModified *ErrNotModified
+			if errors.As(condErr, &errNotModified) {
+				return cached, nil
+			}
+			if httpStatusCode(err) == http.StatusNotFound || errorCode(err) == "NoSuchKey" {
+				c.remove(cached)
+			}
+		}
+		if condErr != nil {
+			return nil, condErr
+		}
+		return nil, NewError(err, logData)
+	}
+	defer result.Body.Close()
+
+	etag := aws.ToString(result.ETag)
+	if etag == "" || aws.ToInt64(result.ContentLength) > c.opts.MaxSize {
+		if cached != nil {
+			c.remove(cached)
+		}
+		return nil, nil
+	}
+
+	var body io.Reader = result.Body
+	if decrypt {
+		if err := crypto.CheckPSK(result.Metadata, psk); err != nil {
+			return nil, NewWrongPSKError(fmt.Errorf("error validating psk: %w", err), logData)
+		}
+		chunkSize, err := crypto.ChunkSizeFromMetadata(result.Metadata, crypto.DefaultChunkSize)
+		if err != nil {
+			return nil, NewError(fmt.Errorf("error reading encryption metadata: %w", err), logData)
+		}
+		decrypted := crypto.NewDecryptReader(result.Body, psk, chunkSize)
+		// the digest of compressed objects is calculated over their uncompressed content, so it is verified when they are read
+		if !isCompressed(result.Metadata) {
+			decrypted = crypto.NewIntegrityReader(decrypted, result.Metadata, psk)
+		}
+		body = decrypted
+	}
+
+	path, size, err := c.write(body)
+	if err != nil {
+		if errors.Is(err, crypto.ErrIntegrity) {
+			return nil, NewError(fmt.Errorf("error verifying object content: %w", err), logData)
+		}
+		return nil, NewError(fmt.Errorf("error writing object to cache: %w", err), logData)
+	}
+	if path == "" {
+		// the object was larger than the cache, but its length was not known in advance
+		if cached != nil {
+			c.remove(cached)
+		}
+		return nil, nil
+	}
+
+	entry := &cacheEntry{
+		id:        id,
+		path:      path,
+		etag:      etag,
+		metadata:  result.Metadata,
+		size:      size,
+		decrypted: decrypt,
+	}
+	c.add(entry)
+	return entry, nil
+}
+
+// write writes the provided content to a new cache file, returning its path and size.
+// An empty path is returned, and no file is left, if the content is larger than the cache.
+func (c *Cache) write(r io.Reader) (string, int64, error) {
+	f, err := os.CreateTemp(c.opts.Dir, "*"+cacheFileSuffix+".tmp")
+	if err != nil {
+		return "", 0, err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, c.opts.MaxSize+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || n > c.opts.MaxSize {
+		os.Remove(f.Name())
+		return "", 0, err
+	}
+
+	path := strings.TrimSuffix(f.Name(), ".tmp")
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return "", 0, err
+	}
+	return path, n, nil
+}
+
+// open returns a reader for the content of the provided entry, decrypted with the psk if it was not stored decrypted,
+// and marks it as the most recently used. If the entry is no longer cached, false is returned.
+func (c *Cache) open(entry *cacheEntry, psk []byte, logData log.Data) (io.ReadCloser, *int64, bool, error) {
+	c.mutex.Lock()
+	elem, ok := c.entries[entry.id]
+	if !ok || elem.Value != entry {
+		c.mutex.Unlock()
+		return nil, nil, false, nil
+	}
+	c.lru.MoveToFront(elem)
+	f, err := os.Open(entry.path)
+	c.mutex.Unlock()
+	if err != nil {
+		return nil, nil, false, NewError(fmt.Errorf("error opening cached object: %w", err), logData)
+	}
+
+	var body io.ReadCloser = f
+	if psk != nil && !entry.decrypted {
+		if err := crypto.CheckPSK(entry.metadata, psk); err != nil {
+			f.Close()
+			return nil, nil, false, NewWrongPSKError(fmt.Errorf("error validating psk: %w", err), logData)
+		}
+		chunkSize, err := crypto.ChunkSizeFromMetadata(entry.metadata, crypto.DefaultChunkSize)
+		if err != nil {
+			f.Close()
+			return nil, nil, false, NewError(fmt.Errorf("error reading encryption metadata: %w", err), logData)
+		}
+		body = crypto.NewDecryptReader(f, psk, chunkSize)
+	}
+
+	body, err = decompressReader(body, entry.metadata)
+	if err != nil {
+		return nil, nil, false, NewError(fmt.Errorf("error decompressing cached object: %w", err), logData)
+	}
+	// the digest is verified once the content is decrypted and decompressed, unless it was verified when it was stored
+	if psk != nil && (!entry.decrypted || isCompressed(entry.metadata)) {
+		body = crypto.NewIntegrityReader(body, entry.metadata, psk)
+	}
+	return body, contentLength(entry.metadata, aws.Int64(entry.size)), true, nil
+}
+
+// add stores the provided entry as the most recently used, replacing any previous entry for the same object,
+// and evicts the least recently used entries until the cache is within its max size
+func (c *Cache) add(entry *cacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.entries[entry.id]; ok {
+		c.removeElement(elem)
+	}
+	c.entries[entry.id] = c.lru.PushFront(entry)
+	c.size += entry.size
+
+	for c.size > c.opts.MaxSize {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// remove removes the provided entry from the cache, if it is still cached
+func (c *Cache) remove(entry *cacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.entries[entry.id]; ok && elem.Value == entry {
+		c.removeElement(elem)
+	}
+}
+
+// removeElement removes the entry of the provided list element and its file. The caller must hold the mutex.
+// Readers that already opened the file can still read it.
+func (c *Cache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.id)
+	c.size -= entry.size
+	os.Remove(entry.path)
+}
+
+// removeCacheFiles removes the cached objects, complete or not, found in the provided directory
+func removeCacheFiles(dir string) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.Type().IsRegular() && strings.Contains(file.Name(), cacheFileSuffix) {
+			if err := os.Remove(filepath.Join(dir, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// cachedBucket is a fake bucket that serves its objects for GetObject requests, honouring If-None-Match
+type cachedBucket struct {
+	mutex   *sync.Mutex
+	objects map[string]*fileObject
+
+	// release, if not nil, makes GetObject requests wait until it is closed
+	release chan struct{}
+}
+
+func newCachedBucket() *cachedBucket {
+	return &cachedBucket{
+		mutex:   &sync.Mutex{},
+		objects: map[string]*fileObject{},
+	}
+}
+
+func (b *cachedBucket) put(key string, obj *fileObject) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.objects[key] = obj
+}
+
+func (b *cachedBucket) mock() *mock.S3SDKClientMock {
+	return &mock.S3SDKClientMock{
+		GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			if b.release != nil {
+				select {
+				case <-b.release:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			obj, ok := b.objects[*in.Key]
+			if !ok {
+				return nil, newResponseError(http.StatusNotFound, "NoSuchKey")
+			}
+			if in.IfNoneMatch != nil && *in.IfNoneMatch == obj.etag {
+				return nil, newResponseError(http.StatusNotModified, "NotModified")
+			}
+			return &s3.GetObjectOutput{
+				Body:          io.NopCloser(bytes.NewReader(obj.content)),
+				ContentLength: aws.Int64(int64(len(obj.content))),
+				ETag:          aws.String(obj.etag),
+				Metadata:      obj.metadata,
+			}, nil
+		},
+	}
+}
+
+// cachedFiles returns the content of the files in the provided cache directory
+func cachedFiles(dir string) [][]byte {
+	files, err := os.ReadDir(dir)
+	So(err, ShouldBeNil)
+	contents := [][]byte{}
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		So(err, ShouldBeNil)
+		contents = append(contents, content)
+	}
+	return contents
+}
+
+func TestCache(t *testing.T) {
+	Convey("Given a cache for a bucket with two objects", t, func() {
+		ctx := context.Background()
+		dir := t.TempDir()
+
+		bucket := newCachedBucket()
+		bucket.put("a.csv", &fileObject{content: []byte("aaaaaa"), etag: `"a1"`})
+		bucket.put("b.csv", &fileObject{content: []byte("bbbbbb"), etag: `"b1"`})
+		sdkMock := bucket.mock()
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		cache, err := dps3.NewCache(cli, dps3.CacheOptions{Dir: dir, MaxSize: 10})
+		So(err, ShouldBeNil)
+
+		Convey("The first Get of an object reads it from S3 and stores it on disk", func() {
+			ret, length, err := cache.Get(ctx, "a.csv")
+			So(err, ShouldBeNil)
+			So(*length, ShouldEqual, 6)
+			So(string(readBytes(ret)), ShouldEqual, "aaaaaa")
+			So(sdkMock.GetObjectCalls()[0].In.IfNoneMatch, ShouldBeNil)
+			So(cachedFiles(dir), ShouldResemble, [][]byte{[]byte("aaaaaa")})
+
+			Convey("Later Gets revalidate the cached object with its ETag and read it from disk", func() {
+				ret, length, err := cache.Get(ctx, "a.csv")
+				So(err, ShouldBeNil)
+				So(*length, ShouldEqual, 6)
+				So(string(readBytes(ret)), ShouldEqual, "aaaaaa")
+				So(sdkMock.GetObjectCalls(), ShouldHaveLength, 2)
+				So(*sdkMock.GetObjectCalls()[1].In.IfNoneMatch, ShouldEqual, `"a1"`)
+			})
+
+			Convey("Later Gets replace the cached object if it changed in S3", func() {
+				bucket.put("a.csv", &fileObject{content: []byte("AAAA"), etag: `"a2"`})
+				ret, _, err := cache.Get(ctx, "a.csv")
+				So(err, ShouldBeNil)
+				So(string(readBytes(ret)), ShouldEqual, "AAAA")
+				So(cachedFiles(dir), ShouldResemble, [][]byte{[]byte("AAAA")})
+			})
+
+			Convey("Later Gets remove the cached object and fail if it was deleted from S3", func() {
+				delete(bucket.objects, "a.csv")
+				_, _, err := cache.Get(ctx, "a.csv")
+				So(err, ShouldNotBeNil)
+				So(cachedFiles(dir), ShouldBeEmpty)
+			})
+
+			Convey("Caching another object that does not fit evicts the least recently used one", func() {
+				ret, _, err := cache.Get(ctx, "b.csv")
+				So(err, ShouldBeNil)
+				So(string(readBytes(ret)), ShouldEqual, "bbbbbb")
+				So(cachedFiles(dir), ShouldResemble, [][]byte{[]byte("bbbbbb")})
+
+				ret, _, err = cache.Get(ctx, "a.csv")
+				So(err, ShouldBeNil)
+				So(string(readBytes(ret)), ShouldEqual, "aaaaaa")
+				So(sdkMock.GetObjectCalls()[2].In.IfNoneMatch, ShouldBeNil)
+			})
+		})
+
+		Convey("Objects larger than the cache are read from S3 without being cached", func() {
+			bucket.put("big.csv", &fileObject{content: []byte("0123456789abc"), etag: `"big"`})
+			ret, _, err := cache.Get(ctx, "big.csv")
+			So(err, ShouldBeNil)
+			So(string(readBytes(ret)), ShouldEqual, "0123456789abc")
+			So(cachedFiles(dir), ShouldBeEmpty)
+		})
+
+		Convey("Concurrent Gets of an object that is not cached share a single request to S3", func() {
+			bucket.release = make(chan struct{})
+			wg := &sync.WaitGroup{}
+			results := make(chan string, 5)
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ret, _, err := cache.Get(ctx, "a.csv")
+					if err != nil {
+						results <- err.Error()
+						return
+					}
+					b, _ := io.ReadAll(ret)
+					ret.Close()
+					results <- string(b)
+				}()
+			}
+			close(bucket.release)
+			wg.Wait()
+			close(results)
+
+			for result := range results {
+				So(result, ShouldEqual, "aaaaaa")
+			}
+			// the goroutines that started after the first request finished revalidate the cached object
+			So(sdkMock.GetObjectCalls()[0].In.IfNoneMatch, ShouldBeNil)
+			for _, call := range sdkMock.GetObjectCalls()[1:] {
+				So(*call.In.IfNoneMatch, ShouldEqual, `"a1"`)
+			}
+		})
+
+		Convey("A Get waiting for the request of another Get whose context is cancelled sends the request again", func() {
+			bucket.release = make(chan struct{})
+			firstCtx, cancel := context.WithCancel(ctx)
+			firstErr := make(chan error, 1)
+			go func() {
+				_, _, err := cache.Get(firstCtx, "a.csv")
+				firstErr <- err
+			}()
+			for len(sdkMock.GetObjectCalls()) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			second := make(chan string, 1)
+			go func() {
+				ret, _, err := cache.Get(ctx, "a.csv")
+				if err != nil {
+					second <- err.Error()
+					return
+				}
+				b, _ := io.ReadAll(ret)
+				ret.Close()
+				second <- string(b)
+			}()
+			time.Sleep(10 * time.Millisecond)
+
+			cancel()
+			So(errors.Is(<-firstErr, context.Canceled), ShouldBeTrue)
+			close(bucket.release)
+			So(<-second, ShouldEqual, "aaaaaa")
+			So(len(sdkMock.GetObjectCalls()), ShouldEqual, 2)
+		})
+
+		Convey("Stale cached objects are removed when a new cache is created in the same directory", func() {
+			_, _, err := cache.Get(ctx, "a.csv")
+			So(err, ShouldBeNil)
+			So(cachedFiles(dir), ShouldHaveLength, 1)
+
+			_, err = dps3.NewCache(cli, dps3.CacheOptions{Dir: dir, MaxSize: 10})
+			So(err, ShouldBeNil)
+			So(cachedFiles(dir), ShouldBeEmpty)
+		})
+	})
+
+	Convey("NewCache fails if the directory or max size are not provided", t, func() {
+		cli := dps3.InstantiateClient(&mock.S3SDKClientMock{}, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+		_, err := dps3.NewCache(cli, dps3.CacheOptions{MaxSize: 10})
+		So(err, ShouldNotBeNil)
+		_, err = dps3.NewCache(cli, dps3.CacheOptions{Dir: t.TempDir()})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestCacheWithPSK(t *testing.T) {
+	Convey("Given a cache for a bucket with an object encrypted with a psk", t, func() {
+		ctx := context.Background()
+		dir := t.TempDir()
+		psk := []byte("0123456789abcdef")
+		chunkSize := 16
+
+		encrypted := encryptChunks(psk, testCSV, chunkSize)
+		metadata := crypto.SetPSKMetadata(nil, psk, chunkSize)
+		bucket := newCachedBucket()
+		bucket.put(testS3Key, &fileObject{content: encrypted, etag: `"enc"`, metadata: metadata})
+		sdkMock := bucket.mock()
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetWithPSK returns the decrypted content, storing only the encrypted content on disk", func() {
+			cache, err := dps3.NewCache(cli, dps3.CacheOptions{Dir: dir, MaxSize: 1 << 20})
+			So(err, ShouldBeNil)
+
+			for i := 0; i < 2; i++ {
+				ret, length, err := cache.GetWithPSK(ctx, testS3Key, psk)
+				So(err, ShouldBeNil)
+				So(*length, ShouldEqual, len(testCSV))
+				So(readBytes(ret), ShouldResemble, testCSV)
+			}
+			So(sdkMock.GetObjectCalls(), ShouldHaveLength, 2)
+			So(cachedFiles(dir), ShouldResemble, [][]byte{encrypted})
+
+			Convey("And GetWithPSK fails with ErrWrongPSK for the cached object if the psk is wrong", func() {
+				_, _, err := cache.GetWithPSK(ctx, testS3Key, []byte("fedcba9876543210"))
+				var errWrongPSK *dps3.ErrWrongPSK
+				So(errors.As(err, &errWrongPSK), ShouldBeTrue)
+			})
+		})
+
+		Convey("GetWithPSK stores the decrypted content on disk if plaintext is allowed", func() {
+			cache, err := dps3.NewCache(cli, dps3.CacheOptions{Dir: dir, MaxSize: 1 << 20, AllowPSKPlaintext: true})
+			So(err, ShouldBeNil)
+
+			ret, _, err := cache.GetWithPSK(ctx, testS3Key, psk)
+			So(err, ShouldBeNil)
+			So(readBytes(ret), ShouldResemble, testCSV)
+			So(cachedFiles(dir), ShouldResemble, [][]byte{testCSV})
+
+			Convey("And the decrypted content is not served for a different psk", func() {
+				_, _, err := cache.GetWithPSK(ctx, testS3Key, []byte("fedcba9876543210"))
+				var errWrongPSK *dps3.ErrWrongPSK
+				So(errors.As(err, &errWrongPSK), ShouldBeTrue)
+				So(sdkMock.GetObjectCalls(), ShouldHaveLength, 2)
+				So(sdkMock.GetObjectCalls()[1].In.IfNoneMatch, ShouldBeNil)
+			})
+		})
+	})
+}
@@ -21,22 +21,26 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
-// Client: client with sdkClient, cryptoClient, sdkUploader, cryptoUploader, bucketName, region, mutexUploadID and cfg
+// Client: client with sdkClient, cryptoClient, sdkUploader, cryptoUploader, sdkDownloader, bucketName, region, mutexUploadID, cfg, optFns, ownClients and compression
 type Client struct {
 	sdkClient      S3SDKClient
 	cryptoClient   S3CryptoClient
 	sdkUploader    S3SDKUploader
 	cryptoUploader S3CryptoUploader
+	sdkDownloader  S3SDKDownloader
 	bucketName     string
 	region         string
 	mutexUploadID  *sync.Mutex
 	cfg            aws.Config
+	optFns         []func(*s3.Options)
+	ownClients     bool
+	compression    Compression
 }
 
 // NewClient creates a new S3 Client configured for the given region and bucket name.
 // Note: This function will create a new config, if you already have a config, please use NewUploader instead
-// Any error establishing the AWS config will be returned
-func NewClient(ctx context.Context, region string, bucketName string) (*Client, error) {
+// Any error establishing the AWS config, or discovering the bucket region if WithRegionDiscovery is provided, will be returned
+func NewClient(ctx context.Context, region string, bucketName string, opts ...ClientOption) (*Client, error) {
 	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
 	if err != nil {
 		return nil, NewError(
@@ -47,13 +51,18 @@ func NewClient(ctx context.Context, region string, bucketName string) (*Client,
 			},
 		)
 	}
-	return NewClientWithConfig(bucketName, cfg), nil
+
+	cli := NewClientWithConfig(bucketName, cfg)
+	if err := cli.applyClientOptions(ctx, opts); err != nil {
+		return nil, err
+	}
+	return cli, nil
 }
 
 // NewClientWithCredentials creates a new S3 Client configured for the given region and bucket name with creds.
 // Note: This function will create a new config, if you already have a config, please use NewUploader instead
-// Any error establishing the AWS config will be returned
-func NewClientWithCredentials(ctx context.Context, region string, bucketName string, awsAccessKey string, awsSecretKey string) (*Client, error) {
+// Any error establishing the AWS config, or discovering the bucket region if WithRegionDiscovery is provided, will be returned
+func NewClientWithCredentials(ctx context.Context, region string, bucketName string, awsAccessKey string, awsSecretKey string, opts ...ClientOption) (*Client, error) {
 	cfg, err := config.LoadDefaultConfig(ctx,
 		config.WithRegion(region),
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(awsAccessKey, awsSecretKey, "")),
@@ -67,10 +76,16 @@ func NewClientWithCredentials(ctx context.Context, region string, bucketName str
 			},
 		)
 	}
-	return NewClientWithConfig(bucketName, cfg), nil
+
+	cli := NewClientWithConfig(bucketName, cfg)
+	if err := cli.applyClientOptions(ctx, opts); err != nil {
+		return nil, err
+	}
+	return cli, nil
 }
 
 // NewClientWithConfig creates a new S3 Client configured for the given bucket name, using the provided config and region within it.
+// If the region of the bucket is not known in advance, call DiscoverRegion on the new client before using it.
 func NewClientWithConfig(bucketName string, cfg aws.Config, optFns ...func(*s3.Options)) *Client {
 	// Get region for the Config
 	region := cfg.Region
@@ -87,16 +102,26 @@ func NewClientWithConfig(bucketName string, cfg aws.Config, optFns ...func(*s3.O
 	// Create crypto uploader, which allows user to provide a psk
 	cryptoUploader := crypto.NewUploader(cfg, &crypto.Config{HasUserDefinedPSK: true}, optFns...)
 
-	return InstantiateClient(sdkClient, cryptoClient, sdkUploader, cryptoUploader, bucketName, region, cfg)
+	cli := InstantiateClient(sdkClient, cryptoClient, sdkUploader, cryptoUploader, bucketName, region, cfg)
+	cli.optFns = optFns
+	cli.ownClients = true
+	return cli
 }
 
 // InstantiateClient creates a new instance of S3 struct with the provided clients, bucket and region.
+// Its downloader is created from the provided sdk client.
 func InstantiateClient(sdkClient S3SDKClient, cryptoClient S3CryptoClient, sdkUploader S3SDKUploader, cryptoUploader S3CryptoUploader, bucketName, region string, cfg aws.Config) *Client {
+	return InstantiateClientWithDownloader(sdkClient, cryptoClient, sdkUploader, cryptoUploader, manager.NewDownloader(sdkClient), bucketName, region, cfg)
+}
+
+// InstantiateClientWithDownloader creates a new instance of S3 struct with the provided clients, downloader, bucket and region.
+func InstantiateClientWithDownloader(sdkClient S3SDKClient, cryptoClient S3CryptoClient, sdkUploader S3SDKUploader, cryptoUploader S3CryptoUploader, sdkDownloader S3SDKDownloader, bucketName, region string, cfg aws.Config) *Client {
 	return &Client{
 		sdkClient:      sdkClient,
 		cryptoClient:   cryptoClient,
 		sdkUploader:    sdkUploader,
 		cryptoUploader: cryptoUploader,
+		sdkDownloader:  sdkDownloader,
 		bucketName:     bucketName,
 		region:         region,
 		mutexUploadID:  &sync.Mutex{},
@@ -113,3 +138,8 @@ func (cli *Client) Config() aws.Config {
 func (cli *Client) BucketName() string {
 	return cli.bucketName
 }
+
+// Region returns the region used by this S3 client, which is the discovered region of the bucket if DiscoverRegion was called
+func (cli *Client) Region() string {
+	return cli.region
+}
@@ -47,6 +47,29 @@ func TestPutWithPSK(t *testing.T) {
 	})
 }
 
+func TestPutWithPSKVersion(t *testing.T) {
+	Convey("Given an S3 client for a versioned bucket", t, func() {
+		ctx := context.Background()
+		psk := []byte("test psk")
+		objKey := "my/object/key"
+
+		cryptoMock := &mock.S3CryptoClientMock{
+			PutObjectWithPSKFunc: func(ctx context.Context, in1 *s3.PutObjectInput, in2 []byte) (*s3.PutObjectOutput, error) {
+				return &s3.PutObjectOutput{VersionId: aws.String("v2")}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(nil, cryptoMock, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("PutWithPSKVersion puts the object with the crypto client and returns its version id", func() {
+			versionID, err := cli.PutWithPSKVersion(ctx, &objKey, bytes.NewReader([]byte("test data")), psk)
+			So(err, ShouldBeNil)
+			So(versionID, ShouldEqual, "v2")
+			So(len(cryptoMock.PutObjectWithPSKCalls()), ShouldEqual, 1)
+			So(*cryptoMock.PutObjectWithPSKCalls()[0].In.Key, ShouldEqual, objKey)
+		})
+	})
+}
+
 // readBytes reads the bytes from the provided ReadCloser and asserts that there is no error
 func readBytes(ret io.ReadCloser) []byte {
 	buf := new(bytes.Buffer)
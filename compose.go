@@ -0,0 +1,293 @@
+// file: compose.go
+//
+// Contains methods to compose an object of the bucket configured for the client from the concatenation of existing objects,
+// with a multipart upload whose parts are copied server-side from the source objects.
+// Sources smaller than the 5 MB minimum part size are merged, by downloading them, into parts of at least 5 MB.
+//
+// Requires "s3:GetObject" action allowed by IAM policy for source objects,
+// and "s3:PutObject" and "s3:AbortMultipartUpload" for the destination object.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ComposeOptions represents the optional configuration of a composition
+type ComposeOptions struct {
+	// ContentType, Metadata and Tags are set for the composed object
+	ContentType string
+	Metadata    map[string]string
+	Tags        map[string]string
+
+	// StorageClass, ServerSideEncryption and SSEKMSKeyID configure how the composed object is stored.
+	// If they are empty, the defaults of the bucket are used.
+	StorageClass         types.StorageClass
+	ServerSideEncryption types.ServerSideEncryption
+	SSEKMSKeyID          string
+
+	// Concurrency is the number of parts copied or uploaded in parallel. If it is zero, DefaultCopyConcurrency is used.
+	Concurrency int
+}
+
+// composeRange represents a byte range [start, end) of a source object of a composition
+type composeRange struct {
+	key        string
+	etag       *string
+	start, end int64
+}
+
+// composePart represents a part of a composition, which is either copied server-side from a single range of a source object,
+// or uploaded with the content of one or more ranges of source objects that are too small to be copied on their own
+type composePart struct {
+	copy   bool
+	ranges []composeRange
+}
+
+// Compose creates the object for the given destination key from the concatenation of the objects for the given source keys,
+// in the provided order (all inside the bucket configured for this client). Source keys must not be empty or the destination key,
+// which are rejected before any request is sent. The destination is only replaced, atomically, once all the parts have been copied.
+// If any part fails, nothing is written.
+// Sources are pinned to their ETags, so an ErrPreconditionFailed error is returned if any of them changes while it is composed.
+// Compressed or psk encrypted objects cannot be composed, as their concatenation could not be read.
+func (cli *Client) Compose(ctx context.Context, dstKey string, srcKeys []string, opts ComposeOptions) (*CopyResult, error) {
+	logData := log.Data{
+		"bucket_name":     cli.bucketName,
+		"destination_key": dstKey,
+		"num_sources":     len(srcKeys),
+	}
+
+	if len(srcKeys) == 0 {
+		return nil, NewError(errors.New("no source objects provided to compose"), logData)
+	}
+	for i, srcKey := range srcKeys {
+		switch srcKey {
+		case "":
+			logData["source_index"] = i
+			return nil, NewError(errors.New("empty source key provided to compose"), logData)
+		case dstKey:
+			logData["source_key"] = srcKey
+			return nil, NewError(errors.New("the destination object cannot be one of the sources of a composition"), logData)
+		}
+	}
+	if err := ValidateTags(opts.Tags); err != nil {
+		return nil, NewInvalidTagsError(err, logData)
+	}
+
+	sources, err := cli.headSources(ctx, srcKeys, opts.Concurrency)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error getting metadata of source objects from s3: %w", err), logData)
+	}
+
+	size := int64(0)
+	for i, src := range sources {
+		if isCompressed(src.Metadata) || isPSKEncrypted(src.Metadata) {
+			logData["source_key"] = srcKeys[i]
+			return nil, NewError(errors.New("compressed or psk encrypted objects cannot be composed"), logData)
+		}
+		size += aws.ToInt64(src.ContentLength)
+	}
+	logData["size"] = size
+
+	parts := planComposeParts(srcKeys, sources)
+	logData["num_parts"] = len(parts)
+	if len(parts) > maxParts {
+		return nil, NewError(fmt.Errorf("composition would need more than %d parts", maxParts), logData)
+	}
+
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(cli.bucketName),
+		Key:                  aws.String(dstKey),
+		Metadata:             opts.Metadata,
+		StorageClass:         opts.StorageClass,
+		ServerSideEncryption: opts.ServerSideEncryption,
+	}
+	if opts.ContentType != "" {
+		createInput.ContentType = aws.String(opts.ContentType)
+	}
+	if len(opts.Tags) > 0 {
+		createInput.Tagging = aws.String(EncodeTags(opts.Tags))
+	}
+	if opts.SSEKMSKeyID != "" {
+		createInput.SSEKMSKeyId = aws.String(opts.SSEKMSKeyID)
+	}
+
+	created, err := cli.sdkClient.CreateMultipartUpload(ctx, createInput)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error creating multipart upload for composition in s3: %w", err), logData)
+	}
+	uploadID := created.UploadId
+	logData["upload_id"] = aws.ToString(uploadID)
+
+	completed, err := completeParts(ctx, len(parts), opts.Concurrency, func(ctx context.Context, n int) (*string, error) {
+		return cli.composePart(ctx, dstKey, uploadID, n, parts[n-1])
+	})
+	if err != nil {
+		err = errors.Join(fmt.Errorf("error composing object parts in s3: %w", err), cli.abortMultipartUpload(ctx, dstKey, uploadID))
+		if isPreconditionFailed(err) {
+			return nil, NewPreconditionFailedError(err, logData)
+		}
+		return nil, NewError(err, logData)
+	}
+
+	out, err := cli.sdkClient.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(cli.bucketName),
+		Key:             aws.String(dstKey),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error completing multipart upload for composition in s3: %w", err), logData)
+	}
+
+	return &CopyResult{
+		Key:       dstKey,
+		ETag:      aws.ToString(out.ETag),
+		VersionID: aws.ToString(out.VersionId),
+		Size:      size,
+	}, nil
+}
+
+// headSources returns the metadata of the objects for the provided keys, in order, obtained in parallel
+func (cli *Client) headSources(ctx context.Context, keys []string, concurrency int) ([]*s3.HeadObjectOutput, error) {
+	if concurrency <= 0 {
+		concurrency = DefaultCopyConcurrency
+	}
+
+	sources := make([]*s3.HeadObjectOutput, len(keys))
+	errs := make([]error, len(keys))
+	sem := make(chan struct{}, concurrency)
+	wg := &sync.WaitGroup{}
+	for i, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			sources[i], errs[i] = cli.sdkClient.HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(cli.bucketName),
+				Key:    aws.String(key),
+			})
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("%s: %w", key, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// planComposeParts returns the parts of the composition of the provided sources. Ranges of at least 5 MB are copied server-side,
+// split in parts of up to 5 GB, and smaller ranges are merged with the following ones into uploaded parts of 5 MB,
+// except for the last part, which may be smaller.
+func planComposeParts(keys []string, sources []*s3.HeadObjectOutput) []composePart {
+	parts := []composePart{}
+	pending := composePart{}
+	pendingSize := int64(0)
+
+	for i, src := range sources {
+		size := aws.ToInt64(src.ContentLength)
+		for start := int64(0); start < size; {
+			remaining := size - start
+			if pendingSize == 0 && remaining >= minPartSize {
+				numParts := (remaining + MaxCopyObjectSize - 1) / MaxCopyObjectSize
+				partSize := (remaining + numParts - 1) / numParts
+				for ; start < size; start += partSize {
+					parts = append(parts, composePart{
+						copy:   true,
+						ranges: []composeRange{{key: keys[i], etag: src.ETag, start: start, end: min(start+partSize, size)}},
+					})
+				}
+				break
+			}
+
+			n := min(remaining, minPartSize-pendingSize)
+			pending.ranges = append(pending.ranges, composeRange{key: keys[i], etag: src.ETag, start: start, end: start + n})
+			pendingSize += n
+			start += n
+			if pendingSize == minPartSize {
+				parts = append(parts, pending)
+				pending, pendingSize = composePart{}, 0
+			}
+		}
+	}
+
+	// the last part may be smaller than 5 MB, or even empty if all the sources are
+	if pendingSize > 0 || len(parts) == 0 {
+		parts = append(parts, pending)
+	}
+	return parts
+}
+
+// composePart copies or uploads the provided part of a composition, returning its ETag
+func (cli *Client) composePart(ctx context.Context, dstKey string, uploadID *string, n int, part composePart) (*string, error) {
+	if part.copy {
+		r := part.ranges[0]
+		out, err := cli.sdkClient.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:            aws.String(cli.bucketName),
+			Key:               aws.String(dstKey),
+			UploadId:          uploadID,
+			PartNumber:        aws.Int32(int32(n)),
+			CopySource:        aws.String(copySource(cli.bucketName, r.key, "")),
+			CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", r.start, r.end-1)),
+			CopySourceIfMatch: r.etag,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out.CopyPartResult.ETag, nil
+	}
+
+	buf := &bytes.Buffer{}
+	for _, r := range part.ranges {
+		out, err := cli.sdkClient.GetObject(ctx, &s3.GetObjectInput{
+			Bucket:  aws.String(cli.bucketName),
+			Key:     aws.String(r.key),
+			Range:   aws.String(fmt.Sprintf("bytes=%d-%d", r.start, r.end-1)),
+			IfMatch: r.etag,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting %s: %w", r.key, err)
+		}
+		_, err = io.Copy(buf, out.Body)
+		out.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", r.key, err)
+		}
+	}
+
+	out, err := cli.sdkClient.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(cli.bucketName),
+		Key:           aws.String(dstKey),
+		UploadId:      uploadID,
+		PartNumber:    aws.Int32(int32(n)),
+		Body:          bytes.NewReader(buf.Bytes()),
+		ContentLength: aws.Int64(int64(buf.Len())),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.ETag, nil
+}
+
+// isPSKEncrypted returns true if the provided metadata records that the object was encrypted with a psk by this library
+func isPSKEncrypted(metadata map[string]string) bool {
+	_, ok := crypto.MetadataValue(metadata, crypto.FormatMetadataKey)
+	return ok
+}
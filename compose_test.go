@@ -0,0 +1,207 @@
+package s3_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const mb = 1024 * 1024
+
+// newComposeMock returns an S3 client mock for source objects of the provided sizes, whose content is their single-character key repeated.
+// The content of the uploaded parts is recorded by part number.
+func newComposeMock(sizes map[string]int64, uploaded map[int32]string) *mock.S3SDKClientMock {
+	mutex := &sync.Mutex{}
+	return &mock.S3SDKClientMock{
+		HeadObjectFunc: func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			size, ok := sizes[*in.Key]
+			if !ok {
+				return nil, newResponseError(http.StatusNotFound, "NotFound")
+			}
+			return &s3.HeadObjectOutput{ContentLength: aws.Int64(size), ETag: aws.String(`"etag-` + *in.Key + `"`)}, nil
+		},
+		GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			var start, end int
+			if _, err := fmt.Sscanf(*in.Range, "bytes=%d-%d", &start, &end); err != nil {
+				return nil, err
+			}
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(strings.Repeat(*in.Key, end-start+1)))}, nil
+		},
+		CreateMultipartUploadFunc: func(ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+		},
+		UploadPartCopyFunc: func(ctx context.Context, in *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+			return &s3.UploadPartCopyOutput{CopyPartResult: &types.CopyPartResult{ETag: aws.String(*in.CopySourceRange)}}, nil
+		},
+		UploadPartFunc: func(ctx context.Context, in *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+			b, err := io.ReadAll(in.Body)
+			if err != nil {
+				return nil, err
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			uploaded[*in.PartNumber] = string(b)
+			return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("part-%d", *in.PartNumber))}, nil
+		},
+		CompleteMultipartUploadFunc: func(ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+			return &s3.CompleteMultipartUploadOutput{ETag: aws.String(`"composed-3"`), VersionId: aws.String("v1")}, nil
+		},
+		AbortMultipartUploadFunc: func(ctx context.Context, in *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+			return &s3.AbortMultipartUploadOutput{}, nil
+		},
+	}
+}
+
+func TestCompose(t *testing.T) {
+	Convey("Given an S3 client and source objects larger and smaller than the minimum part size", t, func() {
+		ctx := context.Background()
+		uploaded := map[int32]string{}
+		sdkMock := newComposeMock(map[string]int64{
+			"a": 6 * mb,
+			"b": 1 * mb,
+			"c": 5 * mb,
+			"e": 11 * 1024 * mb,
+			"f": 2 * mb,
+			"z": 0,
+		}, uploaded)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Compose copies the large ranges and uploads the small ones merged, in order, pinned to the source ETags", func() {
+			result, err := cli.Compose(ctx, copyDstKey, []string{"a", "b", "z", "c", "e"}, dps3.ComposeOptions{})
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, &dps3.CopyResult{Key: copyDstKey, ETag: `"composed-3"`, VersionID: "v1", Size: (12 + 11*1024) * mb})
+
+			// a is copied, b is merged with the start of c, the rest of c with the start of e, and the rest of e is copied in 3 parts
+			copies := sdkMock.UploadPartCopyCalls()
+			sort.Slice(copies, func(i, j int) bool {
+				return *copies[i].In.PartNumber < *copies[j].In.PartNumber
+			})
+			So(copies, ShouldHaveLength, 4)
+			So(*copies[0].In.PartNumber, ShouldEqual, 1)
+			So(*copies[0].In.CopySource, ShouldEqual, ExistingBucket+"/a")
+			So(*copies[0].In.CopySourceRange, ShouldEqual, fmt.Sprintf("bytes=0-%d", 6*mb-1))
+			So(*copies[0].In.CopySourceIfMatch, ShouldEqual, `"etag-a"`)
+			remaining := int64(11*1024*mb - 4*mb)
+			partSize := (remaining + 2) / 3
+			for i, call := range copies[1:] {
+				start := 4*mb + int64(i)*partSize
+				end := min(start+partSize, 11*1024*mb)
+				So(*call.In.PartNumber, ShouldEqual, i+4)
+				So(*call.In.CopySource, ShouldEqual, ExistingBucket+"/e")
+				So(*call.In.CopySourceRange, ShouldEqual, fmt.Sprintf("bytes=%d-%d", start, end-1))
+				So(*call.In.CopySourceIfMatch, ShouldEqual, `"etag-e"`)
+			}
+
+			So(uploaded, ShouldHaveLength, 2)
+			So(uploaded[2], ShouldEqual, strings.Repeat("b", 1*mb)+strings.Repeat("c", 4*mb))
+			So(uploaded[3], ShouldEqual, strings.Repeat("c", 1*mb)+strings.Repeat("e", 4*mb))
+			for _, call := range sdkMock.GetObjectCalls() {
+				So(*call.In.IfMatch, ShouldEqual, `"etag-`+*call.In.Key+`"`)
+			}
+
+			complete := sdkMock.CompleteMultipartUploadCalls()
+			So(complete, ShouldHaveLength, 1)
+			So(*complete[0].In.UploadId, ShouldEqual, "upload-1")
+			parts := complete[0].In.MultipartUpload.Parts
+			So(parts, ShouldHaveLength, 6)
+			for i, part := range parts {
+				So(*part.PartNumber, ShouldEqual, i+1)
+			}
+			So(*parts[1].ETag, ShouldEqual, "part-2")
+			So(sdkMock.AbortMultipartUploadCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Compose uploads the last part even if it is smaller than the minimum part size", func() {
+			_, err := cli.Compose(ctx, copyDstKey, []string{"a", "f", "b"}, dps3.ComposeOptions{})
+			So(err, ShouldBeNil)
+			So(sdkMock.UploadPartCopyCalls(), ShouldHaveLength, 1)
+			So(uploaded, ShouldResemble, map[int32]string{2: strings.Repeat("f", 2*mb) + strings.Repeat("b", 1*mb)})
+		})
+
+		Convey("Compose sets the provided metadata, tags and storage options on the upload", func() {
+			_, err := cli.Compose(ctx, copyDstKey, []string{"a", "b"}, dps3.ComposeOptions{
+				ContentType:          "text/csv",
+				Metadata:             map[string]string{"owner": "team-a"},
+				Tags:                 map[string]string{"stage": "published"},
+				StorageClass:         types.StorageClassStandardIa,
+				ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+				SSEKMSKeyID:          "key-1",
+			})
+			So(err, ShouldBeNil)
+			in := sdkMock.CreateMultipartUploadCalls()[0].In
+			So(*in.Bucket, ShouldEqual, ExistingBucket)
+			So(*in.Key, ShouldEqual, copyDstKey)
+			So(*in.ContentType, ShouldEqual, "text/csv")
+			So(in.Metadata, ShouldResemble, map[string]string{"owner": "team-a"})
+			So(*in.Tagging, ShouldEqual, "stage=published")
+			So(in.StorageClass, ShouldEqual, types.StorageClassStandardIa)
+			So(in.ServerSideEncryption, ShouldEqual, types.ServerSideEncryptionAwsKms)
+			So(*in.SSEKMSKeyId, ShouldEqual, "key-1")
+		})
+
+		Convey("Compose fails with ErrPreconditionFailed, aborting the upload, if a source changes while it is composed", func() {
+			sdkMock.UploadPartCopyFunc = func(ctx context.Context, in *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+				return nil, newResponseError(http.StatusPreconditionFailed, "PreconditionFailed")
+			}
+			_, err := cli.Compose(ctx, copyDstKey, []string{"a", "b"}, dps3.ComposeOptions{})
+			var errPrecondition *dps3.ErrPreconditionFailed
+			So(errors.As(err, &errPrecondition), ShouldBeTrue)
+			So(sdkMock.AbortMultipartUploadCalls(), ShouldHaveLength, 1)
+			So(*sdkMock.AbortMultipartUploadCalls()[0].In.UploadId, ShouldEqual, "upload-1")
+			So(sdkMock.CompleteMultipartUploadCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Compose fails without creating an upload if a source does not exist", func() {
+			_, err := cli.Compose(ctx, copyDstKey, []string{"a", "missing"}, dps3.ComposeOptions{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "missing")
+			So(sdkMock.CreateMultipartUploadCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Compose fails without creating an upload if no sources are provided", func() {
+			_, err := cli.Compose(ctx, copyDstKey, nil, dps3.ComposeOptions{})
+			So(err, ShouldNotBeNil)
+			So(sdkMock.HeadObjectCalls(), ShouldBeEmpty)
+			So(sdkMock.CreateMultipartUploadCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Compose fails without sending any request if a source key is empty", func() {
+			_, err := cli.Compose(ctx, copyDstKey, []string{"a", ""}, dps3.ComposeOptions{})
+			So(err, ShouldNotBeNil)
+			So(sdkMock.HeadObjectCalls(), ShouldBeEmpty)
+			So(sdkMock.CreateMultipartUploadCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Compose fails without sending any request if a source is the destination object", func() {
+			_, err := cli.Compose(ctx, copyDstKey, []string{"a", copyDstKey}, dps3.ComposeOptions{})
+			So(err, ShouldNotBeNil)
+			So(sdkMock.HeadObjectCalls(), ShouldBeEmpty)
+			So(sdkMock.CreateMultipartUploadCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Compose fails without creating an upload if a source is compressed", func() {
+			sdkMock.HeadObjectFunc = func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return &s3.HeadObjectOutput{
+					ContentLength: aws.Int64(mb),
+					Metadata:      map[string]string{dps3.CompressionMetadataKey: string(dps3.CompressionGzip)},
+				}, nil
+			}
+			_, err := cli.Compose(ctx, copyDstKey, []string{"a", "b"}, dps3.ComposeOptions{})
+			So(err, ShouldNotBeNil)
+			So(sdkMock.CreateMultipartUploadCalls(), ShouldBeEmpty)
+		})
+	})
+}
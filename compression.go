@@ -0,0 +1,226 @@
+// file: compression.go
+//
+// Contains the codecs used to compress object content before it is uploaded (and encrypted, if a psk is provided),
+// and the readers that transparently decompress it when it is obtained from S3.
+//
+// The codec and the uncompressed length are recorded in the object metadata,
+// so no configuration is required to read compressed objects.
+package s3
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the codec used to compress object content before it is uploaded
+type Compression string
+
+// Possible compression codecs
+const (
+	// CompressionNone uploads the content as it is provided
+	CompressionNone Compression = ""
+	// CompressionGzip compresses the content with gzip
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd compresses the content with zstd
+	CompressionZstd Compression = "zstd"
+)
+
+const (
+	// CompressionMetadataKey is the object metadata key holding the codec used to compress the object content
+	CompressionMetadataKey = "compression"
+
+	// UncompressedLengthMetadataKey is the object metadata key holding the length of the content before it was compressed
+	UncompressedLengthMetadataKey = "uncompressedlength"
+)
+
+// WithCompression returns a copy of this client that compresses the content of Upload, UploadWithPSK and PutWithPSK
+// with the provided codec, before it is encrypted. The copy shares the SDK clients and uploaders with this client.
+//
+// Objects are decompressed transparently by Get and GetWithPSK regardless of the client that reads them.
+func (cli *Client) WithCompression(codec Compression) *Client {
+	c := *cli
+	c.compression = codec
+	return &c
+}
+
+// Compression returns the codec used by this client to compress uploaded content
+func (cli *Client) Compression() Compression {
+	return cli.compression
+}
+
+// validate returns an error if the codec is not supported
+func (codec Compression) validate() error {
+	switch codec {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+		return nil
+	}
+	return fmt.Errorf("unsupported compression codec: %s", codec)
+}
+
+// compressInput replaces the body of the provided input with a reader that compresses it with the client codec,
+// recording the codec and, if it can be determined, the uncompressed length in the input metadata.
+// If a psk is provided and the body is seekable, the digest of the uncompressed content is recorded too,
+// as the compressed body cannot be read twice to calculate it.
+// The returned closer must be closed once the input has been uploaded, to release the compressing goroutine.
+func (cli *Client) compressInput(input *s3.PutObjectInput, psk []byte) (io.Closer, error) {
+	if err := cli.compression.validate(); err != nil {
+		return nil, err
+	}
+
+	length := input.ContentLength
+	if length == nil {
+		if seeker, ok := input.Body.(io.Seeker); ok {
+			if l, err := remainingLength(seeker); err == nil {
+				length = &l
+			}
+		}
+	}
+
+	input.Metadata = setCompressionMetadata(input.Metadata, cli.compression, length)
+	if seeker, ok := input.Body.(io.ReadSeeker); ok && psk != nil {
+		digest, err := crypto.SeekableDigest(seeker, psk)
+		if err != nil {
+			return nil, fmt.Errorf("error calculating content digest: %w", err)
+		}
+		input.Metadata[crypto.DigestMetadataKey] = digest
+	}
+	input.ContentLength = nil
+
+	r := compressReader(cli.compression, input.Body)
+	input.Body = r
+	return r, nil
+}
+
+// compressReader returns a reader that streams the content of the provided reader compressed with the provided codec
+func compressReader(codec Compression, r io.Reader) *io.PipeReader {
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := newCompressor(codec, pw)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(w.Close())
+	}()
+	return pr
+}
+
+// newCompressor returns a writer that compresses its content into the provided writer with the provided codec
+func newCompressor(codec Compression, w io.Writer) (io.WriteCloser, error) {
+	switch codec {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("unsupported compression codec: %s", codec)
+}
+
+// decompressReader returns a reader that decompresses the provided object body with the codec recorded in its metadata.
+// The body is returned unchanged if the object is not compressed.
+func decompressReader(body io.ReadCloser, metadata map[string]string) (io.ReadCloser, error) {
+	codec, ok := crypto.MetadataValue(metadata, CompressionMetadataKey)
+	if !ok {
+		return body, nil
+	}
+
+	switch Compression(codec) {
+	case CompressionNone:
+		return body, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(body)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		return &decompressingReader{r: r, body: body, close: r.Close}, nil
+	case CompressionZstd:
+		r, err := zstd.NewReader(body)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		return &decompressingReader{r: r, body: body, close: func() error { r.Close(); return nil }}, nil
+	}
+
+	body.Close()
+	return nil, fmt.Errorf("unsupported compression codec: %s", codec)
+}
+
+// decompressingReader reads decompressed content, closing both the decompressor and the underlying body when closed
+type decompressingReader struct {
+	r     io.Reader
+	body  io.Closer
+	close func() error
+}
+
+func (r *decompressingReader) Read(b []byte) (int, error) {
+	return r.r.Read(b)
+}
+
+func (r *decompressingReader) Close() error {
+	err := r.close()
+	if bodyErr := r.body.Close(); bodyErr != nil {
+		return bodyErr
+	}
+	return err
+}
+
+// setCompressionMetadata records the codec and the uncompressed length (if not nil) in the provided object metadata.
+// The provided map is returned, or a new one if it was nil.
+func setCompressionMetadata(metadata map[string]string, codec Compression, length *int64) map[string]string {
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata[CompressionMetadataKey] = string(codec)
+	if length != nil {
+		metadata[UncompressedLengthMetadataKey] = strconv.FormatInt(*length, 10)
+	}
+	return metadata
+}
+
+// contentLength returns the length of the content once decompressed:
+// the provided content length for uncompressed objects, the length recorded in the metadata for compressed ones,
+// or nil if the object is compressed and its uncompressed length is unknown.
+func contentLength(metadata map[string]string, length *int64) *int64 {
+	codec, ok := crypto.MetadataValue(metadata, CompressionMetadataKey)
+	if !ok || Compression(codec) == CompressionNone {
+		return length
+	}
+
+	value, ok := crypto.MetadataValue(metadata, UncompressedLengthMetadataKey)
+	if !ok {
+		return nil
+	}
+	l, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &l
+}
+
+// remainingLength returns the number of bytes between the current position of the provided seeker and its end
+func remainingLength(s io.Seeker) (int64, error) {
+	current, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.Seek(current, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return end - current, nil
+}
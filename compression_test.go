@@ -0,0 +1,218 @@
+package s3_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/klauspost/compress/zstd"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testCSV = []byte(strings.Repeat("time,geography,value\n2024,K02000001,123.4\n", 100))
+
+func TestUploadWithCompression(t *testing.T) {
+	Convey("Given a client with a gzip compression codec and a successful SDK uploader", t, func() {
+		ctx := context.Background()
+
+		var uploaded []byte
+		sdkUploaderMock := &mock.S3SDKUploaderMock{
+			UploadFunc: func(ctx context.Context, in *s3.PutObjectInput, options ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+				b, err := io.ReadAll(in.Body)
+				uploaded = b
+				return &manager.UploadOutput{}, err
+			},
+		}
+		cli := dps3.InstantiateClient(nil, nil, sdkUploaderMock, nil, testBucket, ExpectedRegion, aws.Config{}).
+			WithCompression(dps3.CompressionGzip)
+
+		Convey("Calling Upload with a seekable body uploads the gzipped content and records the codec and uncompressed length", func() {
+			_, err := cli.Upload(ctx, &s3.PutObjectInput{Key: &testS3Key, Body: bytes.NewReader(testCSV)})
+			So(err, ShouldBeNil)
+			So(len(sdkUploaderMock.UploadCalls()), ShouldEqual, 1)
+			So(sdkUploaderMock.UploadCalls()[0].In.Metadata, ShouldResemble, map[string]string{
+				dps3.CompressionMetadataKey:        "gzip",
+				dps3.UncompressedLengthMetadataKey: "4200",
+			})
+			So(sdkUploaderMock.UploadCalls()[0].In.ContentLength, ShouldBeNil)
+			So(len(uploaded), ShouldBeLessThan, len(testCSV))
+
+			r, err := gzip.NewReader(bytes.NewReader(uploaded))
+			So(err, ShouldBeNil)
+			b, err := io.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(b, ShouldResemble, testCSV)
+		})
+
+		Convey("Calling Upload with a non-seekable body does not record the uncompressed length", func() {
+			_, err := cli.Upload(ctx, &s3.PutObjectInput{Key: &testS3Key, Body: io.MultiReader(bytes.NewReader(testCSV))})
+			So(err, ShouldBeNil)
+			So(sdkUploaderMock.UploadCalls()[0].In.Metadata, ShouldResemble, map[string]string{
+				dps3.CompressionMetadataKey: "gzip",
+			})
+		})
+
+		Convey("The original client does not compress uploads", func() {
+			original := dps3.InstantiateClient(nil, nil, sdkUploaderMock, nil, testBucket, ExpectedRegion, aws.Config{})
+			So(original.Compression(), ShouldEqual, dps3.CompressionNone)
+			_, err := original.Upload(ctx, &s3.PutObjectInput{Key: &testS3Key, Body: bytes.NewReader(testCSV)})
+			So(err, ShouldBeNil)
+			So(sdkUploaderMock.UploadCalls()[0].In.Metadata, ShouldBeNil)
+			So(uploaded, ShouldResemble, testCSV)
+		})
+	})
+
+	Convey("Given a client with an unsupported compression codec", t, func() {
+		sdkUploaderMock := &mock.S3SDKUploaderMock{}
+		cli := dps3.InstantiateClient(nil, nil, sdkUploaderMock, nil, testBucket, ExpectedRegion, aws.Config{}).
+			WithCompression("lzma")
+
+		Convey("Calling Upload fails without uploading anything", func() {
+			_, err := cli.Upload(context.Background(), &s3.PutObjectInput{Key: &testS3Key, Body: bytes.NewReader(testCSV)})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "compression error for Upload: unsupported compression codec: lzma")
+			So(len(sdkUploaderMock.UploadCalls()), ShouldEqual, 0)
+		})
+	})
+
+	Convey("Given a client with a zstd compression codec and a successful crypto client", t, func() {
+		ctx := context.Background()
+		psk := []byte("test psk")
+		objKey := "my/object/key"
+
+		var encrypted []byte
+		cryptoMock := &mock.S3CryptoClientMock{
+			PutObjectWithPSKFunc: func(ctx context.Context, in *s3.PutObjectInput, psk []byte) (*s3.PutObjectOutput, error) {
+				b, err := io.ReadAll(in.Body)
+				encrypted = b
+				return &s3.PutObjectOutput{}, err
+			},
+		}
+		cli := dps3.InstantiateClient(nil, cryptoMock, nil, nil, testBucket, ExpectedRegion, aws.Config{}).
+			WithCompression(dps3.CompressionZstd)
+
+		Convey("PutWithPSK passes the compressed content to the crypto client and records the codec, uncompressed length and digest of the uncompressed content", func() {
+			err := cli.PutWithPSK(ctx, &objKey, bytes.NewReader(testCSV), psk)
+			So(err, ShouldBeNil)
+			So(cryptoMock.PutObjectWithPSKCalls()[0].In.Metadata, ShouldResemble, map[string]string{
+				dps3.CompressionMetadataKey:        "zstd",
+				dps3.UncompressedLengthMetadataKey: "4200",
+				crypto.DigestMetadataKey:           pskDigest(psk, testCSV),
+			})
+
+			d, err := zstd.NewReader(bytes.NewReader(encrypted))
+			So(err, ShouldBeNil)
+			defer d.Close()
+			b, err := io.ReadAll(d)
+			So(err, ShouldBeNil)
+			So(b, ShouldResemble, testCSV)
+		})
+	})
+}
+
+func TestGetCompressed(t *testing.T) {
+	Convey("Given an S3 client that obtains a gzipped object with its uncompressed length in the metadata", t, func() {
+		ctx := context.Background()
+
+		var compressed bytes.Buffer
+		w := gzip.NewWriter(&compressed)
+		w.Write(testCSV)
+		w.Close()
+		compressedLen := int64(compressed.Len())
+
+		metadata := map[string]string{
+			dps3.CompressionMetadataKey:        "gzip",
+			dps3.UncompressedLengthMetadataKey: "4200",
+		}
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:          io.NopCloser(bytes.NewReader(compressed.Bytes())),
+					ContentLength: &compressedLen,
+					Metadata:      metadata,
+				}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Get returns the decompressed content and its uncompressed length", func() {
+			ret, cLen, err := cli.Get(ctx, testS3Key)
+			So(err, ShouldBeNil)
+			So(*cLen, ShouldEqual, len(testCSV))
+			So(readBytes(ret), ShouldResemble, testCSV)
+		})
+
+		Convey("Get returns a nil length if the uncompressed length was not recorded", func() {
+			delete(metadata, dps3.UncompressedLengthMetadataKey)
+			ret, cLen, err := cli.Get(ctx, testS3Key)
+			So(err, ShouldBeNil)
+			So(cLen, ShouldBeNil)
+			So(readBytes(ret), ShouldResemble, testCSV)
+		})
+	})
+
+	Convey("Given an S3 client that obtains a zstd compressed object with a psk", t, func() {
+		ctx := context.Background()
+
+		e, err := zstd.NewWriter(nil)
+		So(err, ShouldBeNil)
+		compressed := e.EncodeAll(testCSV, nil)
+
+		psk := []byte("test psk")
+		metadata := map[string]string{"Compression": "zstd", "Uncompressedlength": "4200", "Pskhmacsha256": pskDigest(psk, testCSV)}
+		cryptoMock := &mock.S3CryptoClientMock{
+			GetObjectWithPSKFunc: func(ctx context.Context, input *s3.GetObjectInput, inPsk []byte) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:     io.NopCloser(bytes.NewReader(compressed)),
+					Metadata: metadata,
+				}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(nil, cryptoMock, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetWithPSK returns the decompressed content and its uncompressed length, verified against the digest of the uncompressed content", func() {
+			ret, cLen, err := cli.GetWithPSK(ctx, testS3Key, psk)
+			So(err, ShouldBeNil)
+			So(*cLen, ShouldEqual, len(testCSV))
+			b, err := io.ReadAll(ret)
+			So(err, ShouldBeNil)
+			So(b, ShouldResemble, testCSV)
+		})
+
+		Convey("GetWithPSK fails with ErrIntegrity at the end of the stream if the uncompressed content does not match the digest", func() {
+			metadata["Pskhmacsha256"] = pskDigest(psk, compressed)
+			ret, _, err := cli.GetWithPSK(ctx, testS3Key, psk)
+			So(err, ShouldBeNil)
+			_, err = io.ReadAll(ret)
+			So(errors.Is(err, crypto.ErrIntegrity), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an S3 client that obtains an object compressed with an unsupported codec", t, func() {
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:     io.NopCloser(bytes.NewReader(testCSV)),
+					Metadata: map[string]string{dps3.CompressionMetadataKey: "lzma"},
+				}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Get returns an error", func() {
+			_, _, err := cli.Get(context.Background(), testS3Key)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "error decompressing object from s3: unsupported compression codec: lzma")
+		})
+	})
+}
@@ -0,0 +1,506 @@
+// file: copy.go
+//
+// Contains methods to copy and move objects server-side into the bucket configured for the client,
+// from the same bucket or another one, without downloading and uploading their content.
+// Objects larger than 5 GB, the limit of CopyObject, are copied in parts in parallel.
+//
+// Requires "s3:GetObject" and "s3:GetObjectTagging" actions allowed by IAM policy for source objects,
+// "s3:PutObject" and "s3:PutObjectTagging" for destination objects, and "s3:DeleteObject" for moved source objects.
+// Locking destination objects also requires "s3:PutObjectRetention" and "s3:PutObjectLegalHold".
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// MaxCopyObjectSize is the largest object size S3 can copy with a single CopyObject request (5 GB)
+	MaxCopyObjectSize = 5 * 1024 * 1024 * 1024
+
+	// DefaultCopyPartSize is the size of the parts of multipart copies if none is provided (512 MB)
+	DefaultCopyPartSize = 512 * 1024 * 1024
+
+	// DefaultCopyConcurrency is the number of parts copied in parallel if no concurrency is provided
+	DefaultCopyConcurrency = 5
+
+	// minPartSize and maxParts are the limits of S3 for the parts of multipart uploads
+	minPartSize = 5 * 1024 * 1024
+	maxParts    = 10000
+)
+
+// libraryMetadataKeys are the metadata keys this library needs to read objects, which are kept when their metadata is replaced
+var libraryMetadataKeys = []string{
+	CompressionMetadataKey,
+	UncompressedLengthMetadataKey,
+	crypto.FormatMetadataKey,
+	crypto.ChunkSizeMetadataKey,
+	crypto.KeyCheckMetadataKey,
+	crypto.DigestMetadataKey,
+}
+
+// CopyOptions represents the optional configuration of a copy
+type CopyOptions struct {
+	// SourceBucket is the bucket of the source object. If it is empty, the bucket configured for the client is used.
+	SourceBucket string
+
+	// SourceVersionID is the version of the source object to copy. If it is empty, the latest version is copied.
+	SourceVersionID string
+
+	// MetadataDirective defines whether the metadata of the source object is copied (types.MetadataDirectiveCopy, the default),
+	// or replaced with Metadata and ContentType (types.MetadataDirectiveReplace).
+	// The metadata this library needs to decompress and decrypt objects is always kept.
+	MetadataDirective types.MetadataDirective
+	Metadata          map[string]string
+	ContentType       string
+
+	// Tags replace the tags of the source object, if they are not nil
+	Tags map[string]string
+
+	// StorageClass, ServerSideEncryption and SSEKMSKeyID configure how the destination object is stored.
+	// If they are empty, the defaults of the destination bucket are used.
+	StorageClass         types.StorageClass
+	ServerSideEncryption types.ServerSideEncryption
+	SSEKMSKeyID          string
+
+	// Retention and LegalHold lock the destination object version with Object Lock, if they are set
+	Retention *Retention
+	LegalHold bool
+
+	// MultipartThreshold is the object size above which the copy is done in parts.
+	// If it is zero, or larger than MaxCopyObjectSize, MaxCopyObjectSize is used.
+	MultipartThreshold int64
+
+	// PartSize is the size in bytes of each part of multipart copies. If it is zero, DefaultCopyPartSize is used.
+	// It is increased if needed to respect the limits of S3 (5 MB minimum, 10000 parts maximum).
+	PartSize int64
+
+	// Concurrency is the number of parts copied in parallel. If it is zero, DefaultCopyConcurrency is used.
+	Concurrency int
+}
+
+// CopyResult represents the object created by a copy
+type CopyResult struct {
+	Key       string
+	ETag      string
+	VersionID string
+	Size      int64
+}
+
+// Copy copies the object for the given source key to the given destination key (inside the bucket configured for this client).
+// The source object may be in another bucket, provided in the options. Its ETag is used as a precondition for the copy,
+// so that an ErrPreconditionFailed error is returned if it changes while it is copied.
+func (cli *Client) Copy(ctx context.Context, srcKey, dstKey string, opts CopyOptions) (*CopyResult, error) {
+	result, _, err := cli.copy(ctx, srcKey, dstKey, opts)
+	return result, err
+}
+
+// CopyFromURL copies the object for the given S3 URL, in the format specified by URLStyle,
+// to the given destination key (inside the bucket configured for this client), like Copy does.
+// The bucket of the URL overrides the source bucket of the options.
+func (cli *Client) CopyFromURL(ctx context.Context, srcURL string, style URLStyle, dstKey string, opts CopyOptions) (*CopyResult, error) {
+	s3Url, err := ParseURL(srcURL, style)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error parsing url: %w", err), log.Data{
+			"raw_url":   srcURL,
+			"url_style": style.String(),
+		})
+	}
+
+	opts.SourceBucket = s3Url.BucketName
+	return cli.Copy(ctx, s3Url.Key, dstKey, opts)
+}
+
+// Move copies the object for the given source key to the given destination key like Copy does,
+// and then deletes the source object, once it has verified that the destination object has the size of the source one,
+// and its ETag if both are MD5 checksums. If the verification fails, an ErrCopyVerification error is returned and the source is kept.
+// If a source version is provided, that version is permanently deleted.
+// Moving an object onto itself is rejected, as the copy would be verified and then deleted.
+func (cli *Client) Move(ctx context.Context, srcKey, dstKey string, opts CopyOptions) (*CopyResult, error) {
+	srcBucket := cli.sourceBucket(opts)
+	logData := log.Data{
+		"bucket_name":     cli.bucketName,
+		"source_bucket":   srcBucket,
+		"source_key":      srcKey,
+		"destination_key": dstKey,
+	}
+
+	if srcBucket == cli.bucketName && srcKey == dstKey {
+		return nil, NewError(errors.New("cannot move an object onto itself"), logData)
+	}
+
+	result, src, err := cli.copy(ctx, srcKey, dstKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	logData["source_etag"] = aws.ToString(src.ETag)
+	logData["etag"] = result.ETag
+
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(dstKey),
+	}
+	if result.VersionID != "" {
+		input.VersionId = aws.String(result.VersionID)
+	}
+	dst, err := cli.sdkClient.HeadObject(ctx, input)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error getting metadata of copied object from s3: %w", err), logData)
+	}
+
+	if size := aws.ToInt64(dst.ContentLength); size != result.Size {
+		return nil, NewCopyVerificationError(fmt.Errorf("expected size %d but copied object has size %d", result.Size, size), logData)
+	}
+	if isMD5ETag(src) && isMD5ETag(dst) && aws.ToString(src.ETag) != aws.ToString(dst.ETag) {
+		return nil, NewCopyVerificationError(fmt.Errorf("expected etag %s but copied object has etag %s", aws.ToString(src.ETag), aws.ToString(dst.ETag)), logData)
+	}
+
+	deleteInput := &s3.DeleteObjectInput{
+		Bucket: aws.String(srcBucket),
+		Key:    aws.String(srcKey),
+	}
+	if opts.SourceVersionID != "" {
+		deleteInput.VersionId = aws.String(opts.SourceVersionID)
+	}
+	if _, err := cli.sdkClient.DeleteObject(ctx, deleteInput); err != nil {
+		return nil, lockError(fmt.Errorf("error deleting source object from s3 after copying it: %w", err), logData)
+	}
+	return result, nil
+}
+
+// copy copies the source object to the destination key, with a single request or in parts depending on its size,
+// returning the result along with the metadata of the source object
+func (cli *Client) copy(ctx context.Context, srcKey, dstKey string, opts CopyOptions) (*CopyResult, *s3.HeadObjectOutput, error) {
+	srcBucket := cli.sourceBucket(opts)
+	logData := log.Data{
+		"bucket_name":     cli.bucketName,
+		"source_bucket":   srcBucket,
+		"source_key":      srcKey,
+		"destination_key": dstKey,
+	}
+	if opts.SourceVersionID != "" {
+		logData["source_version_id"] = opts.SourceVersionID
+	}
+
+	if err := ValidateTags(opts.Tags); err != nil {
+		return nil, nil, NewInvalidTagsError(err, logData)
+	}
+	if err := validateObjectLock(opts.Retention); err != nil {
+		return nil, nil, NewError(err, logData)
+	}
+
+	headInput := &s3.HeadObjectInput{
+		Bucket: aws.String(srcBucket),
+		Key:    aws.String(srcKey),
+	}
+	if opts.SourceVersionID != "" {
+		headInput.VersionId = aws.String(opts.SourceVersionID)
+	}
+	src, err := cli.sdkClient.HeadObject(ctx, headInput)
+	if err != nil {
+		return nil, nil, NewError(fmt.Errorf("error getting metadata of source object from s3: %w", err), logData)
+	}
+
+	size := aws.ToInt64(src.ContentLength)
+	logData["size"] = size
+
+	threshold := opts.MultipartThreshold
+	if threshold <= 0 || threshold > MaxCopyObjectSize {
+		threshold = MaxCopyObjectSize
+	}
+
+	var result *CopyResult
+	if size > threshold {
+		result, err = cli.copyParts(ctx, srcBucket, srcKey, dstKey, src, opts, logData)
+	} else {
+		result, err = cli.copyObject(ctx, srcBucket, srcKey, dstKey, src, opts, logData)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, src, nil
+}
+
+// copyObject copies the source object with a single CopyObject request
+func (cli *Client) copyObject(ctx context.Context, srcBucket, srcKey, dstKey string, src *s3.HeadObjectOutput, opts CopyOptions, logData log.Data) (*CopyResult, error) {
+	input := &s3.CopyObjectInput{
+		Bucket:               aws.String(cli.bucketName),
+		Key:                  aws.String(dstKey),
+		CopySource:           aws.String(copySource(srcBucket, srcKey, opts.SourceVersionID)),
+		CopySourceIfMatch:    src.ETag,
+		MetadataDirective:    types.MetadataDirectiveCopy,
+		StorageClass:         opts.StorageClass,
+		ServerSideEncryption: opts.ServerSideEncryption,
+	}
+	if opts.MetadataDirective == types.MetadataDirectiveReplace {
+		input.MetadataDirective = types.MetadataDirectiveReplace
+		input.Metadata = replacedMetadata(src.Metadata, opts.Metadata)
+		if opts.ContentType != "" {
+			input.ContentType = aws.String(opts.ContentType)
+		}
+	}
+	if opts.Tags != nil {
+		input.TaggingDirective = types.TaggingDirectiveReplace
+		input.Tagging = aws.String(EncodeTags(opts.Tags))
+	}
+	if opts.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(opts.SSEKMSKeyID)
+	}
+	if opts.Retention != nil {
+		input.ObjectLockMode = opts.Retention.Mode
+		input.ObjectLockRetainUntilDate = aws.Time(opts.Retention.RetainUntil)
+	}
+	if opts.LegalHold {
+		input.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+	}
+
+	out, err := cli.sdkClient.CopyObject(ctx, input)
+	if err != nil {
+		err = fmt.Errorf("error copying object in s3: %w", err)
+		if isPreconditionFailed(err) {
+			return nil, NewPreconditionFailedError(err, logData)
+		}
+		return nil, lockError(err, logData)
+	}
+
+	result := &CopyResult{
+		Key:       dstKey,
+		VersionID: aws.ToString(out.VersionId),
+		Size:      aws.ToInt64(src.ContentLength),
+	}
+	if out.CopyObjectResult != nil {
+		result.ETag = aws.ToString(out.CopyObjectResult.ETag)
+	}
+	return result, nil
+}
+
+// copyParts copies the source object with a multipart upload whose parts are copied from ranges of the source object in parallel.
+// As UploadPartCopy does not copy metadata or tags, they are set from the source object (or the options) when the upload is created.
+// The upload is aborted if any part fails.
+func (cli *Client) copyParts(ctx context.Context, srcBucket, srcKey, dstKey string, src *s3.HeadObjectOutput, opts CopyOptions, logData log.Data) (*CopyResult, error) {
+	size := aws.ToInt64(src.ContentLength)
+
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(cli.bucketName),
+		Key:                  aws.String(dstKey),
+		Metadata:             src.Metadata,
+		ContentType:          src.ContentType,
+		ContentEncoding:      src.ContentEncoding,
+		ContentDisposition:   src.ContentDisposition,
+		ContentLanguage:      src.ContentLanguage,
+		CacheControl:         src.CacheControl,
+		Expires:              src.Expires,
+		StorageClass:         opts.StorageClass,
+		ServerSideEncryption: opts.ServerSideEncryption,
+	}
+	if opts.MetadataDirective == types.MetadataDirectiveReplace {
+		createInput.Metadata = replacedMetadata(src.Metadata, opts.Metadata)
+		createInput.ContentType = nil
+		if opts.ContentType != "" {
+			createInput.ContentType = aws.String(opts.ContentType)
+		}
+	}
+	if opts.SSEKMSKeyID != "" {
+		createInput.SSEKMSKeyId = aws.String(opts.SSEKMSKeyID)
+	}
+	if opts.Retention != nil {
+		createInput.ObjectLockMode = opts.Retention.Mode
+		createInput.ObjectLockRetainUntilDate = aws.Time(opts.Retention.RetainUntil)
+	}
+	if opts.LegalHold {
+		createInput.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+	}
+
+	tags := opts.Tags
+	if tags == nil {
+		tagsInput := &s3.GetObjectTaggingInput{
+			Bucket: aws.String(srcBucket),
+			Key:    aws.String(srcKey),
+		}
+		if opts.SourceVersionID != "" {
+			tagsInput.VersionId = aws.String(opts.SourceVersionID)
+		}
+		out, err := cli.sdkClient.GetObjectTagging(ctx, tagsInput)
+		if err != nil {
+			return nil, NewError(fmt.Errorf("error getting tags of source object from s3: %w", err), logData)
+		}
+		tags = tagMap(out.TagSet)
+	}
+	if len(tags) > 0 {
+		createInput.Tagging = aws.String(EncodeTags(tags))
+	}
+
+	created, err := cli.sdkClient.CreateMultipartUpload(ctx, createInput)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error creating multipart upload for copy in s3: %w", err), logData)
+	}
+	uploadID := created.UploadId
+	logData["upload_id"] = aws.ToString(uploadID)
+
+	parts, err := cli.copyPartRanges(ctx, uploadID, srcBucket, srcKey, dstKey, src, opts)
+	if err != nil {
+		err = errors.Join(fmt.Errorf("error copying object parts in s3: %w", err), cli.abortMultipartUpload(ctx, dstKey, uploadID))
+		if isPreconditionFailed(err) {
+			return nil, NewPreconditionFailedError(err, logData)
+		}
+		return nil, NewError(err, logData)
+	}
+
+	out, err := cli.sdkClient.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(cli.bucketName),
+		Key:             aws.String(dstKey),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return nil, lockError(fmt.Errorf("error completing multipart upload for copy in s3: %w", err), logData)
+	}
+
+	return &CopyResult{
+		Key:       dstKey,
+		ETag:      aws.ToString(out.ETag),
+		VersionID: aws.ToString(out.VersionId),
+		Size:      size,
+	}, nil
+}
+
+// copyPartRanges copies the ranges of the source object as parts of the provided upload, in parallel,
+// returning the completed parts in order, or the first error
+func (cli *Client) copyPartRanges(ctx context.Context, uploadID *string, srcBucket, srcKey, dstKey string, src *s3.HeadObjectOutput, opts CopyOptions) ([]types.CompletedPart, error) {
+	size := aws.ToInt64(src.ContentLength)
+	partSize := copyPartSize(size, opts.PartSize)
+	numParts := int((size + partSize - 1) / partSize)
+
+	return completeParts(ctx, numParts, opts.Concurrency, func(ctx context.Context, n int) (*string, error) {
+		start := int64(n-1) * partSize
+		end := min(start+partSize, size) - 1
+		out, err := cli.sdkClient.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:            aws.String(cli.bucketName),
+			Key:               aws.String(dstKey),
+			UploadId:          uploadID,
+			PartNumber:        aws.Int32(int32(n)),
+			CopySource:        aws.String(copySource(srcBucket, srcKey, opts.SourceVersionID)),
+			CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+			CopySourceIfMatch: src.ETag,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out.CopyPartResult.ETag, nil
+	})
+}
+
+// completeParts calls the provided function for each part number of a multipart upload, with the provided concurrency
+// (DefaultCopyConcurrency if it is zero), returning the completed parts in order with the ETags returned by the function.
+// If any part fails, the parts that were not started are skipped and the first error is returned.
+func completeParts(ctx context.Context, numParts, concurrency int, uploadPart func(ctx context.Context, n int) (*string, error)) ([]types.CompletedPart, error) {
+	if concurrency <= 0 {
+		concurrency = DefaultCopyConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := make([]types.CompletedPart, numParts)
+	partNumbers := make(chan int)
+	wg := &sync.WaitGroup{}
+	once := &sync.Once{}
+	var firstErr error
+
+	for i := 0; i < min(concurrency, numParts); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range partNumbers {
+				etag, err := uploadPart(ctx, n)
+				if err != nil {
+					once.Do(func() {
+						firstErr = fmt.Errorf("error uploading part %d: %w", n, err)
+						cancel()
+					})
+					continue
+				}
+				parts[n-1] = types.CompletedPart{
+					PartNumber: aws.Int32(int32(n)),
+					ETag:       etag,
+				}
+			}
+		}()
+	}
+
+	for n := 1; n <= numParts; n++ {
+		if ctx.Err() != nil {
+			break
+		}
+		partNumbers <- n
+	}
+	close(partNumbers)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// abortMultipartUpload aborts the provided multipart upload, even if the context was cancelled,
+// so that the parts that were already uploaded are not kept (and billed)
+func (cli *Client) abortMultipartUpload(ctx context.Context, key string, uploadID *string) error {
+	_, err := cli.sdkClient.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(cli.bucketName),
+		Key:      aws.String(key),
+		UploadId: uploadID,
+	})
+	if err != nil {
+		return fmt.Errorf("error aborting multipart upload: %w", err)
+	}
+	return nil
+}
+
+// copyPartSize returns the part size to copy an object of the provided size, which is the requested one (or the default),
+// increased if needed to respect the limits of S3 for the size and number of parts
+func copyPartSize(size, partSize int64) int64 {
+	if partSize <= 0 {
+		partSize = DefaultCopyPartSize
+	}
+	partSize = max(partSize, minPartSize)
+	if minSize := (size + maxParts - 1) / maxParts; partSize < minSize {
+		partSize = minSize
+	}
+	return partSize
+}
+
+// sourceBucket returns the bucket of the source object of a copy with the provided options
+func (cli *Client) sourceBucket(opts CopyOptions) string {
+	if opts.SourceBucket != "" {
+		return opts.SourceBucket
+	}
+	return cli.bucketName
+}
+
+// replacedMetadata returns the provided metadata, with the library metadata of the source object,
+// so that the copied object can still be decompressed and decrypted
+func replacedMetadata(source, metadata map[string]string) map[string]string {
+	replaced := maps.Clone(metadata)
+	if replaced == nil {
+		replaced = make(map[string]string)
+	}
+	for _, key := range libraryMetadataKeys {
+		if value, ok := crypto.MetadataValue(source, key); ok {
+			replaced[key] = value
+		}
+	}
+	return replaced
+}
@@ -0,0 +1,313 @@
+package s3_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	copySrcETag = `"0123456789abcdef0123456789abcdef"`
+	copyDstKey  = "dst/file.csv"
+)
+
+// copySrcExpires is the expiry date of the source object returned by newCopyMock
+var copySrcExpires = time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// newCopyMock returns an S3 client mock for a source object of the provided size, whose copies succeed
+// and report the provided size and etag for the destination object
+func newCopyMock(size int64, dstSize int64, dstETag string) *mock.S3SDKClientMock {
+	return &mock.S3SDKClientMock{
+		HeadObjectFunc: func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			if *in.Key == copyDstKey {
+				return &s3.HeadObjectOutput{ContentLength: aws.Int64(dstSize), ETag: aws.String(dstETag)}, nil
+			}
+			return &s3.HeadObjectOutput{
+				ContentLength:   aws.Int64(size),
+				ETag:            aws.String(copySrcETag),
+				ContentType:     aws.String("text/csv"),
+				ContentLanguage: aws.String("en-GB"),
+				CacheControl:    aws.String("max-age=3600"),
+				Expires:         aws.Time(copySrcExpires),
+				Metadata: map[string]string{
+					"owner":                     "team-a",
+					dps3.CompressionMetadataKey: string(dps3.CompressionGzip),
+					crypto.KeyCheckMetadataKey:  "kcv",
+				},
+			}, nil
+		},
+		CopyObjectFunc: func(ctx context.Context, in *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			return &s3.CopyObjectOutput{
+				CopyObjectResult: &types.CopyObjectResult{ETag: aws.String(dstETag)},
+				VersionId:        aws.String("v2"),
+			}, nil
+		},
+		GetObjectTaggingFunc: func(ctx context.Context, in *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+			return &s3.GetObjectTaggingOutput{TagSet: []types.Tag{{Key: aws.String("classification"), Value: aws.String("public")}}}, nil
+		},
+		CreateMultipartUploadFunc: func(ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+		},
+		UploadPartCopyFunc: func(ctx context.Context, in *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+			return &s3.UploadPartCopyOutput{CopyPartResult: &types.CopyPartResult{ETag: aws.String(*in.CopySourceRange)}}, nil
+		},
+		CompleteMultipartUploadFunc: func(ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+			return &s3.CompleteMultipartUploadOutput{ETag: aws.String(dstETag), VersionId: aws.String("v3")}, nil
+		},
+		AbortMultipartUploadFunc: func(ctx context.Context, in *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+			return &s3.AbortMultipartUploadOutput{}, nil
+		},
+		DeleteObjectFunc: func(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+			return &s3.DeleteObjectOutput{}, nil
+		},
+	}
+}
+
+func TestCopy(t *testing.T) {
+	Convey("Given an S3 client and a source object smaller than 5 GB", t, func() {
+		ctx := context.Background()
+		sdkMock := newCopyMock(1024, 1024, copySrcETag)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Copy copies it with a single CopyObject request, pinned to the source ETag, copying its metadata", func() {
+			result, err := cli.Copy(ctx, "src/my file.csv", copyDstKey, dps3.CopyOptions{})
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, &dps3.CopyResult{Key: copyDstKey, ETag: copySrcETag, VersionID: "v2", Size: 1024})
+
+			So(sdkMock.CopyObjectCalls(), ShouldHaveLength, 1)
+			in := sdkMock.CopyObjectCalls()[0].In
+			So(*in.Bucket, ShouldEqual, ExistingBucket)
+			So(*in.Key, ShouldEqual, copyDstKey)
+			So(*in.CopySource, ShouldEqual, ExistingBucket+"/src/my%20file.csv")
+			So(*in.CopySourceIfMatch, ShouldEqual, copySrcETag)
+			So(in.MetadataDirective, ShouldEqual, types.MetadataDirectiveCopy)
+			So(in.Metadata, ShouldBeNil)
+			So(in.Tagging, ShouldBeNil)
+			So(sdkMock.UploadPartCopyCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Copy replaces the metadata and tags, and sets the storage options, if requested", func() {
+			_, err := cli.Copy(ctx, "src/file.csv", copyDstKey, dps3.CopyOptions{
+				SourceVersionID:      "v1",
+				MetadataDirective:    types.MetadataDirectiveReplace,
+				Metadata:             map[string]string{"owner": "team-b"},
+				ContentType:          "application/csv",
+				Tags:                 map[string]string{"stage": "published", "team": "b&c"},
+				StorageClass:         types.StorageClassStandardIa,
+				ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+				SSEKMSKeyID:          "key-1",
+			})
+			So(err, ShouldBeNil)
+
+			So(*sdkMock.HeadObjectCalls()[0].In.VersionId, ShouldEqual, "v1")
+			in := sdkMock.CopyObjectCalls()[0].In
+			So(*in.CopySource, ShouldEqual, ExistingBucket+"/src/file.csv?versionId=v1")
+			So(in.MetadataDirective, ShouldEqual, types.MetadataDirectiveReplace)
+			So(in.Metadata, ShouldResemble, map[string]string{
+				"owner":                     "team-b",
+				dps3.CompressionMetadataKey: string(dps3.CompressionGzip),
+				crypto.KeyCheckMetadataKey:  "kcv",
+			})
+			So(*in.ContentType, ShouldEqual, "application/csv")
+			So(in.TaggingDirective, ShouldEqual, types.TaggingDirectiveReplace)
+			So(*in.Tagging, ShouldEqual, "stage=published&team=b%26c")
+			So(in.StorageClass, ShouldEqual, types.StorageClassStandardIa)
+			So(in.ServerSideEncryption, ShouldEqual, types.ServerSideEncryptionAwsKms)
+			So(*in.SSEKMSKeyId, ShouldEqual, "key-1")
+		})
+
+		Convey("CopyFromURL copies the object of the URL, from its bucket", func() {
+			_, err := cli.CopyFromURL(ctx, "https://s3-eu-west-1.amazonaws.com/other-bucket/src/file.csv", dps3.PathStyle, copyDstKey, dps3.CopyOptions{})
+			So(err, ShouldBeNil)
+			So(*sdkMock.HeadObjectCalls()[0].In.Bucket, ShouldEqual, "other-bucket")
+			So(*sdkMock.CopyObjectCalls()[0].In.CopySource, ShouldEqual, "other-bucket/src/file.csv")
+			So(*sdkMock.CopyObjectCalls()[0].In.Bucket, ShouldEqual, ExistingBucket)
+		})
+
+		Convey("Copy fails with ErrPreconditionFailed if the source object changes while it is copied", func() {
+			sdkMock.CopyObjectFunc = func(ctx context.Context, in *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				return nil, newResponseError(http.StatusPreconditionFailed, "PreconditionFailed")
+			}
+			_, err := cli.Copy(ctx, "src/file.csv", copyDstKey, dps3.CopyOptions{})
+			var errPrecondition *dps3.ErrPreconditionFailed
+			So(errors.As(err, &errPrecondition), ShouldBeTrue)
+		})
+
+		Convey("Copy fails without copying if the source object does not exist", func() {
+			sdkMock.HeadObjectFunc = func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return nil, newResponseError(http.StatusNotFound, "NotFound")
+			}
+			_, err := cli.Copy(ctx, "src/file.csv", copyDstKey, dps3.CopyOptions{})
+			So(err, ShouldNotBeNil)
+			So(sdkMock.CopyObjectCalls(), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given an S3 client and a source object larger than the multipart threshold", t, func() {
+		ctx := context.Background()
+		size := int64(12*1024*1024 + 1)
+		sdkMock := newCopyMock(size, size, `"abc-3"`)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+		opts := dps3.CopyOptions{MultipartThreshold: 10 * 1024 * 1024, PartSize: 5 * 1024 * 1024, Concurrency: 2}
+
+		Convey("Copy copies it in parts, creating the upload with the metadata and tags of the source", func() {
+			result, err := cli.Copy(ctx, "src/file.csv", copyDstKey, opts)
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, &dps3.CopyResult{Key: copyDstKey, ETag: `"abc-3"`, VersionID: "v3", Size: size})
+			So(sdkMock.CopyObjectCalls(), ShouldBeEmpty)
+
+			create := sdkMock.CreateMultipartUploadCalls()[0].In
+			So(*create.Key, ShouldEqual, copyDstKey)
+			So(create.Metadata["owner"], ShouldEqual, "team-a")
+			So(*create.ContentType, ShouldEqual, "text/csv")
+			So(*create.ContentLanguage, ShouldEqual, "en-GB")
+			So(*create.CacheControl, ShouldEqual, "max-age=3600")
+			So(*create.Expires, ShouldEqual, copySrcExpires)
+			So(*create.Tagging, ShouldEqual, "classification=public")
+
+			ranges := []string{}
+			for _, call := range sdkMock.UploadPartCopyCalls() {
+				So(*call.In.UploadId, ShouldEqual, "upload-1")
+				So(*call.In.CopySource, ShouldEqual, ExistingBucket+"/src/file.csv")
+				So(*call.In.CopySourceIfMatch, ShouldEqual, copySrcETag)
+				ranges = append(ranges, *call.In.CopySourceRange)
+			}
+			sort.Strings(ranges)
+			So(ranges, ShouldResemble, []string{"bytes=0-5242879", "bytes=10485760-12582912", "bytes=5242880-10485759"})
+
+			parts := sdkMock.CompleteMultipartUploadCalls()[0].In.MultipartUpload.Parts
+			So(parts, ShouldHaveLength, 3)
+			for i, part := range parts {
+				So(*part.PartNumber, ShouldEqual, i+1)
+			}
+			So(*parts[2].ETag, ShouldEqual, "bytes=10485760-12582912")
+		})
+
+		Convey("Copy uses the provided tags instead of the ones of the source", func() {
+			opts.Tags = map[string]string{"stage": "published"}
+			_, err := cli.Copy(ctx, "src/file.csv", copyDstKey, opts)
+			So(err, ShouldBeNil)
+			So(sdkMock.GetObjectTaggingCalls(), ShouldBeEmpty)
+			So(*sdkMock.CreateMultipartUploadCalls()[0].In.Tagging, ShouldEqual, "stage=published")
+		})
+
+		Convey("Copy aborts the upload if a part fails", func() {
+			errPart := errors.New("internal error")
+			sdkMock.UploadPartCopyFunc = func(ctx context.Context, in *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+				return nil, errPart
+			}
+			_, err := cli.Copy(ctx, "src/file.csv", copyDstKey, opts)
+			So(errors.Is(err, errPart), ShouldBeTrue)
+			So(sdkMock.AbortMultipartUploadCalls(), ShouldHaveLength, 1)
+			So(*sdkMock.AbortMultipartUploadCalls()[0].In.UploadId, ShouldEqual, "upload-1")
+			So(sdkMock.CompleteMultipartUploadCalls(), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given an S3 client and a source object larger than 5 GB", t, func() {
+		size := int64(6 * 1024 * 1024 * 1024)
+		sdkMock := newCopyMock(size, size, `"abc-12"`)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Copy copies it in parts of the default size", func() {
+			_, err := cli.Copy(context.Background(), "src/file.csv", copyDstKey, dps3.CopyOptions{})
+			So(err, ShouldBeNil)
+			So(sdkMock.CopyObjectCalls(), ShouldBeEmpty)
+			So(sdkMock.UploadPartCopyCalls(), ShouldHaveLength, 12)
+		})
+	})
+}
+
+func TestMove(t *testing.T) {
+	Convey("Given an S3 client and a source object", t, func() {
+		ctx := context.Background()
+
+		Convey("Move copies it and deletes the source once the copy is verified", func() {
+			sdkMock := newCopyMock(1024, 1024, copySrcETag)
+			cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			result, err := cli.Move(ctx, "src/file.csv", copyDstKey, dps3.CopyOptions{})
+			So(err, ShouldBeNil)
+			So(result.Key, ShouldEqual, copyDstKey)
+			So(*sdkMock.HeadObjectCalls()[1].In.Key, ShouldEqual, copyDstKey)
+			So(*sdkMock.HeadObjectCalls()[1].In.VersionId, ShouldEqual, "v2")
+			So(sdkMock.DeleteObjectCalls(), ShouldHaveLength, 1)
+			So(*sdkMock.DeleteObjectCalls()[0].In.Key, ShouldEqual, "src/file.csv")
+			So(sdkMock.DeleteObjectCalls()[0].In.VersionId, ShouldBeNil)
+		})
+
+		Convey("Move deletes the source version that was moved, from the source bucket", func() {
+			sdkMock := newCopyMock(1024, 1024, copySrcETag)
+			cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			_, err := cli.Move(ctx, "src/file.csv", copyDstKey, dps3.CopyOptions{SourceBucket: "other-bucket", SourceVersionID: "v1"})
+			So(err, ShouldBeNil)
+			So(*sdkMock.DeleteObjectCalls()[0].In.Bucket, ShouldEqual, "other-bucket")
+			So(*sdkMock.DeleteObjectCalls()[0].In.VersionId, ShouldEqual, "v1")
+		})
+
+		Convey("Move fails with ErrCopyVerification, keeping the source, if the copy has a different size", func() {
+			sdkMock := newCopyMock(1024, 1000, copySrcETag)
+			cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			_, err := cli.Move(ctx, "src/file.csv", copyDstKey, dps3.CopyOptions{})
+			var errVerification *dps3.ErrCopyVerification
+			So(errors.As(err, &errVerification), ShouldBeTrue)
+			So(sdkMock.DeleteObjectCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Move fails with ErrCopyVerification, keeping the source, if the copy has a different MD5 ETag", func() {
+			sdkMock := newCopyMock(1024, 1024, `"fedcba9876543210fedcba9876543210"`)
+			cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			_, err := cli.Move(ctx, "src/file.csv", copyDstKey, dps3.CopyOptions{})
+			var errVerification *dps3.ErrCopyVerification
+			So(errors.As(err, &errVerification), ShouldBeTrue)
+			So(sdkMock.DeleteObjectCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Move fails without copying or deleting anything if the source and destination are the same object", func() {
+			sdkMock := newCopyMock(1024, 1024, copySrcETag)
+			cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			for _, opts := range []dps3.CopyOptions{{}, {SourceBucket: ExistingBucket, MetadataDirective: types.MetadataDirectiveReplace}} {
+				_, err := cli.Move(ctx, copyDstKey, copyDstKey, opts)
+				var s3Err *dps3.S3Error
+				So(errors.As(err, &s3Err), ShouldBeTrue)
+			}
+			So(sdkMock.HeadObjectCalls(), ShouldBeEmpty)
+			So(sdkMock.CopyObjectCalls(), ShouldBeEmpty)
+			So(sdkMock.DeleteObjectCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Move allows the same key in another source bucket", func() {
+			sdkMock := newCopyMock(1024, 1024, copySrcETag)
+			cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			_, err := cli.Move(ctx, copyDstKey, copyDstKey, dps3.CopyOptions{SourceBucket: "other-bucket"})
+			So(err, ShouldBeNil)
+			So(*sdkMock.DeleteObjectCalls()[0].In.Bucket, ShouldEqual, "other-bucket")
+		})
+
+		Convey("Move does not compare ETags that are not MD5 checksums, like the ones of multipart copies", func() {
+			size := int64(12*1024*1024 + 1)
+			sdkMock := newCopyMock(size, size, `"abc-3"`)
+			cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			_, err := cli.Move(ctx, "src/file.csv", copyDstKey, dps3.CopyOptions{MultipartThreshold: 10 * 1024 * 1024})
+			So(err, ShouldBeNil)
+			So(sdkMock.DeleteObjectCalls(), ShouldHaveLength, 1)
+		})
+	})
+}
@@ -9,13 +9,18 @@ import (
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -26,7 +31,34 @@ import (
 const (
 	encryptionKeyHeader = "Pskencrypted"
 
+	// ChunkSizeMetadataKey is the object metadata key holding the size of the plaintext chunks
+	// that are encrypted independently with a user-defined PSK.
+	ChunkSizeMetadataKey = "pskchunksize"
+
+	// FormatMetadataKey is the object metadata key holding the cipher format of an object encrypted with a user-defined PSK.
+	FormatMetadataKey = "pskformat"
+
+	// KeyCheckMetadataKey is the object metadata key holding the key check value of the PSK used to encrypt the object.
+	KeyCheckMetadataKey = "pskkeycheck"
+
+	// DigestMetadataKey is the object metadata key holding the hex encoded digest of the plaintext object content, calculated by NewDigest.
+	// It is only recorded when the content is known before it is uploaded (see PutObjectWithPSK and UploadWithPSK),
+	// so readers must not expect it; in particular, it is never recorded for multipart uploads of parts.
+	DigestMetadataKey = "pskhmacsha256"
+
+	// FormatAESCFB is the cipher format of objects encrypted with AES in CFB mode, restarting the stream at every chunk.
+	FormatAESCFB = "aes-cfb"
+
+	// keyCheckMessage is the message authenticated with the PSK to obtain its key check value
+	keyCheckMessage = "dp-s3 psk key check"
+
+	// digestKeyMessage is the message authenticated with the PSK to obtain the key of the content digest
+	digestKeyMessage = "dp-s3 psk content digest"
+
 	maxChunkSize = 5 * 1024 * 1024
+
+	// DefaultChunkSize is the chunk size used to encrypt and decrypt content with a user-defined PSK, unless configured otherwise.
+	DefaultChunkSize = maxChunkSize
 )
 
 // ErrNoPrivateKey is returned when an attempt is made to access a method that requires a private key when it has not been provided
@@ -35,6 +67,18 @@ var ErrNoPrivateKey = errors.New("you have not provided a private key and theref
 // ErrNoMetadataPSK is returned when the file you are trying to download is not encrypted
 var ErrNoMetadataPSK = errors.New("no encrypted key found for this file, you are trying to download a file which is not encrypted")
 
+// ErrUnsupportedFormat is returned when the cipher format recorded in the object metadata is not supported by this client
+var ErrUnsupportedFormat = errors.New("unsupported cipher format found in object metadata")
+
+// ErrInvalidChunkSize is returned when the chunk size recorded in the object metadata is not a positive integer
+var ErrInvalidChunkSize = errors.New("invalid chunk size found in object metadata")
+
+// ErrWrongPSK is returned when the PSK provided to decrypt an object does not match the key check value recorded in its metadata
+var ErrWrongPSK = errors.New("the provided psk does not match the one used to encrypt the object")
+
+// ErrIntegrity is returned at the end of the stream when the digest of the plaintext content does not match the one recorded in its metadata
+var ErrIntegrity = errors.New("the object content does not match the digest recorded in its metadata")
+
 // Config represents the configuration items for the
 // CryptoClient
 type Config struct {
@@ -157,6 +201,16 @@ func (r *cryptoReader) Close() error {
 	return r.s3Reader.Close()
 }
 
+// NewDecryptReader returns a reader that decrypts the content of the provided reader with the provided PSK,
+// in chunks of the provided size, which must be the size the content was encrypted with.
+func NewDecryptReader(r io.ReadCloser, psk []byte, chunkSize int) io.ReadCloser {
+	return &cryptoReader{
+		s3Reader:  r,
+		psk:       psk,
+		chunkSize: chunkSize,
+	}
+}
+
 // Uploader provides a wrapper to the aws-sdk-go-v2 manager uploader
 // for encryption
 type Uploader struct {
@@ -182,6 +236,9 @@ func New(awsConfig aws.Config, cfg *Config, optFns ...func(*s3.Options)) *Crypto
 
 // NewUploader creates a new instance of the crypto Uploader
 func NewUploader(awsConfig aws.Config, cfg *Config, optFns ...func(*s3.Options)) *Uploader {
+	if cfg.MultipartChunkSize == 0 {
+		cfg.MultipartChunkSize = maxChunkSize
+	}
 	cc := &CryptoClient{s3.NewFromConfig(awsConfig, optFns...), cfg.PrivateKey, cfg.PublicKey, cfg.HasUserDefinedPSK, cfg.MultipartChunkSize}
 
 	if cc.privKey != nil {
@@ -303,14 +360,25 @@ func (c *CryptoClient) PutObject(ctx context.Context, input *s3.PutObjectInput)
 	return out, nil
 }
 
-// PutObjectRequestWithPSK wraps the SDK method by encrypting the object content with a user defined PSK
+// PutObjectRequestWithPSK wraps the SDK method by encrypting the object content with a user defined PSK.
+// The content is encrypted in chunks of the configured size, which is recorded in the object metadata.
+// The digest of the content is recorded, unless it is provided in the metadata (e.g. by callers that compress the content).
 func (c *CryptoClient) PutObjectWithPSK(ctx context.Context, input *s3.PutObjectInput, psk []byte) (*s3.PutObjectOutput, error) {
-	encryptedContent, err := encryptObjectContent(psk, input.Body)
+	digest := NewDigest(psk)
+	encryptedContent, err := io.ReadAll(&encryptoReader{
+		psk:       psk,
+		s3Reader:  io.TeeReader(input.Body, digest),
+		chunkSize: c.chunkSize,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt content: %w", err)
 	}
 
 	input.Body = bytes.NewReader(encryptedContent)
+	input.Metadata = SetPSKMetadata(input.Metadata, psk, c.chunkSize)
+	if _, ok := input.Metadata[DigestMetadataKey]; !ok {
+		input.Metadata[DigestMetadataKey] = hex.EncodeToString(digest.Sum(nil))
+	}
 
 	out, err := c.s3Client.PutObject(ctx, input)
 	if err != nil {
@@ -351,17 +419,30 @@ func (c *CryptoClient) GetObject(ctx context.Context, input *s3.GetObjectInput)
 	return out, nil
 }
 
-// GetObjectRequestWithPSK wraps the SDK method by decrypting the retrieved object content with the given PSK
+// GetObjectRequestWithPSK wraps the SDK method by decrypting the retrieved object content with the given PSK.
+// The chunk size recorded in the object metadata is used, falling back to the configured size for legacy objects.
+// ErrWrongPSK is returned if the PSK does not match the key check value recorded in the object metadata.
 func (c *CryptoClient) GetObjectWithPSK(ctx context.Context, input *s3.GetObjectInput, psk []byte) (*s3.GetObjectOutput, error) {
 	out, err := c.s3Client.GetObject(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("GetObject failed: %w", err)
 	}
 
+	if err := CheckPSK(out.Metadata, psk); err != nil {
+		out.Body.Close()
+		return nil, err
+	}
+
+	chunkSize, err := ChunkSizeFromMetadata(out.Metadata, c.chunkSize)
+	if err != nil {
+		out.Body.Close()
+		return nil, err
+	}
+
 	out.Body = &cryptoReader{
 		s3Reader:  out.Body,
 		psk:       psk,
-		chunkSize: c.chunkSize,
+		chunkSize: chunkSize,
 	}
 
 	return out, nil
@@ -464,16 +545,176 @@ func (u *Uploader) Upload(ctx context.Context, input *s3.PutObjectInput) (output
 	return u.s3uploader.Upload(ctx, input)
 }
 
-// UploadWithPSK allows you to encrypt the file with a given psk
+// UploadWithPSK allows you to encrypt the file with a given psk.
+// The content is encrypted in chunks of the configured size, which is recorded in the object metadata.
+// The digest of the content is also recorded if the body is seekable, as it needs to be read twice;
+// non-seekable bodies are uploaded without a digest, unless it is provided in the metadata
+// (e.g. by callers that compress the content, whose digest is calculated before it is compressed).
 func (u *Uploader) UploadWithPSK(ctx context.Context, input *s3.PutObjectInput, psk []byte) (output *manager.UploadOutput, err error) {
+	input.Metadata = SetPSKMetadata(input.Metadata, psk, u.chunkSize)
+
+	_, recorded := input.Metadata[DigestMetadataKey]
+	if seeker, ok := input.Body.(io.ReadSeeker); ok && !recorded {
+		digest, err := SeekableDigest(seeker, psk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate content digest: %w", err)
+		}
+		input.Metadata[DigestMetadataKey] = digest
+	}
+
 	input.Body = &encryptoReader{
-		psk:      psk,
-		s3Reader: input.Body,
+		psk:       psk,
+		s3Reader:  input.Body,
+		chunkSize: u.chunkSize,
 	}
 
 	return u.s3uploader.Upload(ctx, input)
 }
 
+// SetPSKMetadata records the cipher format, the chunk size and the key check value of the user-defined PSK
+// used to encrypt an object in the provided object metadata, so that readers can decrypt it regardless of
+// their own configuration, and detect when they are given the wrong PSK.
+// The provided map is returned, or a new one if it was nil.
+func SetPSKMetadata(metadata map[string]string, psk []byte, chunkSize int) map[string]string {
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata[FormatMetadataKey] = FormatAESCFB
+	metadata[ChunkSizeMetadataKey] = strconv.Itoa(chunkSize)
+	metadata[KeyCheckMetadataKey] = KeyCheckValue(psk)
+	return metadata
+}
+
+// KeyCheckValue returns a hex encoded value that identifies the provided PSK without revealing it.
+func KeyCheckValue(psk []byte) string {
+	mac := hmac.New(sha256.New, psk)
+	mac.Write([]byte(keyCheckMessage))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// CheckPSK returns ErrWrongPSK if the provided PSK does not match the key check value recorded in the object metadata.
+// Legacy objects without a key check value cannot be validated, so nil is returned for them.
+func CheckPSK(metadata map[string]string, psk []byte) error {
+	expected, ok := MetadataValue(metadata, KeyCheckMetadataKey)
+	if !ok {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(KeyCheckValue(psk))) != 1 {
+		return ErrWrongPSK
+	}
+	return nil
+}
+
+// NewDigest returns the hash that calculates the digest of the plaintext content of objects encrypted with the provided PSK.
+// It is an HMAC-SHA256 keyed with a key derived from the PSK, so that the digest recorded in the clear object metadata
+// cannot be used to confirm a guessed content without the PSK.
+func NewDigest(psk []byte) hash.Hash {
+	mac := hmac.New(sha256.New, psk)
+	mac.Write([]byte(digestKeyMessage))
+	return hmac.New(sha256.New, mac.Sum(nil))
+}
+
+// NewIntegrityReader wraps the provided plaintext reader so that it returns ErrIntegrity instead of io.EOF
+// if the digest of the streamed content, calculated with the provided PSK, does not match the digest recorded in the object metadata.
+// The reader is returned unchanged if no digest is recorded.
+func NewIntegrityReader(r io.ReadCloser, metadata map[string]string, psk []byte) io.ReadCloser {
+	expected, ok := MetadataValue(metadata, DigestMetadataKey)
+	if !ok {
+		return r
+	}
+	return &integrityReader{
+		r:        r,
+		digest:   NewDigest(psk),
+		expected: expected,
+	}
+}
+
+type integrityReader struct {
+	r        io.ReadCloser
+	digest   hash.Hash
+	expected string
+}
+
+func (r *integrityReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.digest.Write(b[:n])
+	if err == io.EOF {
+		if actual := hex.EncodeToString(r.digest.Sum(nil)); subtle.ConstantTimeCompare([]byte(actual), []byte(r.expected)) != 1 {
+			return n, fmt.Errorf("%w: expected digest %s but got %s", ErrIntegrity, r.expected, actual)
+		}
+	}
+	return n, err
+}
+
+func (r *integrityReader) Close() error {
+	return r.r.Close()
+}
+
+// SeekableDigest returns the hex encoded digest of the remaining content of the provided reader for the provided PSK,
+// which is then rewound to its original position.
+func SeekableDigest(r io.ReadSeeker, psk []byte) (string, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+
+	digest := NewDigest(psk)
+	if _, err := io.Copy(digest, r); err != nil {
+		return "", err
+	}
+
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// ChunkSizeFromMetadata returns the chunk size recorded in the provided object metadata,
+// validating that the recorded cipher format is supported.
+// The provided defaultSize is returned for legacy objects without a recorded chunk size.
+func ChunkSizeFromMetadata(metadata map[string]string, defaultSize int) (int, error) {
+	if format, ok := MetadataValue(metadata, FormatMetadataKey); ok && format != FormatAESCFB {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+
+	value, ok := MetadataValue(metadata, ChunkSizeMetadataKey)
+	if !ok {
+		return defaultSize, nil
+	}
+
+	chunkSize, err := strconv.Atoi(value)
+	if err != nil || chunkSize <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidChunkSize, value)
+	}
+	return chunkSize, nil
+}
+
+// MetadataValue returns the value for the provided metadata key, ignoring case,
+// as the SDK lowercases the keys of the metadata headers it returns.
+func MetadataValue(metadata map[string]string, key string) (string, bool) {
+	if value, ok := metadata[key]; ok {
+		return value, true
+	}
+	for k, value := range metadata {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// DecryptChunk decrypts a single chunk of content encrypted with the provided PSK.
+// Chunks are encrypted independently, so each one must be provided with the boundaries it was encrypted with.
+func DecryptChunk(psk []byte, chunk []byte) ([]byte, error) {
+	return decryptObjectContent(psk, io.NopCloser(bytes.NewReader(chunk)))
+}
+
+// EncryptChunk encrypts a single chunk of content with the provided PSK, independently of any other chunk,
+// as UploadPartWithPSK does for the content of each part.
+func EncryptChunk(psk []byte, chunk []byte) ([]byte, error) {
+	return encryptObjectContent(psk, bytes.NewReader(chunk))
+}
+
 func encryptObjectContent(psk []byte, b io.Reader) ([]byte, error) {
 	unencryptedBytes, err := io.ReadAll(b)
 	if err != nil {
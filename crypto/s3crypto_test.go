@@ -0,0 +1,147 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPSKMetadata(t *testing.T) {
+	Convey("Given object metadata written by SetPSKMetadata", t, func() {
+		psk := []byte("0123456789abcdef")
+		metadata := SetPSKMetadata(map[string]string{"existing": "value"}, psk, 1024)
+
+		Convey("The existing metadata is kept along with the cipher format, chunk size and key check value", func() {
+			So(metadata, ShouldResemble, map[string]string{
+				"existing":           "value",
+				FormatMetadataKey:    FormatAESCFB,
+				ChunkSizeMetadataKey: "1024",
+				KeyCheckMetadataKey:  KeyCheckValue(psk),
+			})
+		})
+
+		Convey("The key check value does not reveal the psk", func() {
+			So(metadata[KeyCheckMetadataKey], ShouldNotContainSubstring, string(psk))
+			So(metadata[KeyCheckMetadataKey], ShouldNotContainSubstring, hex.EncodeToString(psk))
+		})
+
+		Convey("CheckPSK succeeds for the same psk", func() {
+			So(CheckPSK(metadata, psk), ShouldBeNil)
+		})
+
+		Convey("CheckPSK fails with ErrWrongPSK for a different psk", func() {
+			So(CheckPSK(metadata, []byte("fedcba9876543210")), ShouldEqual, ErrWrongPSK)
+		})
+
+		Convey("ChunkSizeFromMetadata returns the recorded chunk size", func() {
+			chunkSize, err := ChunkSizeFromMetadata(metadata, maxChunkSize)
+			So(err, ShouldBeNil)
+			So(chunkSize, ShouldEqual, 1024)
+		})
+	})
+
+	Convey("ChunkSizeFromMetadata ignores the case of the metadata keys", t, func() {
+		chunkSize, err := ChunkSizeFromMetadata(map[string]string{"Pskformat": FormatAESCFB, "Pskchunksize": "2048"}, maxChunkSize)
+		So(err, ShouldBeNil)
+		So(chunkSize, ShouldEqual, 2048)
+	})
+
+	Convey("CheckPSK cannot validate legacy objects without a key check value", t, func() {
+		So(CheckPSK(map[string]string{}, []byte("0123456789abcdef")), ShouldBeNil)
+	})
+
+	Convey("ChunkSizeFromMetadata returns the default chunk size for legacy objects", t, func() {
+		chunkSize, err := ChunkSizeFromMetadata(nil, maxChunkSize)
+		So(err, ShouldBeNil)
+		So(chunkSize, ShouldEqual, maxChunkSize)
+	})
+
+	Convey("ChunkSizeFromMetadata fails for an unsupported cipher format", t, func() {
+		_, err := ChunkSizeFromMetadata(map[string]string{FormatMetadataKey: "aes-gcm"}, maxChunkSize)
+		So(errors.Is(err, ErrUnsupportedFormat), ShouldBeTrue)
+	})
+
+	Convey("ChunkSizeFromMetadata fails for an invalid chunk size", t, func() {
+		_, err := ChunkSizeFromMetadata(map[string]string{ChunkSizeMetadataKey: "0"}, maxChunkSize)
+		So(errors.Is(err, ErrInvalidChunkSize), ShouldBeTrue)
+	})
+}
+
+func TestChunkedEncryption(t *testing.T) {
+	Convey("Given a payload spanning several chunks", t, func() {
+		psk := []byte("0123456789abcdef")
+		payload := bytes.Repeat([]byte("some,csv,content\n"), 100)
+		chunkSize := 64
+
+		encrypted, err := io.ReadAll(&encryptoReader{psk: psk, s3Reader: bytes.NewReader(payload), chunkSize: chunkSize})
+		So(err, ShouldBeNil)
+		So(len(encrypted), ShouldEqual, len(payload))
+
+		Convey("It is decrypted when read with the same chunk size", func() {
+			decrypted, err := io.ReadAll(&cryptoReader{psk: psk, s3Reader: io.NopCloser(bytes.NewReader(encrypted)), chunkSize: chunkSize})
+			So(err, ShouldBeNil)
+			So(decrypted, ShouldResemble, payload)
+		})
+
+		Convey("It is corrupted when read with a different chunk size", func() {
+			decrypted, err := io.ReadAll(&cryptoReader{psk: psk, s3Reader: io.NopCloser(bytes.NewReader(encrypted)), chunkSize: chunkSize * 2})
+			So(err, ShouldBeNil)
+			So(decrypted, ShouldNotResemble, payload)
+		})
+	})
+}
+
+func TestIntegrityReader(t *testing.T) {
+	payload := []byte("some,csv,content\n")
+	psk := []byte("test psk")
+	mac := NewDigest(psk)
+	mac.Write(payload)
+	digest := hex.EncodeToString(mac.Sum(nil))
+
+	Convey("The digest is keyed with the psk, so it cannot be calculated from the content alone", t, func() {
+		sum := sha256.Sum256(payload)
+		So(digest, ShouldNotEqual, hex.EncodeToString(sum[:]))
+
+		other := NewDigest([]byte("other psk"))
+		other.Write(payload)
+		So(digest, ShouldNotEqual, hex.EncodeToString(other.Sum(nil)))
+	})
+
+	Convey("An integrity reader streams content matching the recorded digest until io.EOF", t, func() {
+		r := NewIntegrityReader(io.NopCloser(bytes.NewReader(payload)), map[string]string{DigestMetadataKey: digest}, psk)
+		b, err := io.ReadAll(r)
+		So(err, ShouldBeNil)
+		So(b, ShouldResemble, payload)
+	})
+
+	Convey("An integrity reader fails with ErrIntegrity at the end of content that does not match the recorded digest", t, func() {
+		r := NewIntegrityReader(io.NopCloser(strings.NewReader("other,csv,content\n")), map[string]string{DigestMetadataKey: digest}, psk)
+		_, err := io.ReadAll(r)
+		So(errors.Is(err, ErrIntegrity), ShouldBeTrue)
+	})
+
+	Convey("NewIntegrityReader returns the same reader when no digest is recorded", t, func() {
+		body := io.NopCloser(bytes.NewReader(payload))
+		So(NewIntegrityReader(body, nil, psk), ShouldEqual, body)
+	})
+
+	Convey("SeekableDigest calculates the digest of the remaining content and rewinds the reader", t, func() {
+		r := bytes.NewReader(append([]byte("header\n"), payload...))
+		_, err := r.Seek(int64(len("header\n")), io.SeekStart)
+		So(err, ShouldBeNil)
+
+		d, err := SeekableDigest(r, psk)
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, digest)
+
+		b, err := io.ReadAll(r)
+		So(err, ShouldBeNil)
+		So(b, ShouldResemble, payload)
+	})
+}
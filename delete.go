@@ -0,0 +1,214 @@
+// file: delete.go
+//
+// Contains methods to delete objects from the bucket configured for the client,
+// one by one, in batches, or all the objects under a prefix.
+//
+// Requires "s3:DeleteObject" action allowed by IAM policy for objects inside the bucket,
+// and "s3:ListBucket" for the bucket to delete objects by prefix.
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// maxDeleteBatch is the maximum number of keys S3 accepts in a single DeleteObjects request
+	maxDeleteBatch = 1000
+
+	// deleteConcurrency is the number of DeleteObjects requests sent in parallel
+	deleteConcurrency = 4
+)
+
+// DeleteResult represents the outcome of deleting several objects
+type DeleteResult struct {
+	// Deleted are the keys of the objects that were deleted, or that would be deleted in a dry run, sorted
+	Deleted []string
+
+	// Failed are the objects that could not be deleted, sorted by key
+	Failed []DeleteFailure
+}
+
+// DeleteFailure represents an object that could not be deleted, and the reason
+type DeleteFailure struct {
+	Key string
+	Err error
+}
+
+// Delete deletes the object for the given key (inside the bucket configured for this client).
+// Deleting an object that does not exist is not an error.
+// In buckets with versioning enabled, a delete marker is added instead; see DeleteVersion to delete a version permanently.
+// An ErrObjectLocked error is returned if the deletion is blocked by Object Lock.
+func (cli *Client) Delete(ctx context.Context, key string) error {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+	}
+
+	_, err := cli.sdkClient.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return lockError(fmt.Errorf("error deleting object from s3: %w", err), logData)
+	}
+	return nil
+}
+
+// DeleteMany deletes the objects for the given keys (inside the bucket configured for this client),
+// in batches of up to 1000 keys that are sent in parallel.
+// The result reports the keys that were deleted and the ones that failed, with their error
+// (an ErrObjectLocked error for objects protected by Object Lock), in which case an error is also returned.
+func (cli *Client) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"num_keys":    len(keys),
+	}
+
+	return cli.deleteKeys(ctx, func(yield func(string, error) bool) {
+		for _, key := range keys {
+			if !yield(key, nil) {
+				return
+			}
+		}
+	}, false, logData)
+}
+
+// DeletePrefix deletes all the objects whose keys start with the provided prefix (inside the bucket configured for this client),
+// deleting each page of listed objects like DeleteMany does. An empty prefix is not allowed, as it would empty the bucket.
+// With dryRun, no object is deleted, and the keys that would be deleted are reported as deleted in the result.
+func (cli *Client) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"prefix":      prefix,
+		"dry_run":     dryRun,
+	}
+
+	if prefix == "" {
+		return nil, NewError(errors.New("a prefix must be provided to delete objects by prefix"), logData)
+	}
+
+	return cli.deleteKeys(ctx, func(yield func(string, error) bool) {
+		for obj, err := range cli.List(ctx, prefix, ListOptions{}) {
+			if !yield(obj.Key, err) {
+				return
+			}
+		}
+	}, dryRun, logData)
+}
+
+// deleteKeys deletes the provided keys in batches, which are sent in parallel while the keys are still being obtained.
+// If obtaining the keys fails, the batches that were already sent are completed, and the error is returned along with their result.
+func (cli *Client) deleteKeys(ctx context.Context, keys iter.Seq2[string, error], dryRun bool, logData log.Data) (*DeleteResult, error) {
+	result := &DeleteResult{
+		Deleted: []string{},
+		Failed:  []DeleteFailure{},
+	}
+	mutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+
+	batches := make(chan []string)
+	for i := 0; i < deleteConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				deleted, failed := batch, []DeleteFailure(nil)
+				if !dryRun {
+					deleted, failed = cli.deleteBatch(ctx, batch)
+				}
+				mutex.Lock()
+				result.Deleted = append(result.Deleted, deleted...)
+				result.Failed = append(result.Failed, failed...)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	var keysErr error
+	batch := make([]string, 0, maxDeleteBatch)
+	for key, err := range keys {
+		if err != nil {
+			keysErr = err
+			break
+		}
+		batch = append(batch, key)
+		if len(batch) == maxDeleteBatch {
+			batches <- batch
+			batch = make([]string, 0, maxDeleteBatch)
+		}
+	}
+	if len(batch) > 0 {
+		batches <- batch
+	}
+	close(batches)
+	wg.Wait()
+
+	sort.Strings(result.Deleted)
+	sort.Slice(result.Failed, func(i, j int) bool {
+		return result.Failed[i].Key < result.Failed[j].Key
+	})
+
+	if keysErr != nil {
+		return result, keysErr
+	}
+	if len(result.Failed) > 0 {
+		logData["num_failed"] = len(result.Failed)
+		return result, NewError(fmt.Errorf("failed to delete %d objects from s3: %w", len(result.Failed), result.Failed[0].Err), logData)
+	}
+	return result, nil
+}
+
+// deleteBatch deletes the provided keys with a single DeleteObjects request, returning the keys that were deleted and the ones that failed.
+// If the request fails, all the keys are reported as failed with its error.
+func (cli *Client) deleteBatch(ctx context.Context, keys []string) ([]string, []DeleteFailure) {
+	objects := make([]types.ObjectIdentifier, len(keys))
+	for i, key := range keys {
+		objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+	}
+
+	// in quiet mode, the response only lists the keys that could not be deleted
+	out, err := cli.sdkClient.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(cli.bucketName),
+		Delete: &types.Delete{
+			Objects: objects,
+			Quiet:   aws.Bool(true),
+		},
+	})
+	if err != nil {
+		err = fmt.Errorf("error deleting objects from s3: %w", err)
+		failed := make([]DeleteFailure, len(keys))
+		for i, key := range keys {
+			failed[i] = DeleteFailure{Key: key, Err: err}
+		}
+		return nil, failed
+	}
+
+	failed := make([]DeleteFailure, 0, len(out.Errors))
+	failedKeys := make(map[string]struct{}, len(out.Errors))
+	for _, e := range out.Errors {
+		key := aws.ToString(e.Key)
+		failedKeys[key] = struct{}{}
+		err := fmt.Errorf("error deleting object from s3: %s: %s", aws.ToString(e.Code), aws.ToString(e.Message))
+		if isObjectLockedMessage(aws.ToString(e.Code), aws.ToString(e.Message)) {
+			err = NewObjectLockedError(err, log.Data{"s3_key": key})
+		}
+		failed = append(failed, DeleteFailure{Key: key, Err: err})
+	}
+
+	deleted := slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+		_, ok := failedKeys[key]
+		return ok
+	})
+	return deleted, failed
+}
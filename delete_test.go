@@ -0,0 +1,179 @@
+package s3_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// deleteObjectsFunc returns a DeleteObjects mock function that fails to delete the provided keys with an AccessDenied error,
+// recording the keys of every request
+func deleteObjectsFunc(mutex *sync.Mutex, requested *[]string, denied ...string) func(ctx context.Context, in *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	return func(ctx context.Context, in *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+		out := &s3.DeleteObjectsOutput{}
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, obj := range in.Delete.Objects {
+			*requested = append(*requested, *obj.Key)
+			for _, key := range denied {
+				if *obj.Key == key {
+					out.Errors = append(out.Errors, types.Error{Key: obj.Key, Code: aws.String("AccessDenied"), Message: aws.String("Access Denied")})
+				}
+			}
+		}
+		return out, nil
+	}
+}
+
+func TestDelete(t *testing.T) {
+	Convey("Given an S3 client", t, func() {
+		ctx := context.Background()
+		sdkMock := &mock.S3SDKClientMock{
+			DeleteObjectFunc: func(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+				return &s3.DeleteObjectOutput{}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Delete deletes the object for the provided key", func() {
+			err := cli.Delete(ctx, testS3Key)
+			So(err, ShouldBeNil)
+			So(sdkMock.DeleteObjectCalls(), ShouldHaveLength, 1)
+			So(*sdkMock.DeleteObjectCalls()[0].In.Bucket, ShouldEqual, ExistingBucket)
+			So(*sdkMock.DeleteObjectCalls()[0].In.Key, ShouldEqual, testS3Key)
+			So(sdkMock.DeleteObjectCalls()[0].In.VersionId, ShouldBeNil)
+		})
+
+		Convey("Delete fails if S3 fails to delete the object", func() {
+			errDelete := errors.New("access denied")
+			sdkMock.DeleteObjectFunc = func(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+				return nil, errDelete
+			}
+			err := cli.Delete(ctx, testS3Key)
+			So(errors.Is(err, errDelete), ShouldBeTrue)
+		})
+	})
+}
+
+func TestDeleteMany(t *testing.T) {
+	Convey("Given an S3 client and 2500 keys to delete", t, func() {
+		ctx := context.Background()
+		keys := make([]string, 2500)
+		for i := range keys {
+			keys[i] = "key-" + strconv.Itoa(10000+i)
+		}
+
+		mutex := &sync.Mutex{}
+		requested := []string{}
+		sdkMock := &mock.S3SDKClientMock{}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("DeleteMany deletes them in batches of up to 1000 keys, in quiet mode", func() {
+			sdkMock.DeleteObjectsFunc = deleteObjectsFunc(mutex, &requested)
+
+			result, err := cli.DeleteMany(ctx, keys)
+			So(err, ShouldBeNil)
+			So(result.Deleted, ShouldResemble, keys)
+			So(result.Failed, ShouldBeEmpty)
+
+			sizes := []int{}
+			for _, call := range sdkMock.DeleteObjectsCalls() {
+				So(*call.In.Bucket, ShouldEqual, ExistingBucket)
+				So(*call.In.Delete.Quiet, ShouldBeTrue)
+				sizes = append(sizes, len(call.In.Delete.Objects))
+			}
+			So(sizes, ShouldHaveLength, 3)
+			So(sizes, ShouldContain, 500)
+			So(requested, ShouldHaveLength, 2500)
+		})
+
+		Convey("DeleteMany reports the keys that could not be deleted, with their errors", func() {
+			sdkMock.DeleteObjectsFunc = deleteObjectsFunc(mutex, &requested, "key-12000", "key-10001")
+
+			result, err := cli.DeleteMany(ctx, keys)
+			So(err, ShouldNotBeNil)
+			So(result.Deleted, ShouldHaveLength, 2498)
+			So(result.Deleted, ShouldNotContain, "key-10001")
+			So(result.Failed, ShouldHaveLength, 2)
+			So(result.Failed[0].Key, ShouldEqual, "key-10001")
+			So(result.Failed[0].Err.Error(), ShouldContainSubstring, "AccessDenied")
+			So(result.Failed[1].Key, ShouldEqual, "key-12000")
+		})
+
+		Convey("DeleteMany reports all the keys of a batch as failed if its request fails", func() {
+			errDelete := errors.New("slow down")
+			sdkMock.DeleteObjectsFunc = func(ctx context.Context, in *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+				if len(in.Delete.Objects) == 500 {
+					return nil, errDelete
+				}
+				return &s3.DeleteObjectsOutput{}, nil
+			}
+
+			result, err := cli.DeleteMany(ctx, keys)
+			So(errors.Is(err, errDelete), ShouldBeTrue)
+			So(result.Deleted, ShouldHaveLength, 2000)
+			So(result.Failed, ShouldHaveLength, 500)
+			So(errors.Is(result.Failed[0].Err, errDelete), ShouldBeTrue)
+		})
+
+		Convey("DeleteMany does not send any request if no keys are provided", func() {
+			result, err := cli.DeleteMany(ctx, nil)
+			So(err, ShouldBeNil)
+			So(result.Deleted, ShouldBeEmpty)
+			So(sdkMock.DeleteObjectsCalls(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestDeletePrefix(t *testing.T) {
+	Convey("Given a bucket with objects under several prefixes", t, func() {
+		ctx := context.Background()
+		sdkMock := newListMock([]string{"data/a.csv", "data/b.csv", "data/2023/c.csv", "other/file.csv"}, 2)
+		mutex := &sync.Mutex{}
+		requested := []string{}
+		sdkMock.DeleteObjectsFunc = deleteObjectsFunc(mutex, &requested)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("DeletePrefix deletes all the objects under the prefix", func() {
+			result, err := cli.DeletePrefix(ctx, "data/", false)
+			So(err, ShouldBeNil)
+			So(result.Deleted, ShouldResemble, []string{"data/2023/c.csv", "data/a.csv", "data/b.csv"})
+			So(requested, ShouldHaveLength, 3)
+			So(requested, ShouldNotContain, "other/file.csv")
+			So(*sdkMock.ListObjectsV2Calls()[0].In.Prefix, ShouldEqual, "data/")
+		})
+
+		Convey("DeletePrefix in a dry run reports the objects under the prefix without deleting them", func() {
+			result, err := cli.DeletePrefix(ctx, "data/", true)
+			So(err, ShouldBeNil)
+			So(result.Deleted, ShouldResemble, []string{"data/2023/c.csv", "data/a.csv", "data/b.csv"})
+			So(sdkMock.DeleteObjectsCalls(), ShouldBeEmpty)
+		})
+
+		Convey("DeletePrefix fails without listing or deleting objects if the prefix is empty", func() {
+			_, err := cli.DeletePrefix(ctx, "", false)
+			So(err, ShouldNotBeNil)
+			So(sdkMock.ListObjectsV2Calls(), ShouldBeEmpty)
+			So(sdkMock.DeleteObjectsCalls(), ShouldBeEmpty)
+		})
+
+		Convey("DeletePrefix fails if the objects cannot be listed", func() {
+			errList := errors.New("access denied")
+			sdkMock.ListObjectsV2Func = func(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				return nil, errList
+			}
+			_, err := cli.DeletePrefix(ctx, "data/", false)
+			So(errors.Is(err, errList), ShouldBeTrue)
+			So(sdkMock.DeleteObjectsCalls(), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,236 @@
+// file: download.go
+//
+// Contains methods to efficiently download objects from S3 into an io.WriterAt
+// by using the high level SDK manager downloader methods,
+// which automatically split large objects in ranges and download them concurrently.
+//
+// Requires "s3:GetObject" action allowed by IAM policy for objects inside the bucket,
+// as defined by `read-{bucketName}-bucket` policies in dp-setup
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DownloadOptions represents the optional configuration of a concurrent download
+type DownloadOptions struct {
+	// PartSize is the size in bytes of each range requested to S3.
+	// If it is zero, manager.DefaultDownloadPartSize (5 MB) is used.
+	// For downloads with a psk it is rounded up to a multiple of the encryption chunk size.
+	PartSize int64
+
+	// Concurrency is the number of ranges downloaded in parallel.
+	// If it is zero, manager.DefaultDownloadConcurrency (5) is used.
+	Concurrency int
+}
+
+// apply returns a function that configures a manager downloader with these options,
+// using a part size multiple of the provided alignment.
+func (opts DownloadOptions) apply(alignment int64) func(*manager.Downloader) {
+	return func(d *manager.Downloader) {
+		partSize := opts.PartSize
+		if partSize <= 0 {
+			partSize = manager.DefaultDownloadPartSize
+		}
+		if alignment > 0 && partSize%alignment != 0 {
+			partSize += alignment - partSize%alignment
+		}
+		d.PartSize = partSize
+
+		if opts.Concurrency > 0 {
+			d.Concurrency = opts.Concurrency
+		}
+	}
+}
+
+// Download writes the object for the given key (inside the bucket configured for this client) to the provided io.WriterAt,
+// downloading byte ranges in parallel. The number of bytes written is returned.
+// Compressed objects cannot be decompressed by range, so they are streamed sequentially and their decompressed content is written.
+func (cli *Client) Download(ctx context.Context, key string, w io.WriterAt, opts DownloadOptions) (int64, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"user_psk":    false,
+	}
+
+	head, err := cli.sdkClient.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &cli.bucketName,
+		Key:    &key,
+	})
+	if err != nil {
+		return 0, NewError(fmt.Errorf("error trying to obtain s3 object metadata with HeadObject call: %w", err), logData)
+	}
+
+	if isCompressed(head.Metadata) {
+		body, _, err := cli.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		return copyToWriterAt(w, body, logData)
+	}
+
+	n, err := cli.sdkDownloader.Download(ctx, w, &s3.GetObjectInput{
+		Bucket:  &cli.bucketName,
+		Key:     &key,
+		IfMatch: head.ETag,
+	}, opts.apply(0))
+	if err != nil {
+		return n, NewError(fmt.Errorf("error downloading object from s3: %w", err), logData)
+	}
+	return n, nil
+}
+
+// DownloadWithPSK writes the object for the given key (inside the bucket configured for this client) to the provided io.WriterAt,
+// downloading byte ranges in parallel and decrypting them with the provided psk. The number of bytes written is returned.
+// Ranges are aligned to the encryption chunk size recorded in the object metadata, so that each chunk can be decrypted independently.
+// If the object metadata holds a digest of its content and the writer is also an io.ReaderAt (e.g. an *os.File),
+// the written content is verified against it, returning crypto.ErrIntegrity if it does not match.
+// Compressed objects cannot be decompressed by range, so they are streamed sequentially and their decompressed content is written.
+func (cli *Client) DownloadWithPSK(ctx context.Context, key string, w io.WriterAt, psk []byte, opts DownloadOptions) (int64, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"user_psk":    true,
+	}
+
+	head, err := cli.sdkClient.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &cli.bucketName,
+		Key:    &key,
+	})
+	if err != nil {
+		return 0, NewError(fmt.Errorf("error trying to obtain s3 object metadata with HeadObject call: %w", err), logData)
+	}
+
+	if err := crypto.CheckPSK(head.Metadata, psk); err != nil {
+		return 0, NewWrongPSKError(fmt.Errorf("error validating psk: %w", err), logData)
+	}
+
+	if isCompressed(head.Metadata) {
+		body, _, err := cli.GetWithPSK(ctx, key, psk)
+		if err != nil {
+			return 0, err
+		}
+		return copyToWriterAt(w, body, logData)
+	}
+
+	chunkSize, err := crypto.ChunkSizeFromMetadata(head.Metadata, crypto.DefaultChunkSize)
+	if err != nil {
+		return 0, NewError(fmt.Errorf("error reading encryption metadata: %w", err), logData)
+	}
+
+	dw := &decryptingWriterAt{
+		w:         w,
+		psk:       psk,
+		chunkSize: int64(chunkSize),
+		size:      aws.ToInt64(head.ContentLength),
+		pending:   make(map[int64]*pendingChunk),
+	}
+
+	n, err := cli.sdkDownloader.Download(ctx, dw, &s3.GetObjectInput{
+		Bucket:  &cli.bucketName,
+		Key:     &key,
+		IfMatch: head.ETag,
+	}, opts.apply(int64(chunkSize)))
+	if err != nil {
+		return n, NewError(fmt.Errorf("error downloading object from s3: %w", err), logData)
+	}
+	if len(dw.pending) > 0 {
+		return n, NewError(errors.New("incomplete encryption chunks after downloading object from s3"), logData)
+	}
+
+	if ra, ok := w.(io.ReaderAt); ok {
+		verifier := crypto.NewIntegrityReader(io.NopCloser(io.NewSectionReader(ra, 0, n)), head.Metadata, psk)
+		if _, err := io.Copy(io.Discard, verifier); err != nil {
+			return n, NewError(fmt.Errorf("error verifying downloaded object: %w", err), logData)
+		}
+	}
+	return n, nil
+}
+
+// copyToWriterAt sequentially writes the content of the provided body to the start of the provided io.WriterAt, closing the body.
+func copyToWriterAt(w io.WriterAt, body io.ReadCloser, logData log.Data) (int64, error) {
+	defer body.Close()
+	n, err := io.Copy(io.NewOffsetWriter(w, 0), body)
+	if err != nil {
+		return n, NewError(fmt.Errorf("error writing object content: %w", err), logData)
+	}
+	return n, nil
+}
+
+// isCompressed returns true if the provided object metadata records a compression codec
+func isCompressed(metadata map[string]string) bool {
+	codec, ok := crypto.MetadataValue(metadata, CompressionMetadataKey)
+	return ok && Compression(codec) != CompressionNone
+}
+
+// decryptingWriterAt is an io.WriterAt that buffers the encrypted content of each chunk as it is downloaded,
+// and writes its decrypted content to the underlying writer once the chunk is complete.
+// Content is expected to be written sequentially from chunk-aligned offsets, as the manager downloader does
+// for each of its parts. A chunk that is written again (e.g. when a part is retried) is decrypted again.
+type decryptingWriterAt struct {
+	w         io.WriterAt
+	psk       []byte
+	chunkSize int64
+	size      int64
+
+	mutex   sync.Mutex
+	pending map[int64]*pendingChunk
+}
+
+// pendingChunk is an encrypted chunk that has not been completely downloaded yet
+type pendingChunk struct {
+	buf    []byte
+	filled int64
+}
+
+func (d *decryptingWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		index := off / d.chunkSize
+		start := index * d.chunkSize
+		length := min(d.chunkSize, d.size-start)
+		if length <= 0 {
+			return written, fmt.Errorf("write at offset %d beyond the object size %d", off, d.size)
+		}
+		n := min(int64(len(p)), length-(off-start))
+
+		d.mutex.Lock()
+		chunk, ok := d.pending[index]
+		if !ok {
+			chunk = &pendingChunk{buf: make([]byte, length)}
+			d.pending[index] = chunk
+		}
+		copy(chunk.buf[off-start:], p[:n])
+		chunk.filled = max(chunk.filled, off-start+n)
+		complete := chunk.filled == length
+		if complete {
+			delete(d.pending, index)
+		}
+		d.mutex.Unlock()
+
+		if complete {
+			plaintext, err := crypto.DecryptChunk(d.psk, chunk.buf)
+			if err != nil {
+				return written, err
+			}
+			if _, err := d.w.WriteAt(plaintext, start); err != nil {
+				return written, err
+			}
+		}
+
+		p = p[n:]
+		off += n
+		written += int(n)
+	}
+	return written, nil
+}
@@ -0,0 +1,349 @@
+// file: download_file.go
+//
+// Contains methods to download objects from S3 to local files,
+// so that a crash or a network failure never leaves a truncated file at the destination path.
+// Content is written to a temporary file in the same directory, which is verified, synced to disk and atomically renamed.
+//
+// Requires "s3:GetObject" action allowed by IAM policy for objects inside the bucket,
+// as defined by `read-{bucketName}-bucket` policies in dp-setup
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// DefaultFileMode is the default permission of files created by DownloadToFile
+const DefaultFileMode os.FileMode = 0o644
+
+// DownloadToFileOptions represents the optional configuration of a download to a local file
+type DownloadToFileOptions struct {
+	// Resume continues from a temporary file left by a previous download of the same object version
+	// (with the same ETag), requesting only the remaining content. If it is false, any partial content is discarded.
+	// Compressed objects are always downloaded from the start.
+	Resume bool
+
+	// ResumeOptions is the retry budget to resume the stream if it is interrupted during the download
+	ResumeOptions ResumeOptions
+
+	// FileMode is the permission of the created file. If it is zero, DefaultFileMode is used.
+	FileMode os.FileMode
+}
+
+// DownloadToFile writes the content of the object for the given key (inside the bucket configured for this client) to the provided path,
+// and returns the number of bytes of the file. Compressed objects are decompressed.
+// The content is written to a temporary file in the same directory, named after the destination path and the object ETag,
+// which is verified against the object length and MD5 ETag (when it is one), synced to disk and atomically renamed to the destination path.
+// If the verification fails, the temporary file is removed and an ErrDownloadVerification error is returned.
+func (cli *Client) DownloadToFile(ctx context.Context, key, path string, opts DownloadToFileOptions) (int64, error) {
+	return cli.downloadToFile(ctx, key, path, nil, opts)
+}
+
+// DownloadToFileWithPSK writes the content of the object for the given key (inside the bucket configured for this client) to the provided path,
+// decrypted with the provided psk, like DownloadToFile does.
+// The downloaded file is verified against the digest of its content recorded in the object metadata, if any.
+// Partial temporary files are resumed from the start of the last complete encryption chunk.
+func (cli *Client) DownloadToFileWithPSK(ctx context.Context, key, path string, psk []byte, opts DownloadToFileOptions) (int64, error) {
+	return cli.downloadToFile(ctx, key, path, psk, opts)
+}
+
+func (cli *Client) downloadToFile(ctx context.Context, key, path string, psk []byte, opts DownloadToFileOptions) (int64, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"path":        path,
+		"user_psk":    psk != nil,
+	}
+
+	head, err := cli.sdkClient.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &cli.bucketName,
+		Key:    &key,
+	})
+	if err != nil {
+		return 0, NewError(fmt.Errorf("error trying to obtain s3 object metadata with HeadObject call: %w", err), logData)
+	}
+	if head.ETag == nil || head.ContentLength == nil {
+		return 0, NewError(errors.New("s3 did not return the etag and length of the object"), logData)
+	}
+	etag, size := *head.ETag, *head.ContentLength
+	logData["etag"] = etag
+
+	chunkSize := int64(1)
+	if psk != nil {
+		if err := crypto.CheckPSK(head.Metadata, psk); err != nil {
+			return 0, NewWrongPSKError(fmt.Errorf("error validating psk: %w", err), logData)
+		}
+		c, err := crypto.ChunkSizeFromMetadata(head.Metadata, crypto.DefaultChunkSize)
+		if err != nil {
+			return 0, NewError(fmt.Errorf("error reading encryption metadata: %w", err), logData)
+		}
+		chunkSize = int64(c)
+	}
+
+	tmpPath := tempDownloadPath(path, etag)
+	logData["tmp_path"] = tmpPath
+	removeStaleDownloads(path, tmpPath)
+
+	mode := opts.FileMode
+	if mode == 0 {
+		mode = DefaultFileMode
+	}
+	f, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE, mode)
+	if err != nil {
+		return 0, NewError(fmt.Errorf("error opening temporary file: %w", err), logData)
+	}
+	defer f.Close()
+
+	// the content of a compressed object cannot be resumed, as the file holds its decompressed content
+	offset := int64(0)
+	compressed := isCompressed(head.Metadata)
+	if opts.Resume && !compressed {
+		info, err := f.Stat()
+		if err != nil {
+			return 0, NewError(fmt.Errorf("error reading temporary file: %w", err), logData)
+		}
+		offset = min(info.Size(), size) / chunkSize * chunkSize
+	}
+	if err := f.Truncate(offset); err != nil {
+		return 0, NewError(fmt.Errorf("error truncating temporary file: %w", err), logData)
+	}
+	logData["offset"] = offset
+
+	// the verification of streamed content is only possible if it is read from the start
+	var streamDigest hash.Hash
+	if offset < size {
+		r, digest, err := cli.openDownloadStream(ctx, key, head, offset, psk, chunkSize, opts.ResumeOptions, logData)
+		if err != nil {
+			var errChanged *ErrObjectChanged
+			if errors.As(err, &errChanged) {
+				os.Remove(tmpPath)
+			}
+			return 0, err
+		}
+		streamDigest = digest
+
+		_, err = io.Copy(io.NewOffsetWriter(f, offset), r)
+		r.Close()
+		if err != nil {
+			var errChanged *ErrObjectChanged
+			if errors.As(err, &errChanged) {
+				os.Remove(tmpPath)
+				return 0, errChanged
+			}
+			if errors.Is(err, crypto.ErrIntegrity) {
+				os.Remove(tmpPath)
+				return 0, NewDownloadVerificationError(err, logData)
+			}
+			return 0, NewError(fmt.Errorf("error downloading object to temporary file: %w", err), logData)
+		}
+	}
+
+	n, err := verifyDownload(f, head, psk, compressed, streamDigest)
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, NewDownloadVerificationError(err, logData)
+	}
+
+	if err := f.Sync(); err != nil {
+		return 0, NewError(fmt.Errorf("error syncing temporary file: %w", err), logData)
+	}
+	if err := f.Close(); err != nil {
+		return 0, NewError(fmt.Errorf("error closing temporary file: %w", err), logData)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, NewError(fmt.Errorf("error renaming temporary file: %w", err), logData)
+	}
+	syncDir(filepath.Dir(path))
+
+	return n, nil
+}
+
+// openDownloadStream returns a resumable reader for the content of the object from the provided offset,
+// pinned to the ETag of the provided HeadObject response.
+// For compressed objects, the content is decompressed, and a digest of the raw stream is returned to be compared with the ETag.
+func (cli *Client) openDownloadStream(ctx context.Context, key string, head *s3.HeadObjectOutput, offset int64, psk []byte, chunkSize int64, opts ResumeOptions, logData log.Data) (io.ReadCloser, hash.Hash, error) {
+	input := &s3.GetObjectInput{
+		Bucket:  aws.String(cli.bucketName),
+		Key:     aws.String(key),
+		IfMatch: head.ETag,
+	}
+	if offset > 0 {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	result, err := cli.sdkClient.GetObject(ctx, input)
+	if err != nil {
+		err = fmt.Errorf("error getting object from s3: %w", err)
+		if isPreconditionFailed(err) {
+			return nil, nil, NewObjectChangedError(err, logData)
+		}
+		return nil, nil, NewError(err, logData)
+	}
+
+	var r io.ReadCloser = &resumingReader{
+		ctx:       ctx,
+		cli:       cli,
+		key:       key,
+		etag:      *head.ETag,
+		size:      *head.ContentLength,
+		body:      result.Body,
+		offset:    offset,
+		opts:      opts.withDefaults(),
+		logData:   logData,
+		psk:       psk,
+		chunkSize: chunkSize,
+	}
+	if !isCompressed(head.Metadata) {
+		return r, nil, nil
+	}
+
+	var digest hash.Hash
+	if psk == nil && isMD5ETag(head) {
+		digest = md5.New()
+		r = &teeReadCloser{Reader: io.TeeReader(r, digest), Closer: r}
+	}
+
+	body, err := decompressReader(r, head.Metadata)
+	if err != nil {
+		return nil, nil, NewError(fmt.Errorf("error decompressing object from s3: %w", err), logData)
+	}
+	if psk != nil {
+		// the digest is calculated over the content before it was compressed
+		body = crypto.NewIntegrityReader(body, head.Metadata, psk)
+	}
+	return body, digest, nil
+}
+
+// verifyDownload verifies the content of the downloaded file against the expected length and checksums of the object,
+// and returns its size. The content of uncompressed objects is read again from the file, so that resumed downloads are fully verified.
+func verifyDownload(f *os.File, head *s3.HeadObjectOutput, psk []byte, compressed bool, streamDigest hash.Hash) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	n := info.Size()
+
+	if expected := contentLength(head.Metadata, head.ContentLength); expected != nil && *expected != n {
+		return n, fmt.Errorf("expected %d bytes but got %d", *expected, n)
+	}
+
+	if compressed {
+		if streamDigest != nil {
+			return n, compareETag(head, streamDigest)
+		}
+		return n, nil
+	}
+
+	if psk != nil {
+		_, err := io.Copy(io.Discard, crypto.NewIntegrityReader(io.NopCloser(io.NewSectionReader(f, 0, n)), head.Metadata, psk))
+		return n, err
+	}
+
+	if isMD5ETag(head) {
+		digest := md5.New()
+		if _, err := io.Copy(digest, io.NewSectionReader(f, 0, n)); err != nil {
+			return n, err
+		}
+		return n, compareETag(head, digest)
+	}
+	return n, nil
+}
+
+// isMD5ETag returns true if the ETag of the object is the MD5 digest of its content,
+// which is not the case for multipart uploads or objects encrypted with KMS or customer keys.
+func isMD5ETag(head *s3.HeadObjectOutput) bool {
+	etag := strings.Trim(aws.ToString(head.ETag), `"`)
+	return len(etag) == 2*md5.Size && !strings.Contains(etag, "-") &&
+		head.ServerSideEncryption != types.ServerSideEncryptionAwsKms &&
+		head.ServerSideEncryption != types.ServerSideEncryptionAwsKmsDsse &&
+		head.SSECustomerAlgorithm == nil
+}
+
+// compareETag returns an error if the provided MD5 digest does not match the ETag of the object
+func compareETag(head *s3.HeadObjectOutput, digest hash.Hash) error {
+	expected := strings.Trim(aws.ToString(head.ETag), `"`)
+	if actual := hex.EncodeToString(digest.Sum(nil)); actual != expected {
+		return fmt.Errorf("expected md5 %s but got %s", expected, actual)
+	}
+	return nil
+}
+
+// tempDownloadSuffix is the suffix of the temporary files of downloads, which follows the path and a hash of the object ETag
+const tempDownloadSuffix = ".part"
+
+// tempDownloadHashSize is the number of bytes of the hash of the object ETag in the names of temporary download files
+const tempDownloadHashSize = 8
+
+// tempDownloadPath returns the path of the temporary file for a download of the provided object version to the provided path
+func tempDownloadPath(path, etag string) string {
+	sum := sha256.Sum256([]byte(etag))
+	return fmt.Sprintf("%s.%s%s", path, hex.EncodeToString(sum[:tempDownloadHashSize]), tempDownloadSuffix)
+}
+
+// isTempDownload returns true if the provided file name is the name of the temporary file of a download,
+// i.e. '<name>.<hash>.part' as returned by tempDownloadPath
+func isTempDownload(name string) bool {
+	base, ok := strings.CutSuffix(name, tempDownloadSuffix)
+	hashLen := 2 * tempDownloadHashSize
+	if !ok || len(base) < hashLen+2 || base[len(base)-hashLen-1] != '.' {
+		return false
+	}
+	_, err := hex.DecodeString(base[len(base)-hashLen:])
+	return err == nil
+}
+
+// removeStaleDownloads removes the temporary files of previous downloads to the provided path, other than the current one
+func removeStaleDownloads(path, current string) {
+	matches, err := filepath.Glob(escapeGlob(path) + ".*" + tempDownloadSuffix)
+	if err != nil {
+		return
+	}
+	for _, match := range matches {
+		if match != current && isTempDownload(filepath.Base(match)) {
+			os.Remove(match)
+		}
+	}
+}
+
+// escapeGlob escapes the characters of the provided path that have a special meaning in glob patterns
+func escapeGlob(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// syncDir syncs the provided directory, so that a rename is persisted. Errors are ignored, as not all platforms support it.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	d.Sync()
+}
+
+// teeReadCloser is an io.ReadCloser that reads from a tee reader and closes the original reader
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
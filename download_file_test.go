@@ -0,0 +1,271 @@
+package s3_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fileObject is a fake S3 object served by the mock returned by newFileObjectMock
+type fileObject struct {
+	content  []byte
+	metadata map[string]string
+	etag     string
+
+	// interruptAfter makes the responses fail with a connection reset after the provided number of bytes, if it is positive
+	interruptAfter int
+}
+
+// newFileObjectMock returns an S3 client mock that serves the provided object for HeadObject and (ranged) GetObject requests
+func newFileObjectMock(obj *fileObject) *mock.S3SDKClientMock {
+	return &mock.S3SDKClientMock{
+		HeadObjectFunc: func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{
+				ContentLength: aws.Int64(int64(len(obj.content))),
+				ETag:          aws.String(obj.etag),
+				Metadata:      obj.metadata,
+			}, nil
+		},
+		GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			if in.IfMatch != nil && *in.IfMatch != obj.etag {
+				return nil, newResponseError(http.StatusPreconditionFailed, "PreconditionFailed")
+			}
+			start := 0
+			if in.Range != nil {
+				start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(*in.Range, "bytes="), "-"))
+			}
+			var body io.Reader = bytes.NewReader(obj.content[start:])
+			if obj.interruptAfter > 0 {
+				body = &interruptedReader{r: body, limit: obj.interruptAfter}
+			}
+			return &s3.GetObjectOutput{
+				Body:          io.NopCloser(body),
+				ContentLength: aws.Int64(int64(len(obj.content) - start)),
+				ETag:          aws.String(obj.etag),
+				Metadata:      obj.metadata,
+			}, nil
+		},
+	}
+}
+
+// md5ETag returns the ETag that S3 returns for an object uploaded with the provided content in a single request
+func md5ETag(content []byte) string {
+	sum := md5.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// listDir returns the names of the files in the provided directory
+func listDir(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	So(err, ShouldBeNil)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+var singleRetry = dps3.ResumeOptions{MaxRetries: 1, Backoff: time.Millisecond}
+
+func TestDownloadToFile(t *testing.T) {
+	Convey("Given an S3 client for an object with an MD5 ETag", t, func() {
+		ctx := context.Background()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "data.csv")
+		obj := &fileObject{content: testCSV, etag: md5ETag(testCSV)}
+		sdkMock := newFileObjectMock(obj)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("DownloadToFile writes the verified content to the path, without leaving temporary files", func() {
+			n, err := cli.DownloadToFile(ctx, testS3Key, path, dps3.DownloadToFileOptions{})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(testCSV))
+
+			b, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(b, ShouldResemble, testCSV)
+			So(listDir(dir), ShouldResemble, []string{"data.csv"})
+			So(*sdkMock.GetObjectCalls()[0].In.IfMatch, ShouldEqual, obj.etag)
+		})
+
+		Convey("DownloadToFile fails with ErrDownloadVerification if the content does not match the ETag, leaving no files", func() {
+			obj.etag = md5ETag([]byte("other content"))
+			_, err := cli.DownloadToFile(ctx, testS3Key, path, dps3.DownloadToFileOptions{})
+			var errVerification *dps3.ErrDownloadVerification
+			So(errors.As(err, &errVerification), ShouldBeTrue)
+			So(listDir(dir), ShouldBeEmpty)
+		})
+
+		Convey("Given a previous download that failed after resuming the stream once, with 2000 bytes written", func() {
+			obj.interruptAfter = 1000
+			_, err := cli.DownloadToFile(ctx, testS3Key, path, dps3.DownloadToFileOptions{ResumeOptions: singleRetry})
+			So(errors.Is(err, syscall.ECONNRESET), ShouldBeTrue)
+			So(listDir(dir), ShouldHaveLength, 1)
+			So(listDir(dir)[0], ShouldEndWith, ".part")
+			obj.interruptAfter = 0
+			calls := len(sdkMock.GetObjectCalls())
+
+			Convey("DownloadToFile with Resume requests the remaining content only, and verifies the whole file", func() {
+				n, err := cli.DownloadToFile(ctx, testS3Key, path, dps3.DownloadToFileOptions{Resume: true})
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, len(testCSV))
+				So(*sdkMock.GetObjectCalls()[calls].In.Range, ShouldEqual, "bytes=2000-")
+
+				b, err := os.ReadFile(path)
+				So(err, ShouldBeNil)
+				So(b, ShouldResemble, testCSV)
+				So(listDir(dir), ShouldResemble, []string{"data.csv"})
+			})
+
+			Convey("DownloadToFile without Resume downloads the whole object again", func() {
+				_, err := cli.DownloadToFile(ctx, testS3Key, path, dps3.DownloadToFileOptions{})
+				So(err, ShouldBeNil)
+				So(sdkMock.GetObjectCalls()[calls].In.Range, ShouldBeNil)
+			})
+
+			Convey("DownloadToFile with Resume discards the partial file if the object changed", func() {
+				obj.etag = md5ETag(testCSV[:100])
+				obj.content = testCSV[:100]
+				_, err := cli.DownloadToFile(ctx, testS3Key, path, dps3.DownloadToFileOptions{Resume: true})
+				So(err, ShouldBeNil)
+				So(sdkMock.GetObjectCalls()[calls].In.Range, ShouldBeNil)
+				So(listDir(dir), ShouldResemble, []string{"data.csv"})
+			})
+		})
+	})
+
+	Convey("Given an S3 client for an object that changes while it is downloaded", t, func() {
+		dir := t.TempDir()
+		obj := &fileObject{content: testCSV, etag: md5ETag(testCSV), interruptAfter: 1000}
+		sdkMock := newFileObjectMock(obj)
+		getObject := sdkMock.GetObjectFunc
+		sdkMock.GetObjectFunc = func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			if in.Range != nil {
+				obj.etag = `"changed"`
+			}
+			return getObject(ctx, in, optFns...)
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("DownloadToFile fails with ErrObjectChanged and removes the temporary file", func() {
+			_, err := cli.DownloadToFile(context.Background(), testS3Key, filepath.Join(dir, "data.csv"), dps3.DownloadToFileOptions{ResumeOptions: singleRetry})
+			var errChanged *dps3.ErrObjectChanged
+			So(errors.As(err, &errChanged), ShouldBeTrue)
+			So(listDir(dir), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given an S3 client for a gzipped object", t, func() {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "data.csv")
+
+		var compressed bytes.Buffer
+		w := gzip.NewWriter(&compressed)
+		w.Write(testCSV)
+		w.Close()
+
+		obj := &fileObject{
+			content:  compressed.Bytes(),
+			etag:     md5ETag(compressed.Bytes()),
+			metadata: map[string]string{dps3.CompressionMetadataKey: "gzip", dps3.UncompressedLengthMetadataKey: "4200"},
+		}
+		sdkMock := newFileObjectMock(obj)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("DownloadToFile writes the decompressed content, verifying the compressed stream against the ETag", func() {
+			n, err := cli.DownloadToFile(context.Background(), testS3Key, path, dps3.DownloadToFileOptions{Resume: true})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(testCSV))
+			b, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(b, ShouldResemble, testCSV)
+			So(sdkMock.GetObjectCalls()[0].In.Range, ShouldBeNil)
+		})
+
+		Convey("DownloadToFile fails with ErrDownloadVerification if the compressed stream does not match the ETag", func() {
+			obj.etag = md5ETag(testCSV)
+			_, err := cli.DownloadToFile(context.Background(), testS3Key, path, dps3.DownloadToFileOptions{})
+			var errVerification *dps3.ErrDownloadVerification
+			So(errors.As(err, &errVerification), ShouldBeTrue)
+			So(listDir(dir), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestDownloadToFileWithPSK(t *testing.T) {
+	Convey("Given an S3 client for an object encrypted in chunks with a psk and a digest of its content", t, func() {
+		ctx := context.Background()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "data.csv")
+		psk := []byte("0123456789abcdef")
+		chunkSize := 64
+		encrypted := encryptChunks(psk, testCSV, chunkSize)
+		metadata := crypto.SetPSKMetadata(nil, psk, chunkSize)
+		metadata[crypto.DigestMetadataKey] = pskDigest(psk, testCSV)
+		obj := &fileObject{content: encrypted, etag: `"abc-2"`, metadata: metadata}
+		sdkMock := newFileObjectMock(obj)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("DownloadToFileWithPSK writes the decrypted content to the path", func() {
+			n, err := cli.DownloadToFileWithPSK(ctx, testS3Key, path, psk, dps3.DownloadToFileOptions{})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(testCSV))
+			b, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(b, ShouldResemble, testCSV)
+		})
+
+		Convey("DownloadToFileWithPSK resumes a partial file from the start of its last complete chunk", func() {
+			// the stream is resumed once from the start of chunk 15 (offset 960), and fails again 1000 bytes later, in chunk 30
+			obj.interruptAfter = 1000
+			_, err := cli.DownloadToFileWithPSK(ctx, testS3Key, path, psk, dps3.DownloadToFileOptions{ResumeOptions: singleRetry})
+			So(err, ShouldNotBeNil)
+			obj.interruptAfter = 0
+			calls := len(sdkMock.GetObjectCalls())
+
+			n, err := cli.DownloadToFileWithPSK(ctx, testS3Key, path, psk, dps3.DownloadToFileOptions{Resume: true})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(testCSV))
+			So(*sdkMock.GetObjectCalls()[calls].In.Range, ShouldEqual, "bytes=1920-")
+			b, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(b, ShouldResemble, testCSV)
+		})
+
+		Convey("DownloadToFileWithPSK fails with ErrDownloadVerification if the content does not match the digest", func() {
+			metadata[crypto.DigestMetadataKey] = hex.EncodeToString(make([]byte, sha256.Size))
+			_, err := cli.DownloadToFileWithPSK(ctx, testS3Key, path, psk, dps3.DownloadToFileOptions{})
+			var errVerification *dps3.ErrDownloadVerification
+			So(errors.As(err, &errVerification), ShouldBeTrue)
+			So(errors.Is(err, crypto.ErrIntegrity), ShouldBeTrue)
+			So(listDir(dir), ShouldBeEmpty)
+		})
+
+		Convey("DownloadToFileWithPSK fails with ErrWrongPSK without creating any file if the psk is wrong", func() {
+			_, err := cli.DownloadToFileWithPSK(ctx, testS3Key, path, []byte("fedcba9876543210"), dps3.DownloadToFileOptions{})
+			var errWrongPSK *dps3.ErrWrongPSK
+			So(errors.As(err, &errWrongPSK), ShouldBeTrue)
+			So(listDir(dir), ShouldBeEmpty)
+		})
+	})
+}
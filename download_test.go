@@ -0,0 +1,191 @@
+package s3_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDownload(t *testing.T) {
+	Convey("Given an S3 client with a downloader for an uncompressed object", t, func() {
+		ctx := context.Background()
+		etag := `"abc"`
+
+		sdkMock := &mock.S3SDKClientMock{
+			HeadObjectFunc: func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(testCSV))), ETag: &etag}, nil
+			},
+		}
+		downloaderMock := newDownloaderMock(testCSV)
+		cli := dps3.InstantiateClientWithDownloader(sdkMock, nil, nil, nil, downloaderMock, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Download writes the object to the writer, pinned to the ETag and with the provided options", func() {
+			w := manager.NewWriteAtBuffer(nil)
+			n, err := cli.Download(ctx, testS3Key, w, dps3.DownloadOptions{PartSize: 1000, Concurrency: 3})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(testCSV))
+			So(w.Bytes(), ShouldResemble, testCSV)
+
+			So(len(downloaderMock.DownloadCalls()), ShouldEqual, 1)
+			So(*downloaderMock.DownloadCalls()[0].In.IfMatch, ShouldEqual, etag)
+			d := &manager.Downloader{}
+			downloaderMock.DownloadCalls()[0].Options[0](d)
+			So(d.PartSize, ShouldEqual, 1000)
+			So(d.Concurrency, ShouldEqual, 3)
+		})
+	})
+
+	Convey("Given an S3 client for a gzipped object", t, func() {
+		var compressed bytes.Buffer
+		gw := gzip.NewWriter(&compressed)
+		gw.Write(testCSV)
+		gw.Close()
+
+		metadata := map[string]string{dps3.CompressionMetadataKey: "gzip"}
+		sdkMock := &mock.S3SDKClientMock{
+			HeadObjectFunc: func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return &s3.HeadObjectOutput{Metadata: metadata}, nil
+			},
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(compressed.Bytes())), Metadata: metadata}, nil
+			},
+		}
+		downloaderMock := &mock.S3SDKDownloaderMock{}
+		cli := dps3.InstantiateClientWithDownloader(sdkMock, nil, nil, nil, downloaderMock, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Download streams the decompressed content sequentially instead of using the downloader", func() {
+			w := manager.NewWriteAtBuffer(nil)
+			n, err := cli.Download(context.Background(), testS3Key, w, dps3.DownloadOptions{})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(testCSV))
+			So(w.Bytes(), ShouldResemble, testCSV)
+			So(len(downloaderMock.DownloadCalls()), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestDownloadWithPSK(t *testing.T) {
+	Convey("Given an S3 client with a downloader for an object encrypted in chunks with a psk", t, func() {
+		ctx := context.Background()
+		psk := []byte("0123456789abcdef")
+		chunkSize := 64
+		encrypted := encryptChunks(psk, testCSV, chunkSize)
+		metadata := crypto.SetPSKMetadata(nil, psk, chunkSize)
+		metadata[crypto.DigestMetadataKey] = pskDigest(psk, testCSV)
+
+		sdkMock := &mock.S3SDKClientMock{
+			HeadObjectFunc: func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(encrypted))), Metadata: metadata}, nil
+			},
+		}
+		downloaderMock := newDownloaderMock(encrypted)
+		cli := dps3.InstantiateClientWithDownloader(sdkMock, nil, nil, nil, downloaderMock, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("DownloadWithPSK decrypts the ranges, which are aligned to the chunk size, and verifies the written content", func() {
+			f, err := os.Create(filepath.Join(t.TempDir(), "download.csv"))
+			So(err, ShouldBeNil)
+			defer f.Close()
+
+			n, err := cli.DownloadWithPSK(ctx, testS3Key, f, psk, dps3.DownloadOptions{PartSize: 1000, Concurrency: 4})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(testCSV))
+
+			b, err := os.ReadFile(f.Name())
+			So(err, ShouldBeNil)
+			So(b, ShouldResemble, testCSV)
+
+			d := &manager.Downloader{}
+			downloaderMock.DownloadCalls()[0].Options[0](d)
+			So(d.PartSize, ShouldEqual, 1024)
+		})
+
+		Convey("DownloadWithPSK fails with ErrIntegrity if the written content does not match the recorded digest", func() {
+			metadata[crypto.DigestMetadataKey] = hex.EncodeToString(make([]byte, sha256.Size))
+			f, err := os.Create(filepath.Join(t.TempDir(), "download.csv"))
+			So(err, ShouldBeNil)
+			defer f.Close()
+
+			_, err = cli.DownloadWithPSK(ctx, testS3Key, f, psk, dps3.DownloadOptions{})
+			So(errors.Is(err, crypto.ErrIntegrity), ShouldBeTrue)
+		})
+
+		Convey("DownloadWithPSK fails with ErrWrongPSK without downloading anything if the psk is wrong", func() {
+			_, err := cli.DownloadWithPSK(ctx, testS3Key, manager.NewWriteAtBuffer(nil), []byte("fedcba9876543210"), dps3.DownloadOptions{})
+			var errWrongPSK *dps3.ErrWrongPSK
+			So(errors.As(err, &errWrongPSK), ShouldBeTrue)
+			So(len(downloaderMock.DownloadCalls()), ShouldEqual, 0)
+		})
+	})
+}
+
+// newDownloaderMock returns a downloader mock that behaves like the manager downloader for the provided content:
+// parts are written concurrently, each one sequentially in small writes from its start offset.
+func newDownloaderMock(content []byte) *mock.S3SDKDownloaderMock {
+	return &mock.S3SDKDownloaderMock{
+		DownloadFunc: func(ctx context.Context, w io.WriterAt, in *s3.GetObjectInput, options ...func(*manager.Downloader)) (int64, error) {
+			d := &manager.Downloader{PartSize: manager.DefaultDownloadPartSize}
+			for _, opt := range options {
+				opt(d)
+			}
+
+			wg := &sync.WaitGroup{}
+			errs := make(chan error, len(content)/int(d.PartSize)+1)
+			for start := int64(0); start < int64(len(content)); start += d.PartSize {
+				wg.Add(1)
+				go func(start int64) {
+					defer wg.Done()
+					part := content[start:min(start+d.PartSize, int64(len(content)))]
+					for off := 0; off < len(part); off += 100 {
+						if _, err := w.WriteAt(part[off:min(off+100, len(part))], start+int64(off)); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}(start)
+			}
+			wg.Wait()
+			close(errs)
+			return int64(len(content)), <-errs
+		},
+	}
+}
+
+// encryptChunks encrypts the provided content in chunks of the provided size with the psk,
+// in the same way as the crypto client does.
+func encryptChunks(psk, content []byte, chunkSize int) []byte {
+	encrypted := make([]byte, 0, len(content))
+	for start := 0; start < len(content); start += chunkSize {
+		chunk := content[start:min(start+chunkSize, len(content))]
+		block, err := aes.NewCipher(psk)
+		So(err, ShouldBeNil)
+		out := make([]byte, len(chunk))
+		cipher.NewCFBEncrypter(block, psk).XORKeyStream(out, chunk)
+		encrypted = append(encrypted, out...)
+	}
+	return encrypted
+}
+
+// pskDigest returns the digest of the provided content for the psk, as it is recorded by the crypto client
+func pskDigest(psk, content []byte) string {
+	digest := crypto.NewDigest(psk)
+	digest.Write(content)
+	return hex.EncodeToString(digest.Sum(nil))
+}
@@ -1,7 +1,10 @@
 package s3
 
 import (
+	"errors"
+
 	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/smithy-go"
 )
 
 // S3Error is the s3 package's error type
@@ -123,3 +126,161 @@ func NewChunkTooSmallError(err error, logData map[string]interface{}) *ErrChunkT
 type ErrChunkTooSmall struct {
 	S3Error
 }
+
+// ErrWrongPSK if the psk provided to read an object is not the one it was encrypted with
+type ErrWrongPSK struct {
+	S3Error
+}
+
+func NewWrongPSKError(err error, logData map[string]interface{}) *ErrWrongPSK {
+	return &ErrWrongPSK{
+		S3Error: S3Error{
+			err:     err,
+			logData: logData,
+		},
+	}
+}
+
+// ErrInvalidRange if a requested byte range is not valid or cannot be satisfied for the object size
+type ErrInvalidRange struct {
+	S3Error
+}
+
+func NewInvalidRangeError(err error, logData map[string]interface{}) *ErrInvalidRange {
+	return &ErrInvalidRange{
+		S3Error: S3Error{
+			err:     err,
+			logData: logData,
+		},
+	}
+}
+
+// ErrNotModified if an object was not returned because an If-None-Match or If-Modified-Since precondition was not met (HTTP 304)
+type ErrNotModified struct {
+	S3Error
+}
+
+func NewNotModifiedError(err error, logData map[string]interface{}) *ErrNotModified {
+	return &ErrNotModified{
+		S3Error: S3Error{
+			err:     err,
+			logData: logData,
+		},
+	}
+}
+
+// ErrPreconditionFailed if an object was not returned because an If-Match or If-Unmodified-Since precondition was not met (HTTP 412)
+type ErrPreconditionFailed struct {
+	S3Error
+}
+
+func NewPreconditionFailedError(err error, logData map[string]interface{}) *ErrPreconditionFailed {
+	return &ErrPreconditionFailed{
+		S3Error: S3Error{
+			err:     err,
+			logData: logData,
+		},
+	}
+}
+
+// ErrObjectChanged if an object changed while its content was being read, so the stream cannot be resumed
+type ErrObjectChanged struct {
+	S3Error
+}
+
+func NewObjectChangedError(err error, logData map[string]interface{}) *ErrObjectChanged {
+	return &ErrObjectChanged{
+		S3Error: S3Error{
+			err:     err,
+			logData: logData,
+		},
+	}
+}
+
+// ErrDownloadVerification if a downloaded file does not match the length or checksum of the object
+type ErrDownloadVerification struct {
+	S3Error
+}
+
+func NewDownloadVerificationError(err error, logData map[string]interface{}) *ErrDownloadVerification {
+	return &ErrDownloadVerification{
+		S3Error: S3Error{
+			err:     err,
+			logData: logData,
+		},
+	}
+}
+
+// ErrCopyVerification if a copied object does not match the size or checksum of its source
+type ErrCopyVerification struct {
+	S3Error
+}
+
+func NewCopyVerificationError(err error, logData map[string]interface{}) *ErrCopyVerification {
+	return &ErrCopyVerification{
+		S3Error: S3Error{
+			err:     err,
+			logData: logData,
+		},
+	}
+}
+
+// ErrInvalidTags if the tags provided for an object exceed the limits of S3
+type ErrInvalidTags struct {
+	S3Error
+}
+
+func NewInvalidTagsError(err error, logData map[string]interface{}) *ErrInvalidTags {
+	return &ErrInvalidTags{
+		S3Error: S3Error{
+			err:     err,
+			logData: logData,
+		},
+	}
+}
+
+// ErrInvalidExpiry if the expiry of a presigned request is not positive or exceeds the maximum allowed by S3
+type ErrInvalidExpiry struct {
+	S3Error
+}
+
+func NewInvalidExpiryError(err error, logData map[string]interface{}) *ErrInvalidExpiry {
+	return &ErrInvalidExpiry{
+		S3Error: S3Error{
+			err:     err,
+			logData: logData,
+		},
+	}
+}
+
+// ErrObjectLocked if a delete or overwrite is blocked by the Object Lock retention or legal hold of an object version
+type ErrObjectLocked struct {
+	S3Error
+}
+
+func NewObjectLockedError(err error, logData map[string]interface{}) *ErrObjectLocked {
+	return &ErrObjectLocked{
+		S3Error: S3Error{
+			err:     err,
+			logData: logData,
+		},
+	}
+}
+
+// errorCode returns the AWS error code of the provided error, or an empty string if it is not an AWS API error
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+// httpStatusCode returns the HTTP status code of the response that caused the provided error, or 0 if it is not known
+func httpStatusCode(err error) int {
+	var respErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode()
+	}
+	return 0
+}
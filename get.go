@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/ONSdigital/dp-s3/v3/crypto"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -76,75 +77,160 @@ func (cli *Client) doGetFromS3URL(ctx context.Context, rawURL string, style URLS
 // Get returns an io.ReadCloser instance for the given path (inside the bucket configured for this client)
 // and the content length (size in bytes).
 // They 'key' parameter refers to the path for the file under the bucket.
+// Compressed objects are decompressed transparently, and their uncompressed length is returned if it is known (nil otherwise).
+// Optional read options may select a version of the object or set preconditions, in which case an ErrNotModified or ErrPreconditionFailed error
+// is returned if S3 responds with 304 (Not Modified) or 412 (Precondition Failed) respectively.
 //
 // The caller is responsible for closing the returned ReadCloser.
 // For example, it may be closed in a defer statement: defer r.Close()
-func (cli *Client) Get(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+func (cli *Client) Get(ctx context.Context, key string, opts ...ReadOption) (io.ReadCloser, *int64, error) {
+	body, info, err := cli.GetObject(ctx, key, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, info.ContentLength, nil
+}
 
+// GetObject returns an io.ReadCloser instance for the given path (inside the bucket configured for this client),
+// like Get does, along with the object metadata obtained from the same response.
+//
+// The caller is responsible for closing the returned ReadCloser.
+// For example, it may be closed in a defer statement: defer r.Close()
+func (cli *Client) GetObject(ctx context.Context, key string, opts ...ReadOption) (io.ReadCloser, *ObjectInfo, error) {
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(cli.bucketName),
 		Key:    aws.String(key),
 	}
 
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"user_psk":    false,
+	}
+
+	readOpts := newReadOptions(opts)
+	readOpts.applyToGet(input)
+	readOpts.addToLogData(logData)
+
 	result, err := cli.sdkClient.GetObject(ctx, input)
 	if err != nil {
-		return nil, nil, NewError(fmt.Errorf("error getting object from s3: %w", err), log.Data{
-			"bucket_name": cli.bucketName,
-			"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
-			"user_psk":    false,
-		})
+		err = fmt.Errorf("error getting object from s3: %w", err)
+		if condErr := conditionalError(err, logData); condErr != nil {
+			return nil, nil, condErr
+		}
+		return nil, nil, NewError(err, logData)
+	}
+
+	body, err := decompressReader(result.Body, result.Metadata)
+	if err != nil {
+		return nil, nil, NewError(fmt.Errorf("error decompressing object from s3: %w", err), logData)
 	}
 
-	return result.Body, result.ContentLength, nil
+	return body, objectInfoFromGet(key, result), nil
 }
 
 // GetWithPSK returns an io.ReadCloser instance for the given path (inside the bucket configured for this client)
 // and the content length (size in bytes). It uses the provided PSK for encryption.
 // The 'key' parameter refers to the path for the file under the bucket.
+// The encryption chunk size recorded in the object metadata is used to decrypt the content.
+// Compressed objects are decompressed transparently, and their uncompressed length is returned if it is known (nil otherwise).
+// If the psk is not the one the object was encrypted with, an ErrWrongPSK error is returned.
+// If the object metadata holds a digest of its content, the returned reader fails with crypto.ErrIntegrity
+// instead of io.EOF when the streamed content does not match it.
+// Optional read options may select a version of the object or set preconditions, in which case an ErrNotModified or ErrPreconditionFailed error
+// is returned if S3 responds with 304 (Not Modified) or 412 (Precondition Failed) respectively.
+//
+// The caller is responsible for closing the returned ReadCloser.
+// For example, it may be closed in a defer statement: defer r.Close()
+func (cli *Client) GetWithPSK(ctx context.Context, key string, psk []byte, opts ...ReadOption) (io.ReadCloser, *int64, error) {
+	body, info, err := cli.GetObjectWithPSK(ctx, key, psk, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, info.ContentLength, nil
+}
+
+// GetObjectWithPSK returns an io.ReadCloser instance for the given path (inside the bucket configured for this client),
+// decrypted with the provided PSK like GetWithPSK does, along with the object metadata obtained from the same response.
 //
 // The caller is responsible for closing the returned ReadCloser.
 // For example, it may be closed in a defer statement: defer r.Close()
-func (cli *Client) GetWithPSK(ctx context.Context, key string, psk []byte) (io.ReadCloser, *int64, error) {
+func (cli *Client) GetObjectWithPSK(ctx context.Context, key string, psk []byte, opts ...ReadOption) (io.ReadCloser, *ObjectInfo, error) {
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(cli.bucketName),
 		Key:    aws.String(key),
 	}
 
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"user_psk":    true,
+	}
+
+	readOpts := newReadOptions(opts)
+	readOpts.applyToGet(input)
+	readOpts.addToLogData(logData)
+
 	result, err := cli.cryptoClient.GetObjectWithPSK(ctx, input, psk)
 	if err != nil {
-		return nil, nil, NewError(fmt.Errorf("error getting object from s3: %w", err), log.Data{
-			"bucket_name": cli.bucketName,
-			"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
-			"user_psk":    true,
-		})
+		err = fmt.Errorf("error getting object from s3: %w", err)
+		if errors.Is(err, crypto.ErrWrongPSK) {
+			return nil, nil, NewWrongPSKError(err, logData)
+		}
+		if condErr := conditionalError(err, logData); condErr != nil {
+			return nil, nil, condErr
+		}
+		return nil, nil, NewError(err, logData)
+	}
+
+	// The digest is calculated over the content before it was compressed, if the client had a codec
+	body, err := decompressReader(result.Body, result.Metadata)
+	if err != nil {
+		return nil, nil, NewError(fmt.Errorf("error decompressing object from s3: %w", err), logData)
 	}
+	body = crypto.NewIntegrityReader(body, result.Metadata, psk)
 
-	return result.Body, result.ContentLength, nil
+	return body, objectInfoFromGet(key, result), nil
 }
 
-// Head returns a HeadObjectOutput containing an object metadata obtained from a HTTP HEAD call
-func (cli *Client) Head(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
-	result, err := cli.sdkClient.HeadObject(ctx, &s3.HeadObjectInput{
+// Head returns the metadata of an object obtained from a HTTP HEAD call.
+// Optional read options may select a version of the object or set preconditions, in which case an ErrNotModified or ErrPreconditionFailed error
+// is returned if S3 responds with 304 (Not Modified) or 412 (Precondition Failed) respectively.
+func (cli *Client) Head(ctx context.Context, key string, opts ...ReadOption) (*ObjectInfo, error) {
+	input := &s3.HeadObjectInput{
 		Bucket: &cli.bucketName,
 		Key:    &key,
-	})
+	}
+
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+	}
+
+	readOpts := newReadOptions(opts)
+	readOpts.applyToHead(input)
+	readOpts.addToLogData(logData)
+
+	result, err := cli.sdkClient.HeadObject(ctx, input)
 	if err != nil {
-		return nil, NewError(
-			fmt.Errorf("error trying to obtain s3 object metadata with HeadObject call: %w", err),
-			log.Data{
-				"bucket_name": cli.bucketName,
-				"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
-			},
-		)
+		err = fmt.Errorf("error trying to obtain s3 object metadata with HeadObject call: %w", err)
+		if condErr := conditionalError(err, logData); condErr != nil {
+			return nil, condErr
+		}
+		return nil, NewError(err, logData)
 	}
-	return result, nil
+	return objectInfoFromHead(key, result), nil
 }
 
-func (cli *Client) FileExists(ctx context.Context, key string) (bool, error) {
-	_, err := cli.sdkClient.HeadObject(ctx, &s3.HeadObjectInput{
+// FileExists returns true if the object for the given key exists, or the version selected by the provided read options
+func (cli *Client) FileExists(ctx context.Context, key string, opts ...ReadOption) (bool, error) {
+	input := &s3.HeadObjectInput{
 		Bucket: &cli.bucketName,
 		Key:    &key,
-	})
+	}
+	newReadOptions(opts).applyToHead(input)
+
+	_, err := cli.sdkClient.HeadObject(ctx, input)
 	if err != nil {
 		var notFoundErr *types.NotFound
 		if errors.As(err, &notFoundErr) {
@@ -155,13 +241,20 @@ func (cli *Client) FileExists(ctx context.Context, key string) (bool, error) {
 	return true, nil
 }
 
+// GetBucketPolicy returns the raw policy of the provided bucket (or the bucket configured for this client, if it is empty),
+// or nil if it has no policy.
+//
+// Deprecated: use GetPolicy, which returns the typed policy of the bucket.
 func (cli *Client) GetBucketPolicy(ctx context.Context, BucketName string) (*s3.GetBucketPolicyOutput, error) {
+	if BucketName == "" {
+		BucketName = cli.bucketName
+	}
 	result, err := cli.sdkClient.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{
 		Bucket: aws.String(BucketName),
 	})
 	if err != nil {
 		var notFoundErr *types.NotFound
-		if errors.As(err, &notFoundErr) {
+		if errors.As(err, &notFoundErr) || errorCode(err) == errCodeNoSuchBucketPolicy {
 			return nil, nil
 		}
 		return nil, err
@@ -169,21 +262,29 @@ func (cli *Client) GetBucketPolicy(ctx context.Context, BucketName string) (*s3.
 	return result, nil
 }
 
+// PutBucketPolicy replaces the policy of the provided bucket (or the bucket configured for this client, if it is empty) with the raw policy document.
+// Any error putting the policy, including a bucket that is not found, is returned wrapped in an S3Error.
+//
+// Deprecated: use PutPolicy or AddPolicyStatements, which validate the typed policy before it is sent.
 func (cli *Client) PutBucketPolicy(ctx context.Context, BucketName string, policy string) (*s3.PutBucketPolicyOutput, error) {
+	if BucketName == "" {
+		BucketName = cli.bucketName
+	}
 	result, err := cli.sdkClient.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
 		Bucket: aws.String(BucketName),
 		Policy: aws.String(string(policy)),
 	})
 	if err != nil {
-		var notFoundErr *types.NotFound
-		if errors.As(err, &notFoundErr) {
-			return nil, nil
-		}
-		return nil, err
+		return nil, NewError(fmt.Errorf("error putting bucket policy to s3: %w", err), log.Data{
+			"bucket_name": BucketName,
+		})
 	}
 	return result, nil
 }
 
+// ListObjects returns the first page of objects (up to 1000) of the provided bucket, using the legacy ListObjects API.
+//
+// Deprecated: use List, which lists all the objects of the bucket configured for this client, under a prefix.
 func (cli *Client) ListObjects(ctx context.Context, BucketName string) (*s3.ListObjectsOutput, error) {
 	result, err := cli.sdkClient.ListObjects(ctx, &s3.ListObjectsInput{
 		Bucket: aws.String(BucketName),
@@ -0,0 +1,132 @@
+// file: get_range.go
+//
+// Contains methods to get byte ranges of objects from S3,
+// for example to read a file header or the last lines of a log without downloading the whole object.
+//
+// Requires "s3:GetObject" action allowed by IAM policy for objects inside the bucket,
+// as defined by `read-{bucketName}-bucket` policies in dp-setup
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ContentRange represents the byte range of an object returned by S3, with inclusive Start and End offsets.
+// Size is the full size of the object, or -1 if S3 did not report it.
+type ContentRange struct {
+	Start int64
+	End   int64
+	Size  int64
+}
+
+// Length returns the number of bytes in the range
+func (r *ContentRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// GetRange returns an io.ReadCloser instance for a byte range of the given path (inside the bucket configured for this client),
+// along with the range returned by S3 and the full size of the object.
+// The range starts at 'offset' and spans 'length' bytes, or until the end of the object if length is zero (open-ended range).
+// A negative offset requests the last -offset bytes of the object (suffix range), in which case length must be zero.
+// An ErrInvalidRange error is returned if the range is not valid, or S3 cannot satisfy it for the object size.
+//
+// Byte ranges refer to the content stored in S3, so they are not supported for compressed objects.
+//
+// The caller is responsible for closing the returned ReadCloser.
+// For example, it may be closed in a defer statement: defer r.Close()
+func (cli *Client) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, *ContentRange, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"offset":      offset,
+		"length":      length,
+	}
+
+	rng, err := rangeHeader(offset, length)
+	if err != nil {
+		return nil, nil, NewInvalidRangeError(err, logData)
+	}
+	logData["range"] = rng
+
+	result, err := cli.sdkClient.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+		Range:  aws.String(rng),
+	})
+	if err != nil {
+		if errorCode(err) == "InvalidRange" {
+			return nil, nil, NewInvalidRangeError(fmt.Errorf("error getting object range from s3: %w", err), logData)
+		}
+		return nil, nil, NewError(fmt.Errorf("error getting object range from s3: %w", err), logData)
+	}
+
+	if isCompressed(result.Metadata) {
+		result.Body.Close()
+		return nil, nil, NewError(errors.New("byte ranges are not supported for compressed objects"), logData)
+	}
+
+	contentRange, err := parseContentRange(aws.ToString(result.ContentRange))
+	if err != nil {
+		result.Body.Close()
+		return nil, nil, NewError(fmt.Errorf("error parsing content range returned by s3: %w", err), logData)
+	}
+
+	return result.Body, contentRange, nil
+}
+
+// rangeHeader returns the value of the HTTP Range header for the provided offset and length, as described by GetRange
+func rangeHeader(offset, length int64) (string, error) {
+	switch {
+	case length < 0:
+		return "", fmt.Errorf("negative range length: %d", length)
+	case offset < 0 && length > 0:
+		return "", errors.New("a length cannot be provided for suffix ranges")
+	case offset < 0:
+		return fmt.Sprintf("bytes=%d", offset), nil
+	case length == 0:
+		return fmt.Sprintf("bytes=%d-", offset), nil
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1), nil
+}
+
+// parseContentRange parses the value of an HTTP Content-Range header, like 'bytes 0-99/1234'
+func parseContentRange(value string) (*ContentRange, error) {
+	rng, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return nil, fmt.Errorf("unexpected content range: %q", value)
+	}
+
+	bounds, size, found := strings.Cut(rng, "/")
+	if !found {
+		return nil, fmt.Errorf("unexpected content range: %q", value)
+	}
+
+	start, end, found := strings.Cut(bounds, "-")
+	if !found {
+		return nil, fmt.Errorf("unexpected content range: %q", value)
+	}
+
+	contentRange := &ContentRange{Size: -1}
+	var err error
+	if contentRange.Start, err = strconv.ParseInt(start, 10, 64); err != nil {
+		return nil, fmt.Errorf("unexpected content range: %q", value)
+	}
+	if contentRange.End, err = strconv.ParseInt(end, 10, 64); err != nil {
+		return nil, fmt.Errorf("unexpected content range: %q", value)
+	}
+	if size != "*" {
+		if contentRange.Size, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return nil, fmt.Errorf("unexpected content range: %q", value)
+		}
+	}
+	return contentRange, nil
+}
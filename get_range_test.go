@@ -0,0 +1,127 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetRange(t *testing.T) {
+	Convey("Given an S3 client that returns a content range for a ranged GetObject request", t, func() {
+		ctx := context.Background()
+		payload := []byte("time,geography")
+		contentRange := "bytes 0-13/4200"
+
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:         io.NopCloser(bytes.NewReader(payload)),
+					ContentRange: &contentRange,
+				}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetRange with an offset and length requests a closed range and returns it with the object size", func() {
+			ret, rng, err := cli.GetRange(ctx, testS3Key, 0, 14)
+			So(err, ShouldBeNil)
+			So(readBytes(ret), ShouldResemble, payload)
+			So(rng, ShouldResemble, &dps3.ContentRange{Start: 0, End: 13, Size: 4200})
+			So(rng.Length(), ShouldEqual, 14)
+			So(sdkMock.GetObjectCalls()[0].In, ShouldResemble, &s3.GetObjectInput{
+				Bucket: &testBucket,
+				Key:    &testS3Key,
+				Range:  aws.String("bytes=0-13"),
+			})
+		})
+
+		Convey("GetRange with a zero length requests an open-ended range", func() {
+			_, _, err := cli.GetRange(ctx, testS3Key, 100, 0)
+			So(err, ShouldBeNil)
+			So(*sdkMock.GetObjectCalls()[0].In.Range, ShouldEqual, "bytes=100-")
+		})
+
+		Convey("GetRange with a negative offset requests a suffix range", func() {
+			_, _, err := cli.GetRange(ctx, testS3Key, -500, 0)
+			So(err, ShouldBeNil)
+			So(*sdkMock.GetObjectCalls()[0].In.Range, ShouldEqual, "bytes=-500")
+		})
+
+		Convey("GetRange with a negative offset and a length fails with ErrInvalidRange without calling S3", func() {
+			_, _, err := cli.GetRange(ctx, testS3Key, -500, 10)
+			var errInvalidRange *dps3.ErrInvalidRange
+			So(errors.As(err, &errInvalidRange), ShouldBeTrue)
+			So(len(sdkMock.GetObjectCalls()), ShouldEqual, 0)
+		})
+
+		Convey("GetRange with a negative length fails with ErrInvalidRange without calling S3", func() {
+			_, _, err := cli.GetRange(ctx, testS3Key, 0, -1)
+			var errInvalidRange *dps3.ErrInvalidRange
+			So(errors.As(err, &errInvalidRange), ShouldBeTrue)
+			So(len(sdkMock.GetObjectCalls()), ShouldEqual, 0)
+		})
+	})
+
+	Convey("Given an S3 client that returns a content range with an unknown object size", t, func() {
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:         io.NopCloser(bytes.NewReader([]byte("value"))),
+					ContentRange: aws.String("bytes 10-14/*"),
+				}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetRange returns a size of -1", func() {
+			_, rng, err := cli.GetRange(context.Background(), testS3Key, 10, 5)
+			So(err, ShouldBeNil)
+			So(rng, ShouldResemble, &dps3.ContentRange{Start: 10, End: 14, Size: -1})
+		})
+	})
+
+	Convey("Given an S3 client that fails with an InvalidRange error", t, func() {
+		errRange := &smithy.GenericAPIError{Code: "InvalidRange", Message: "The requested range is not satisfiable"}
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return nil, errRange
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetRange returns an ErrInvalidRange error wrapping it", func() {
+			_, _, err := cli.GetRange(context.Background(), testS3Key, 5000, 10)
+			var errInvalidRange *dps3.ErrInvalidRange
+			So(errors.As(err, &errInvalidRange), ShouldBeTrue)
+			So(errors.Is(err, errRange), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an S3 client that returns a compressed object", t, func() {
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:         io.NopCloser(bytes.NewReader([]byte("value"))),
+					ContentRange: aws.String("bytes 0-4/100"),
+					Metadata:     map[string]string{dps3.CompressionMetadataKey: "gzip"},
+				}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetRange returns an error", func() {
+			_, _, err := cli.GetRange(context.Background(), testS3Key, 0, 5)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "byte ranges are not supported for compressed objects")
+		})
+	})
+}
@@ -2,18 +2,21 @@ package s3_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
 
 	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/crypto"
 	"github.com/ONSdigital/dp-s3/v3/mock"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -177,6 +180,78 @@ func TestGetWithPSK(t *testing.T) {
 	})
 }
 
+func TestGetWithPSKVerification(t *testing.T) {
+	Convey("Given an S3 client configured with a bucket and region", t, func() {
+		ctx := context.Background()
+
+		psk := []byte("test psk")
+		payload := []byte("test data")
+		bucket := "myBucket"
+		objKey := "my/object/key"
+		region := "eu-north-1"
+		digest := pskDigest(psk, payload)
+
+		Convey("When the crypto client fails because the psk does not match the object key check value", func() {
+			cryptoMock := &mock.S3CryptoClientMock{
+				GetObjectWithPSKFunc: func(ctx context.Context, input *s3.GetObjectInput, inPsk []byte) (*s3.GetObjectOutput, error) {
+					return nil, crypto.ErrWrongPSK
+				},
+			}
+			cli := dps3.InstantiateClient(nil, cryptoMock, nil, nil, bucket, region, aws.Config{})
+
+			Convey("GetWithPSK returns an ErrWrongPSK error", func() {
+				_, _, err := cli.GetWithPSK(ctx, objKey, psk)
+				So(err, ShouldResemble, dps3.NewWrongPSKError(
+					fmt.Errorf("error getting object from s3: %w", crypto.ErrWrongPSK),
+					log.Data{
+						"bucket_name": bucket,
+						"s3_key":      objKey,
+						"user_psk":    true,
+					},
+				))
+				So(errors.Is(err, crypto.ErrWrongPSK), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the object metadata holds the digest of its content", func() {
+			cryptoMock := &mock.S3CryptoClientMock{
+				GetObjectWithPSKFunc: func(ctx context.Context, input *s3.GetObjectInput, inPsk []byte) (*s3.GetObjectOutput, error) {
+					return &s3.GetObjectOutput{
+						Body:     io.NopCloser(bytes.NewReader(payload)),
+						Metadata: map[string]string{crypto.DigestMetadataKey: digest},
+					}, nil
+				},
+			}
+			cli := dps3.InstantiateClient(nil, cryptoMock, nil, nil, bucket, region, aws.Config{})
+
+			Convey("GetWithPSK returns a reader that streams the expected payload", func() {
+				ret, _, err := cli.GetWithPSK(ctx, objKey, psk)
+				So(err, ShouldBeNil)
+				So(readBytes(ret), ShouldResemble, payload)
+			})
+		})
+
+		Convey("When the object metadata holds a digest that does not match its content", func() {
+			cryptoMock := &mock.S3CryptoClientMock{
+				GetObjectWithPSKFunc: func(ctx context.Context, input *s3.GetObjectInput, inPsk []byte) (*s3.GetObjectOutput, error) {
+					return &s3.GetObjectOutput{
+						Body:     io.NopCloser(bytes.NewReader([]byte("corrupted data"))),
+						Metadata: map[string]string{crypto.DigestMetadataKey: digest},
+					}, nil
+				},
+			}
+			cli := dps3.InstantiateClient(nil, cryptoMock, nil, nil, bucket, region, aws.Config{})
+
+			Convey("GetWithPSK returns a reader that fails with ErrIntegrity at the end of the content", func() {
+				ret, _, err := cli.GetWithPSK(ctx, objKey, psk)
+				So(err, ShouldBeNil)
+				_, err = io.ReadAll(ret)
+				So(errors.Is(err, crypto.ErrIntegrity), ShouldBeTrue)
+			})
+		})
+	})
+}
+
 func TestFileExists(t *testing.T) {
 	ctx := context.Background()
 
@@ -319,6 +394,12 @@ func TestGetBucketPolicy(t *testing.T) {
 			So(err, ShouldBeNil)
 			So(out, ShouldResemble, expectedReturn)
 		})
+
+		Convey("GetBucketPolicy with an empty bucket name obtains the policy of the client bucket", func() {
+			_, err := cli.GetBucketPolicy(ctx, "")
+			So(err, ShouldBeNil)
+			So(*sdkMock.GetBucketPolicyCalls()[0].In.Bucket, ShouldEqual, bucket)
+		})
 	})
 
 	Convey("Given an S3 client that returns an error on a BucketPolicy request", t, func() {
@@ -371,6 +452,30 @@ func TestPutBucketPolicy(t *testing.T) {
 			So(err, ShouldBeNil)
 			So(out, ShouldResemble, expectedReturn)
 		})
+
+		Convey("PutBucketPolicy with an empty bucket name replaces the policy of the client bucket", func() {
+			_, err := cli.PutBucketPolicy(ctx, "", policy)
+			So(err, ShouldBeNil)
+			So(*sdkMock.PutBucketPolicyCalls()[0].In.Bucket, ShouldEqual, bucket)
+		})
+	})
+
+	Convey("Given an S3 client that returns a generic error on a PutBucketPolicy request", t, func() {
+		errPolicy := errors.New("BucketPolicy error")
+		sdkMock := &mock.S3SDKClientMock{
+			PutBucketPolicyFunc: func(ctx context.Context, in *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error) {
+				return nil, errPolicy
+			},
+		}
+		s3Cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, bucket, region, aws.Config{})
+
+		Convey("PutBucketPolicy returns the error wrapped in an S3Error", func() {
+			_, err := s3Cli.PutBucketPolicy(ctx, bucket, policy)
+			var s3Err *dps3.S3Error
+			So(errors.As(err, &s3Err), ShouldBeTrue)
+			So(errors.Is(err, errPolicy), ShouldBeTrue)
+			So(s3Err.LogData()["bucket_name"], ShouldEqual, bucket)
+		})
 	})
 
 	Convey("Given an S3 client that returns an error on a BucketPolicy request", t, func() {
@@ -382,8 +487,12 @@ func TestPutBucketPolicy(t *testing.T) {
 		s3Cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, bucket, region, aws.Config{})
 
 		Convey("BucketPolicy returns the expected error", func() {
-			_, err := s3Cli.PutBucketPolicy(ctx, bucket, policy)
-			So(err, ShouldBeNil)
+			out, err := s3Cli.PutBucketPolicy(ctx, bucket, policy)
+			So(out, ShouldBeNil)
+			var notFoundErr *types.NotFound
+			So(errors.As(err, &notFoundErr), ShouldBeTrue)
+			var s3Err *dps3.S3Error
+			So(errors.As(err, &s3Err), ShouldBeTrue)
 		})
 	})
 	Convey("Given an S3 client that returns an aws error on a BucketPolicy request", t, func() {
@@ -395,9 +504,12 @@ func TestPutBucketPolicy(t *testing.T) {
 		s3Cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, bucket, region, aws.Config{})
 
 		Convey("BucketPolicy returns the expected error", func() {
-			_, err := s3Cli.PutBucketPolicy(ctx, bucket, policy)
-			So(err, ShouldBeNil)
-
+			out, err := s3Cli.PutBucketPolicy(ctx, bucket, policy)
+			So(out, ShouldBeNil)
+			var notFoundErr *types.NotFound
+			So(errors.As(err, &notFoundErr), ShouldBeTrue)
+			var s3Err *dps3.S3Error
+			So(errors.As(err, &s3Err), ShouldBeTrue)
 		})
 	})
 }
@@ -454,3 +566,108 @@ func TestListObjects(t *testing.T) {
 		})
 	})
 }
+
+func TestGetObject(t *testing.T) {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	metadata := map[string]string{"dataset": "cpih"}
+
+	Convey("Given an S3 client that returns an object with its metadata", t, func() {
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:                 io.NopCloser(bytes.NewReader(testCSV)),
+					ContentLength:        aws.Int64(int64(len(testCSV))),
+					ContentType:          aws.String("text/csv"),
+					ContentDisposition:   aws.String("attachment"),
+					ETag:                 aws.String(`"abc"`),
+					LastModified:         &lastModified,
+					VersionId:            aws.String("v1"),
+					StorageClass:         types.StorageClassStandardIa,
+					ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+					SSEKMSKeyId:          aws.String("kms-key"),
+					Metadata:             metadata,
+				}, nil
+			},
+			HeadObjectFunc: func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return &s3.HeadObjectOutput{
+					ContentLength: aws.Int64(int64(len(testCSV))),
+					ContentType:   aws.String("text/csv"),
+					ETag:          aws.String(`"abc"`),
+					LastModified:  &lastModified,
+					VersionId:     aws.String("v1"),
+					Metadata:      metadata,
+				}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetObject returns the content along with the object info from the same response", func() {
+			ret, info, err := cli.GetObject(context.Background(), testS3Key)
+			So(err, ShouldBeNil)
+			So(readBytes(ret), ShouldResemble, testCSV)
+			So(info, ShouldResemble, &dps3.ObjectInfo{
+				Key:                  testS3Key,
+				ContentLength:        aws.Int64(int64(len(testCSV))),
+				ContentType:          "text/csv",
+				ContentDisposition:   "attachment",
+				ETag:                 `"abc"`,
+				LastModified:         lastModified,
+				VersionID:            "v1",
+				StorageClass:         types.StorageClassStandardIa,
+				Metadata:             metadata,
+				ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+				SSEKMSKeyID:          "kms-key",
+			})
+			So(len(sdkMock.HeadObjectCalls()), ShouldEqual, 0)
+		})
+
+		Convey("Head returns the same typed object info", func() {
+			info, err := cli.Head(context.Background(), testS3Key)
+			So(err, ShouldBeNil)
+			So(info, ShouldResemble, &dps3.ObjectInfo{
+				Key:           testS3Key,
+				ContentLength: aws.Int64(int64(len(testCSV))),
+				ContentType:   "text/csv",
+				ETag:          `"abc"`,
+				LastModified:  lastModified,
+				VersionID:     "v1",
+				Metadata:      metadata,
+			})
+		})
+	})
+
+	Convey("Given an S3 client that returns a compressed object encrypted with a psk", t, func() {
+		psk := []byte("test psk")
+		pskMetadata := crypto.SetPSKMetadata(map[string]string{
+			dps3.CompressionMetadataKey:        "gzip",
+			dps3.UncompressedLengthMetadataKey: "4200",
+		}, psk, 64)
+
+		var compressed bytes.Buffer
+		w := gzip.NewWriter(&compressed)
+		w.Write(testCSV)
+		w.Close()
+
+		cryptoMock := &mock.S3CryptoClientMock{
+			GetObjectWithPSKFunc: func(ctx context.Context, input *s3.GetObjectInput, inPsk []byte) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:          io.NopCloser(bytes.NewReader(compressed.Bytes())),
+					ContentLength: aws.Int64(int64(compressed.Len())),
+					ETag:          aws.String(`"abc"`),
+					Metadata:      pskMetadata,
+				}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(nil, cryptoMock, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetObjectWithPSK returns the decompressed content and reports the encryption, compression and uncompressed length", func() {
+			ret, info, err := cli.GetObjectWithPSK(context.Background(), testS3Key, psk)
+			So(err, ShouldBeNil)
+			So(readBytes(ret), ShouldResemble, testCSV)
+			So(info.PSKEncrypted, ShouldBeTrue)
+			So(info.Compression, ShouldEqual, dps3.CompressionGzip)
+			So(*info.ContentLength, ShouldEqual, len(testCSV))
+			So(info.ETag, ShouldEqual, `"abc"`)
+		})
+	})
+}
@@ -12,6 +12,7 @@ require (
 	github.com/aws/aws-sdk-go-v2/credentials v1.17.62
 	github.com/aws/aws-sdk-go-v2/feature/s3/manager v1.17.65
 	github.com/aws/aws-sdk-go-v2/service/s3 v1.78.1
+	github.com/klauspost/compress v1.18.0
 	github.com/smartystreets/goconvey v1.8.1
 )
 
@@ -36,7 +36,8 @@ func (cli *Client) Checker(ctx context.Context, state *health.CheckState) error
 }
 
 // handleAWSErr updates the provided CheckState with a Critical state and a message according to the provided AWS error.
-// For inexistent buckets, a relevant error message will be generated, for any other error we use the AWS Code (consice string).
+// For inexistent buckets, or buckets in a region different from the client one, a relevant error message will be generated,
+// for any other error we use the AWS Code (consice string).
 // Any error during the state update will be returned
 func (cli *Client) handleAWSErr(err error, state *health.CheckState) error {
 	var bucketNotFoundErr *types.NoSuchBucket
@@ -45,6 +46,11 @@ func (cli *Client) handleAWSErr(err error, state *health.CheckState) error {
 		return state.Update(health.StatusCritical, fmt.Sprintf("Bucket not found: %s", cli.bucketName), 0)
 	}
 
+	if region := regionFromError(err); region != "" && region != cli.region {
+		// Bucket in a different region
+		return state.Update(health.StatusCritical, fmt.Sprintf("Bucket %s is in region %s, not in client region %s", cli.bucketName, region, cli.region), 0)
+	}
+
 	var apiErr smithy.APIError
 	if errors.As(err, &apiErr) {
 		// Other AWS service error
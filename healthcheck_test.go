@@ -134,3 +134,25 @@ func TestBucketInexistentRegion(t *testing.T) {
 		})
 	})
 }
+
+func TestBucketRedirectedRegion(t *testing.T) {
+	Convey("Given that S3 client is available and S3 redirects HeadBucket calls to the region of the bucket", t, func() {
+		sdkMock := &mock.S3SDKClientMock{
+			HeadBucketFunc: func(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+				return nil, newRedirectError(ExpectedRegion)
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, UnexpectedRegion, aws.Config{})
+
+		// CheckState for test validation
+		checkState := health.NewCheckState(dps3.ServiceName)
+
+		Convey("Checker updates the CheckState to a critical state reporting the region of the bucket", func() {
+			cli.Checker(context.Background(), checkState)
+			So(len(sdkMock.HeadBucketCalls()), ShouldEqual, 1)
+			So(checkState.Status(), ShouldEqual, health.StatusCritical)
+			So(checkState.Message(), ShouldEqual, "Bucket "+ExistingBucket+" is in region "+ExpectedRegion+", not in client region "+UnexpectedRegion)
+			So(checkState.StatusCode(), ShouldEqual, 0)
+		})
+	})
+}
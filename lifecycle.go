@@ -0,0 +1,408 @@
+// file: lifecycle.go
+//
+// Contains methods to manage the lifecycle configuration of the bucket configured for the client with typed rules,
+// which expire objects, noncurrent versions and incomplete multipart uploads, or transition objects to other storage classes.
+// Rules can be replaced, merged by ID, and compared to report what would change before they are applied.
+//
+// Requires "s3:GetLifecycleConfiguration" and "s3:PutLifecycleConfiguration" actions allowed by IAM policy for the bucket.
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// MaxLifecycleRules is the maximum number of rules S3 allows in the lifecycle configuration of a bucket
+	MaxLifecycleRules = 1000
+
+	// maxLifecycleRuleIDLength is the maximum length S3 allows for the ID of a lifecycle rule
+	maxLifecycleRuleIDLength = 255
+
+	// minInfrequentAccessDays is the minimum age S3 allows for transitions to the infrequent access storage classes
+	minInfrequentAccessDays = 30
+
+	// errCodeNoSuchLifecycleConfiguration is the AWS error code returned when a bucket has no lifecycle configuration
+	errCodeNoSuchLifecycleConfiguration = "NoSuchLifecycleConfiguration"
+)
+
+// LifecycleRule represents a lifecycle rule of a bucket, which applies to the objects that match its prefix and tags.
+// Zero days mean that the corresponding action is not part of the rule.
+type LifecycleRule struct {
+	// ID identifies the rule, so that it can be replaced when rules are merged
+	ID      string
+	Enabled bool
+
+	// Prefix and Tags filter the objects the rule applies to. If both are empty, it applies to all the objects of the bucket.
+	Prefix string
+	Tags   map[string]string
+
+	// ExpirationDays is the age after which current object versions expire
+	ExpirationDays int32
+
+	// NoncurrentVersionExpirationDays is the number of days after which noncurrent versions are permanently deleted
+	NoncurrentVersionExpirationDays int32
+
+	// AbortIncompleteMultipartUploadDays is the number of days after which incomplete multipart uploads are aborted
+	AbortIncompleteMultipartUploadDays int32
+
+	// Transitions move current object versions to other storage classes once they reach an age
+	Transitions []LifecycleTransition
+}
+
+// LifecycleTransition represents the transition of objects to a storage class after a number of days
+type LifecycleTransition struct {
+	Days         int32
+	StorageClass types.TransitionStorageClass
+}
+
+// LifecycleDiff represents the changes between two sets of lifecycle rules, matched by ID and sorted by ID
+type LifecycleDiff struct {
+	Added   []LifecycleRule
+	Removed []LifecycleRule
+	Changed []LifecycleRuleChange
+}
+
+// LifecycleRuleChange represents a lifecycle rule whose settings change
+type LifecycleRuleChange struct {
+	Old LifecycleRule
+	New LifecycleRule
+}
+
+// IsEmpty returns true if there are no changes
+func (d LifecycleDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// GetLifecycleRules returns the lifecycle rules of the bucket configured for this client, which has none if it has no lifecycle configuration.
+// Settings not represented by LifecycleRule (e.g. object size filters or date based actions) are not returned.
+func (cli *Client) GetLifecycleRules(ctx context.Context) ([]LifecycleRule, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+	}
+
+	sdkRules, err := cli.getLifecycleConfiguration(ctx)
+	if err != nil {
+		return nil, NewError(err, logData)
+	}
+	rules := make([]LifecycleRule, len(sdkRules))
+	for i, rule := range sdkRules {
+		rules[i] = lifecycleRuleFromSDK(rule)
+	}
+	return rules, nil
+}
+
+// PutLifecycleRules replaces the lifecycle configuration of the bucket configured for this client with the provided rules,
+// which are validated against the limits of S3 before any request is sent. If no rules are provided, the lifecycle configuration is deleted.
+func (cli *Client) PutLifecycleRules(ctx context.Context, rules []LifecycleRule) error {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"num_rules":   len(rules),
+	}
+
+	if err := validateLifecycleRules(rules); err != nil {
+		return NewError(err, logData)
+	}
+
+	sdkRules := make([]types.LifecycleRule, len(rules))
+	for i, rule := range rules {
+		sdkRules[i] = rule.toSDK()
+	}
+	if err := cli.putLifecycleConfiguration(ctx, sdkRules); err != nil {
+		return NewError(err, logData)
+	}
+	return nil
+}
+
+// MergeLifecycleRules replaces the lifecycle rules of the bucket configured for this client that have the IDs of the provided rules,
+// and adds the provided rules whose IDs are new, keeping the rest of the rules as they are, including their settings not represented
+// by LifecycleRule. The changes are returned, and only applied if there are any and it is not a dry run.
+func (cli *Client) MergeLifecycleRules(ctx context.Context, rules []LifecycleRule, dryRun bool) (LifecycleDiff, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"num_rules":   len(rules),
+		"dry_run":     dryRun,
+	}
+
+	if err := validateLifecycleRules(rules); err != nil {
+		return LifecycleDiff{}, NewError(err, logData)
+	}
+
+	current, err := cli.getLifecycleConfiguration(ctx)
+	if err != nil {
+		return LifecycleDiff{}, NewError(err, logData)
+	}
+
+	merged, currentRules, mergedRules := mergeLifecycleRules(current, rules)
+	if len(merged) > MaxLifecycleRules {
+		return LifecycleDiff{}, NewError(fmt.Errorf("merged lifecycle configuration has %d rules, but buckets can have up to %d", len(merged), MaxLifecycleRules), logData)
+	}
+
+	diff := DiffLifecycleRules(currentRules, mergedRules)
+	if dryRun || diff.IsEmpty() {
+		return diff, nil
+	}
+	if err := cli.putLifecycleConfiguration(ctx, merged); err != nil {
+		return LifecycleDiff{}, NewError(err, logData)
+	}
+	return diff, nil
+}
+
+// DiffLifecycleRules returns the changes needed to go from the current lifecycle rules to the desired ones, matching them by ID
+func DiffLifecycleRules(current, desired []LifecycleRule) LifecycleDiff {
+	diff := LifecycleDiff{
+		Added:   []LifecycleRule{},
+		Removed: []LifecycleRule{},
+		Changed: []LifecycleRuleChange{},
+	}
+
+	currentByID := make(map[string]LifecycleRule, len(current))
+	for _, rule := range current {
+		currentByID[rule.ID] = rule
+	}
+	desiredIDs := make(map[string]struct{}, len(desired))
+	for _, rule := range desired {
+		desiredIDs[rule.ID] = struct{}{}
+		old, ok := currentByID[rule.ID]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, rule)
+		case !reflect.DeepEqual(old.normalised(), rule.normalised()):
+			diff.Changed = append(diff.Changed, LifecycleRuleChange{Old: old, New: rule})
+		}
+	}
+	for _, rule := range current {
+		if _, ok := desiredIDs[rule.ID]; !ok {
+			diff.Removed = append(diff.Removed, rule)
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].ID < diff.Added[j].ID })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].ID < diff.Removed[j].ID })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].New.ID < diff.Changed[j].New.ID })
+	return diff
+}
+
+// mergeLifecycleRules returns the provided SDK rules with the ones that have the IDs of the provided rules replaced,
+// followed by the provided rules with new IDs, along with the typed current and merged rules
+func mergeLifecycleRules(current []types.LifecycleRule, rules []LifecycleRule) ([]types.LifecycleRule, []LifecycleRule, []LifecycleRule) {
+	byID := make(map[string]LifecycleRule, len(rules))
+	for _, rule := range rules {
+		byID[rule.ID] = rule
+	}
+
+	merged := make([]types.LifecycleRule, 0, len(current)+len(rules))
+	currentRules := make([]LifecycleRule, 0, len(current))
+	mergedRules := make([]LifecycleRule, 0, len(current)+len(rules))
+	replaced := make(map[string]struct{}, len(rules))
+	for _, sdkRule := range current {
+		rule := lifecycleRuleFromSDK(sdkRule)
+		currentRules = append(currentRules, rule)
+		if newRule, ok := byID[rule.ID]; ok {
+			replaced[rule.ID] = struct{}{}
+			merged = append(merged, newRule.toSDK())
+			mergedRules = append(mergedRules, newRule)
+			continue
+		}
+		merged = append(merged, sdkRule)
+		mergedRules = append(mergedRules, rule)
+	}
+	for _, rule := range rules {
+		if _, ok := replaced[rule.ID]; !ok {
+			merged = append(merged, rule.toSDK())
+			mergedRules = append(mergedRules, rule)
+		}
+	}
+	return merged, currentRules, mergedRules
+}
+
+// getLifecycleConfiguration returns the lifecycle rules of the bucket, or none if it has no lifecycle configuration
+func (cli *Client) getLifecycleConfiguration(ctx context.Context) ([]types.LifecycleRule, error) {
+	out, err := cli.sdkClient.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(cli.bucketName),
+	})
+	if err != nil {
+		if errorCode(err) == errCodeNoSuchLifecycleConfiguration {
+			return []types.LifecycleRule{}, nil
+		}
+		return nil, fmt.Errorf("error getting bucket lifecycle configuration from s3: %w", err)
+	}
+	return out.Rules, nil
+}
+
+// putLifecycleConfiguration replaces the lifecycle rules of the bucket with the provided ones,
+// deleting its lifecycle configuration if there are none, as S3 does not allow empty configurations
+func (cli *Client) putLifecycleConfiguration(ctx context.Context, rules []types.LifecycleRule) error {
+	if len(rules) == 0 {
+		if _, err := cli.sdkClient.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{
+			Bucket: aws.String(cli.bucketName),
+		}); err != nil {
+			return fmt.Errorf("error deleting bucket lifecycle configuration from s3: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := cli.sdkClient.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(cli.bucketName),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: rules},
+	}); err != nil {
+		return fmt.Errorf("error putting bucket lifecycle configuration to s3: %w", err)
+	}
+	return nil
+}
+
+// validateLifecycleRules returns an error if the provided rules exceed the limits of S3:
+// up to MaxLifecycleRules rules with unique non-empty IDs, each of them with at least one action and valid days and tags
+func validateLifecycleRules(rules []LifecycleRule) error {
+	if len(rules) > MaxLifecycleRules {
+		return fmt.Errorf("%d lifecycle rules provided, but buckets can have up to %d", len(rules), MaxLifecycleRules)
+	}
+	ids := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("invalid lifecycle rule %q: %w", rule.ID, err)
+		}
+		if _, ok := ids[rule.ID]; ok {
+			return fmt.Errorf("lifecycle rule id %q is duplicated", rule.ID)
+		}
+		ids[rule.ID] = struct{}{}
+	}
+	return nil
+}
+
+// validate returns an error if the rule does not have an ID and at least one action, or its days or tags are not valid
+func (r LifecycleRule) validate() error {
+	if r.ID == "" || len(r.ID) > maxLifecycleRuleIDLength {
+		return fmt.Errorf("id must have between 1 and %d characters", maxLifecycleRuleIDLength)
+	}
+	if r.ExpirationDays < 0 || r.NoncurrentVersionExpirationDays < 0 || r.AbortIncompleteMultipartUploadDays < 0 {
+		return errors.New("days cannot be negative")
+	}
+	if r.ExpirationDays == 0 && r.NoncurrentVersionExpirationDays == 0 && r.AbortIncompleteMultipartUploadDays == 0 && len(r.Transitions) == 0 {
+		return errors.New("at least one action must be provided")
+	}
+	if err := ValidateTags(r.Tags); err != nil {
+		return err
+	}
+	if r.AbortIncompleteMultipartUploadDays > 0 && len(r.Tags) > 0 {
+		return errors.New("incomplete multipart uploads cannot be aborted by rules that filter by tags")
+	}
+
+	classes := make(map[types.TransitionStorageClass]struct{}, len(r.Transitions))
+	for _, t := range r.Transitions {
+		if t.StorageClass == "" {
+			return errors.New("transitions must have a storage class")
+		}
+		if _, ok := classes[t.StorageClass]; ok {
+			return fmt.Errorf("storage class %s has more than one transition", t.StorageClass)
+		}
+		classes[t.StorageClass] = struct{}{}
+		if t.Days < 0 {
+			return errors.New("days cannot be negative")
+		}
+		if (t.StorageClass == types.TransitionStorageClassStandardIa || t.StorageClass == types.TransitionStorageClassOnezoneIa) && t.Days < minInfrequentAccessDays {
+			return fmt.Errorf("transitions to %s must be after at least %d days", t.StorageClass, minInfrequentAccessDays)
+		}
+		if r.ExpirationDays > 0 && t.Days >= r.ExpirationDays {
+			return fmt.Errorf("transition to %s must be before the expiration", t.StorageClass)
+		}
+	}
+	return nil
+}
+
+// normalised returns a copy of the rule with its transitions sorted, and empty tags and transitions set to nil, so that rules can be compared
+func (r LifecycleRule) normalised() LifecycleRule {
+	if len(r.Tags) == 0 {
+		r.Tags = nil
+	}
+	if len(r.Transitions) == 0 {
+		r.Transitions = nil
+	} else {
+		r.Transitions = append([]LifecycleTransition(nil), r.Transitions...)
+		sort.Slice(r.Transitions, func(i, j int) bool {
+			return r.Transitions[i].Days < r.Transitions[j].Days
+		})
+	}
+	return r
+}
+
+// toSDK returns the SDK representation of the rule
+func (r LifecycleRule) toSDK() types.LifecycleRule {
+	rule := types.LifecycleRule{
+		ID:     aws.String(r.ID),
+		Status: types.ExpirationStatusDisabled,
+	}
+	if r.Enabled {
+		rule.Status = types.ExpirationStatusEnabled
+	}
+
+	switch tags := tagSet(r.Tags); {
+	case len(tags) == 0:
+		rule.Filter = &types.LifecycleRuleFilter{Prefix: aws.String(r.Prefix)}
+	case len(tags) == 1 && r.Prefix == "":
+		rule.Filter = &types.LifecycleRuleFilter{Tag: &tags[0]}
+	default:
+		rule.Filter = &types.LifecycleRuleFilter{And: &types.LifecycleRuleAndOperator{Prefix: aws.String(r.Prefix), Tags: tags}}
+	}
+
+	if r.ExpirationDays > 0 {
+		rule.Expiration = &types.LifecycleExpiration{Days: aws.Int32(r.ExpirationDays)}
+	}
+	if r.NoncurrentVersionExpirationDays > 0 {
+		rule.NoncurrentVersionExpiration = &types.NoncurrentVersionExpiration{NoncurrentDays: aws.Int32(r.NoncurrentVersionExpirationDays)}
+	}
+	if r.AbortIncompleteMultipartUploadDays > 0 {
+		rule.AbortIncompleteMultipartUpload = &types.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int32(r.AbortIncompleteMultipartUploadDays)}
+	}
+	for _, t := range r.Transitions {
+		rule.Transitions = append(rule.Transitions, types.Transition{Days: aws.Int32(t.Days), StorageClass: t.StorageClass})
+	}
+	return rule
+}
+
+// lifecycleRuleFromSDK returns the typed representation of the provided SDK rule, including its deprecated top level prefix
+func lifecycleRuleFromSDK(sdkRule types.LifecycleRule) LifecycleRule {
+	rule := LifecycleRule{
+		ID:      aws.ToString(sdkRule.ID),
+		Enabled: sdkRule.Status == types.ExpirationStatusEnabled,
+		Prefix:  aws.ToString(sdkRule.Prefix),
+	}
+
+	if f := sdkRule.Filter; f != nil {
+		switch {
+		case f.And != nil:
+			rule.Prefix = aws.ToString(f.And.Prefix)
+			if len(f.And.Tags) > 0 {
+				rule.Tags = tagMap(f.And.Tags)
+			}
+		case f.Tag != nil:
+			rule.Tags = tagMap([]types.Tag{*f.Tag})
+		case f.Prefix != nil:
+			rule.Prefix = *f.Prefix
+		}
+	}
+
+	if e := sdkRule.Expiration; e != nil {
+		rule.ExpirationDays = aws.ToInt32(e.Days)
+	}
+	if e := sdkRule.NoncurrentVersionExpiration; e != nil {
+		rule.NoncurrentVersionExpirationDays = aws.ToInt32(e.NoncurrentDays)
+	}
+	if a := sdkRule.AbortIncompleteMultipartUpload; a != nil {
+		rule.AbortIncompleteMultipartUploadDays = aws.ToInt32(a.DaysAfterInitiation)
+	}
+	for _, t := range sdkRule.Transitions {
+		if t.Days != nil {
+			rule.Transitions = append(rule.Transitions, LifecycleTransition{Days: *t.Days, StorageClass: t.StorageClass})
+		}
+	}
+	return rule
+}
@@ -0,0 +1,220 @@
+package s3_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// uploadsRule is a lifecycle rule that expires temporary uploads and aborts their incomplete multipart uploads
+var uploadsRule = dps3.LifecycleRule{
+	ID:                                 "expire-uploads",
+	Enabled:                            true,
+	Prefix:                             "uploads/",
+	ExpirationDays:                     7,
+	AbortIncompleteMultipartUploadDays: 1,
+}
+
+// archiveRule is a lifecycle rule that transitions old releases to cheaper storage classes
+var archiveRule = dps3.LifecycleRule{
+	ID:      "archive-releases",
+	Enabled: true,
+	Prefix:  "releases/",
+	Tags:    map[string]string{"classification": "public"},
+	Transitions: []dps3.LifecycleTransition{
+		{Days: 30, StorageClass: types.TransitionStorageClassStandardIa},
+		{Days: 365, StorageClass: types.TransitionStorageClassGlacier},
+	},
+	NoncurrentVersionExpirationDays: 90,
+}
+
+// newLifecycleMock returns an S3 client mock for a bucket with the provided lifecycle rules, or without lifecycle configuration if there are none,
+// which are replaced when they are put or deleted
+func newLifecycleMock(rules []types.LifecycleRule) *mock.S3SDKClientMock {
+	return &mock.S3SDKClientMock{
+		GetBucketLifecycleConfigurationFunc: func(ctx context.Context, in *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+			if len(rules) == 0 {
+				return nil, newResponseError(http.StatusNotFound, "NoSuchLifecycleConfiguration")
+			}
+			return &s3.GetBucketLifecycleConfigurationOutput{Rules: rules}, nil
+		},
+		PutBucketLifecycleConfigurationFunc: func(ctx context.Context, in *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+			rules = in.LifecycleConfiguration.Rules
+			return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+		},
+		DeleteBucketLifecycleFunc: func(ctx context.Context, in *s3.DeleteBucketLifecycleInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error) {
+			rules = nil
+			return &s3.DeleteBucketLifecycleOutput{}, nil
+		},
+	}
+}
+
+func TestLifecycleRules(t *testing.T) {
+	Convey("Given an S3 client for a bucket without lifecycle configuration", t, func() {
+		ctx := context.Background()
+		sdkMock := newLifecycleMock(nil)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetLifecycleRules returns no rules", func() {
+			rules, err := cli.GetLifecycleRules(ctx)
+			So(err, ShouldBeNil)
+			So(rules, ShouldBeEmpty)
+		})
+
+		Convey("PutLifecycleRules puts the rules, which are returned by GetLifecycleRules", func() {
+			err := cli.PutLifecycleRules(ctx, []dps3.LifecycleRule{uploadsRule, archiveRule})
+			So(err, ShouldBeNil)
+
+			in := sdkMock.PutBucketLifecycleConfigurationCalls()[0].In
+			So(*in.Bucket, ShouldEqual, ExistingBucket)
+			So(in.LifecycleConfiguration.Rules, ShouldHaveLength, 2)
+			uploads := in.LifecycleConfiguration.Rules[0]
+			So(uploads.Status, ShouldEqual, types.ExpirationStatusEnabled)
+			So(*uploads.Filter.Prefix, ShouldEqual, "uploads/")
+			So(*uploads.Expiration.Days, ShouldEqual, 7)
+			So(*uploads.AbortIncompleteMultipartUpload.DaysAfterInitiation, ShouldEqual, 1)
+			archive := in.LifecycleConfiguration.Rules[1]
+			So(*archive.Filter.And.Prefix, ShouldEqual, "releases/")
+			So(archive.Filter.And.Tags, ShouldResemble, []types.Tag{{Key: aws.String("classification"), Value: aws.String("public")}})
+			So(*archive.NoncurrentVersionExpiration.NoncurrentDays, ShouldEqual, 90)
+			So(archive.Transitions, ShouldHaveLength, 2)
+
+			rules, err := cli.GetLifecycleRules(ctx)
+			So(err, ShouldBeNil)
+			So(rules, ShouldResemble, []dps3.LifecycleRule{uploadsRule, archiveRule})
+
+			Convey("And PutLifecycleRules without rules deletes the lifecycle configuration", func() {
+				err := cli.PutLifecycleRules(ctx, nil)
+				So(err, ShouldBeNil)
+				So(sdkMock.DeleteBucketLifecycleCalls(), ShouldHaveLength, 1)
+				So(sdkMock.PutBucketLifecycleConfigurationCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("PutLifecycleRules fails without sending any request if the rules exceed the limits of S3", func() {
+			invalid := map[string]dps3.LifecycleRule{
+				"without id":          {ExpirationDays: 1},
+				"without action":      {ID: "noop"},
+				"negative days":       {ID: "negative", ExpirationDays: -1},
+				"early IA transition": {ID: "ia", Transitions: []dps3.LifecycleTransition{{Days: 10, StorageClass: types.TransitionStorageClassStandardIa}}},
+				"late transition":     {ID: "late", ExpirationDays: 30, Transitions: []dps3.LifecycleTransition{{Days: 30, StorageClass: types.TransitionStorageClassGlacier}}},
+				"tags and abort":      {ID: "abort", Tags: map[string]string{"a": "b"}, AbortIncompleteMultipartUploadDays: 1},
+				"long id":             {ID: strings.Repeat("r", 256), ExpirationDays: 1},
+			}
+			for name, rule := range invalid {
+				Convey(name, func() {
+					So(cli.PutLifecycleRules(ctx, []dps3.LifecycleRule{rule}), ShouldNotBeNil)
+				})
+			}
+			So(cli.PutLifecycleRules(ctx, []dps3.LifecycleRule{uploadsRule, uploadsRule}), ShouldNotBeNil)
+			So(sdkMock.PutBucketLifecycleConfigurationCalls(), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given an S3 client for a bucket that fails to return its lifecycle configuration", t, func() {
+		errLifecycle := errors.New("access denied")
+		sdkMock := &mock.S3SDKClientMock{
+			GetBucketLifecycleConfigurationFunc: func(ctx context.Context, in *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+				return nil, errLifecycle
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetLifecycleRules and MergeLifecycleRules fail with its error", func() {
+			_, err := cli.GetLifecycleRules(context.Background())
+			So(errors.Is(err, errLifecycle), ShouldBeTrue)
+			_, err = cli.MergeLifecycleRules(context.Background(), []dps3.LifecycleRule{uploadsRule}, false)
+			So(errors.Is(err, errLifecycle), ShouldBeTrue)
+		})
+	})
+}
+
+func TestMergeLifecycleRules(t *testing.T) {
+	Convey("Given an S3 client for a bucket with lifecycle rules, including settings not represented by LifecycleRule", t, func() {
+		ctx := context.Background()
+		manual := types.LifecycleRule{
+			ID:     aws.String("manual"),
+			Status: types.ExpirationStatusEnabled,
+			Filter: &types.LifecycleRuleFilter{ObjectSizeGreaterThan: aws.Int64(1024)},
+			Expiration: &types.LifecycleExpiration{
+				Date: aws.Time(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+			},
+		}
+		outdated := uploadsRule
+		outdated.ExpirationDays = 30
+		sdkMock := newLifecycleMock([]types.LifecycleRule{manual, outdatedToSDK(outdated)})
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("MergeLifecycleRules replaces the rules with the same ID, adds the new ones and keeps the rest as they are", func() {
+			diff, err := cli.MergeLifecycleRules(ctx, []dps3.LifecycleRule{uploadsRule, archiveRule}, false)
+			So(err, ShouldBeNil)
+			So(diff.Added, ShouldResemble, []dps3.LifecycleRule{archiveRule})
+			So(diff.Removed, ShouldBeEmpty)
+			So(diff.Changed, ShouldHaveLength, 1)
+			So(diff.Changed[0].Old.ExpirationDays, ShouldEqual, 30)
+			So(diff.Changed[0].New.ExpirationDays, ShouldEqual, 7)
+
+			rules := sdkMock.PutBucketLifecycleConfigurationCalls()[0].In.LifecycleConfiguration.Rules
+			So(rules, ShouldHaveLength, 3)
+			So(rules[0], ShouldResemble, manual)
+			So(*rules[1].Expiration.Days, ShouldEqual, 7)
+			So(*rules[2].ID, ShouldEqual, "archive-releases")
+
+			Convey("And merging the same rules again changes nothing", func() {
+				diff, err := cli.MergeLifecycleRules(ctx, []dps3.LifecycleRule{archiveRule, uploadsRule}, false)
+				So(err, ShouldBeNil)
+				So(diff.IsEmpty(), ShouldBeTrue)
+				So(sdkMock.PutBucketLifecycleConfigurationCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("MergeLifecycleRules in a dry run reports the changes without applying them", func() {
+			diff, err := cli.MergeLifecycleRules(ctx, []dps3.LifecycleRule{uploadsRule}, true)
+			So(err, ShouldBeNil)
+			So(diff.Changed, ShouldHaveLength, 1)
+			So(sdkMock.PutBucketLifecycleConfigurationCalls(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestDiffLifecycleRules(t *testing.T) {
+	Convey("DiffLifecycleRules reports the added, removed and changed rules by ID", t, func() {
+		disabled := archiveRule
+		disabled.Enabled = false
+		diff := dps3.DiffLifecycleRules([]dps3.LifecycleRule{uploadsRule, archiveRule}, []dps3.LifecycleRule{disabled, {ID: "new", ExpirationDays: 1}})
+		So(diff.Added, ShouldResemble, []dps3.LifecycleRule{{ID: "new", ExpirationDays: 1}})
+		So(diff.Removed, ShouldResemble, []dps3.LifecycleRule{uploadsRule})
+		So(diff.Changed, ShouldResemble, []dps3.LifecycleRuleChange{{Old: archiveRule, New: disabled}})
+		So(diff.IsEmpty(), ShouldBeFalse)
+	})
+
+	Convey("DiffLifecycleRules ignores the order of transitions and empty tags", t, func() {
+		reordered := archiveRule
+		reordered.Transitions = []dps3.LifecycleTransition{archiveRule.Transitions[1], archiveRule.Transitions[0]}
+		withEmptyTags := uploadsRule
+		withEmptyTags.Tags = map[string]string{}
+		diff := dps3.DiffLifecycleRules([]dps3.LifecycleRule{uploadsRule, archiveRule}, []dps3.LifecycleRule{withEmptyTags, reordered})
+		So(diff.IsEmpty(), ShouldBeTrue)
+	})
+}
+
+// outdatedToSDK returns the SDK representation of the provided rule, which only filters by prefix, as S3 returns it
+func outdatedToSDK(rule dps3.LifecycleRule) types.LifecycleRule {
+	return types.LifecycleRule{
+		ID:                             aws.String(rule.ID),
+		Status:                         types.ExpirationStatusEnabled,
+		Filter:                         &types.LifecycleRuleFilter{Prefix: aws.String(rule.Prefix)},
+		Expiration:                     &types.LifecycleExpiration{Days: aws.Int32(rule.ExpirationDays)},
+		AbortIncompleteMultipartUpload: &types.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int32(rule.AbortIncompleteMultipartUploadDays)},
+	}
+}
@@ -0,0 +1,131 @@
+// file: list.go
+//
+// Contains methods to list the objects of the bucket configured for the client,
+// going through all the pages of results, optionally grouping keys into "directories" by a delimiter.
+//
+// Requires "s3:ListBucket" action allowed by IAM policy for the bucket.
+package s3
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// maxListPageSize is the maximum number of keys returned by S3 in a single ListObjectsV2 response
+const maxListPageSize = 1000
+
+// ObjectSummary represents an object, or a common prefix, returned by List
+type ObjectSummary struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	StorageClass types.ObjectStorageClass
+
+	// IsPrefix is true for the common prefixes ("directories") returned when a delimiter is provided,
+	// in which case Key is the prefix, ending with the delimiter, and the other fields are not set.
+	IsPrefix bool
+}
+
+// ListOptions represents the options of List
+type ListOptions struct {
+	// Delimiter groups the keys that contain it after the prefix into a single common prefix, like a directory.
+	// For example, a "/" delimiter lists the objects and "directories" directly under the prefix only.
+	Delimiter string
+
+	// StartAfter makes the listing start after the provided key
+	StartAfter string
+
+	// MaxKeys is the maximum number of objects and common prefixes returned. Zero means there is no limit.
+	MaxKeys int
+}
+
+// List returns an iterator over the objects whose keys start with the provided prefix (inside the bucket configured for this client),
+// in lexicographical order, which requests further pages of results from S3 as they are needed.
+// If a delimiter is provided, common prefixes are returned along with the objects, with IsPrefix set.
+// If a request fails, the error is yielded and the iteration stops.
+func (cli *Client) List(ctx context.Context, prefix string, opts ListOptions) iter.Seq2[ObjectSummary, error] {
+	return func(yield func(ObjectSummary, error) bool) {
+		logData := log.Data{
+			"bucket_name": cli.bucketName,
+			"prefix":      prefix,
+			"delimiter":   opts.Delimiter,
+			"start_after": opts.StartAfter,
+			"max_keys":    opts.MaxKeys,
+		}
+
+		input := &s3.ListObjectsV2Input{
+			Bucket: aws.String(cli.bucketName),
+			Prefix: aws.String(prefix),
+		}
+		if opts.Delimiter != "" {
+			input.Delimiter = aws.String(opts.Delimiter)
+		}
+		if opts.StartAfter != "" {
+			input.StartAfter = aws.String(opts.StartAfter)
+		}
+
+		remaining := opts.MaxKeys
+		for {
+			page := *input
+			if opts.MaxKeys > 0 {
+				page.MaxKeys = aws.Int32(int32(min(remaining, maxListPageSize)))
+			}
+
+			result, err := cli.sdkClient.ListObjectsV2(ctx, &page)
+			if err != nil {
+				yield(ObjectSummary{}, NewError(fmt.Errorf("error listing objects from s3: %w", err), logData))
+				return
+			}
+
+			for _, summary := range pageSummaries(result) {
+				if !yield(summary, nil) {
+					return
+				}
+				if remaining--; opts.MaxKeys > 0 && remaining == 0 {
+					return
+				}
+			}
+
+			if !aws.ToBool(result.IsTruncated) || aws.ToString(result.NextContinuationToken) == "" {
+				return
+			}
+			input.ContinuationToken = result.NextContinuationToken
+		}
+	}
+}
+
+// pageSummaries returns the objects and common prefixes of a ListObjectsV2 response,
+// merged in lexicographical order, as S3 returns each of them in order separately.
+func pageSummaries(result *s3.ListObjectsV2Output) []ObjectSummary {
+	summaries := make([]ObjectSummary, 0, len(result.Contents)+len(result.CommonPrefixes))
+	i, j := 0, 0
+	for i < len(result.Contents) || j < len(result.CommonPrefixes) {
+		if j == len(result.CommonPrefixes) ||
+			(i < len(result.Contents) && aws.ToString(result.Contents[i].Key) < aws.ToString(result.CommonPrefixes[j].Prefix)) {
+			obj := result.Contents[i]
+			summaries = append(summaries, ObjectSummary{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				ETag:         aws.ToString(obj.ETag),
+				LastModified: aws.ToTime(obj.LastModified),
+				StorageClass: obj.StorageClass,
+			})
+			i++
+			continue
+		}
+		summaries = append(summaries, ObjectSummary{
+			Key:      aws.ToString(result.CommonPrefixes[j].Prefix),
+			IsPrefix: true,
+		})
+		j++
+	}
+	return summaries
+}
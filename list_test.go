@@ -0,0 +1,180 @@
+package s3_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newListMock returns an S3 client mock that lists the provided keys with ListObjectsV2 like S3 does,
+// in pages of up to pageSize keys and common prefixes
+func newListMock(keys []string, pageSize int) *mock.S3SDKClientMock {
+	sort.Strings(keys)
+	return &mock.S3SDKClientMock{
+		ListObjectsV2Func: func(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			limit := pageSize
+			if in.MaxKeys != nil {
+				limit = min(limit, int(*in.MaxKeys))
+			}
+			after := aws.ToString(in.StartAfter)
+			if in.ContinuationToken != nil {
+				after = *in.ContinuationToken
+			}
+
+			prefix, delimiter := aws.ToString(in.Prefix), aws.ToString(in.Delimiter)
+			out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+			last := ""
+			for _, key := range keys {
+				if key <= after || !strings.HasPrefix(key, prefix) {
+					continue
+				}
+				// keys under a common prefix that was already returned are skipped
+				if delimiter != "" && strings.HasSuffix(after, delimiter) && strings.HasPrefix(key, after) {
+					continue
+				}
+				entry := key
+				if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+					entry = key[:len(prefix)+i+len(delimiter)]
+				}
+				if entry == last {
+					continue
+				}
+				if len(out.Contents)+len(out.CommonPrefixes) == limit {
+					out.IsTruncated = aws.Bool(true)
+					out.NextContinuationToken = aws.String(last)
+					break
+				}
+				if entry != key {
+					out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(entry)})
+				} else {
+					out.Contents = append(out.Contents, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(key))), ETag: aws.String(`"` + key + `"`)})
+				}
+				last = entry
+			}
+			return out, nil
+		},
+	}
+}
+
+// collect returns the keys yielded by the provided iterator, marking common prefixes with a trailing '*', and the first error
+func collect(seq func(func(dps3.ObjectSummary, error) bool)) ([]string, error) {
+	keys := []string{}
+	for summary, err := range seq {
+		if err != nil {
+			return keys, err
+		}
+		if summary.IsPrefix {
+			keys = append(keys, summary.Key+"*")
+			continue
+		}
+		keys = append(keys, summary.Key)
+	}
+	return keys, nil
+}
+
+func TestList(t *testing.T) {
+	Convey("Given a bucket with objects under several prefixes, listed in pages of 2", t, func() {
+		ctx := context.Background()
+		keys := []string{
+			"data/a.csv", "data/b.csv", "data/c.csv",
+			"data/2023/x.csv", "data/2023/y.csv", "data/2024/z.csv",
+			"other/file.csv",
+		}
+		sdkMock := newListMock(keys, 2)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("List returns all the objects under the prefix, requesting every page", func() {
+			listed, err := collect(cli.List(ctx, "data/", dps3.ListOptions{}))
+			So(err, ShouldBeNil)
+			So(listed, ShouldResemble, []string{
+				"data/2023/x.csv", "data/2023/y.csv", "data/2024/z.csv",
+				"data/a.csv", "data/b.csv", "data/c.csv",
+			})
+			So(sdkMock.ListObjectsV2Calls(), ShouldHaveLength, 3)
+			So(*sdkMock.ListObjectsV2Calls()[0].In.Bucket, ShouldEqual, ExistingBucket)
+			So(sdkMock.ListObjectsV2Calls()[0].In.ContinuationToken, ShouldBeNil)
+			So(*sdkMock.ListObjectsV2Calls()[1].In.ContinuationToken, ShouldEqual, "data/2023/y.csv")
+		})
+
+		Convey("List returns the object summaries", func() {
+			for summary, err := range cli.List(ctx, "other/", dps3.ListOptions{}) {
+				So(err, ShouldBeNil)
+				So(summary, ShouldResemble, dps3.ObjectSummary{Key: "other/file.csv", Size: 14, ETag: `"other/file.csv"`})
+			}
+		})
+
+		Convey("List with a delimiter returns the objects and common prefixes directly under the prefix, in order", func() {
+			listed, err := collect(cli.List(ctx, "data/", dps3.ListOptions{Delimiter: "/"}))
+			So(err, ShouldBeNil)
+			So(listed, ShouldResemble, []string{"data/2023/*", "data/2024/*", "data/a.csv", "data/b.csv", "data/c.csv"})
+			So(*sdkMock.ListObjectsV2Calls()[0].In.Delimiter, ShouldEqual, "/")
+		})
+
+		Convey("List starts after the provided key", func() {
+			listed, err := collect(cli.List(ctx, "", dps3.ListOptions{StartAfter: "data/b.csv"}))
+			So(err, ShouldBeNil)
+			So(listed, ShouldResemble, []string{"data/c.csv", "other/file.csv"})
+			So(*sdkMock.ListObjectsV2Calls()[0].In.StartAfter, ShouldEqual, "data/b.csv")
+		})
+
+		Convey("List returns up to the provided max keys, without requesting more than needed", func() {
+			listed, err := collect(cli.List(ctx, "", dps3.ListOptions{MaxKeys: 3}))
+			So(err, ShouldBeNil)
+			So(listed, ShouldResemble, []string{"data/2023/x.csv", "data/2023/y.csv", "data/2024/z.csv"})
+			So(sdkMock.ListObjectsV2Calls(), ShouldHaveLength, 2)
+			So(*sdkMock.ListObjectsV2Calls()[0].In.MaxKeys, ShouldEqual, 3)
+			So(*sdkMock.ListObjectsV2Calls()[1].In.MaxKeys, ShouldEqual, 1)
+		})
+
+		Convey("List stops requesting pages when the caller stops iterating", func() {
+			for range cli.List(ctx, "data/", dps3.ListOptions{}) {
+				break
+			}
+			So(sdkMock.ListObjectsV2Calls(), ShouldHaveLength, 1)
+		})
+	})
+
+	Convey("Given a bucket with more than 1000 objects", t, func() {
+		keys := make([]string, 1500)
+		for i := range keys {
+			keys[i] = "key-" + strconv.Itoa(10000+i)
+		}
+		sdkMock := newListMock(keys, 1000)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("List returns all of them", func() {
+			listed, err := collect(cli.List(context.Background(), "", dps3.ListOptions{}))
+			So(err, ShouldBeNil)
+			So(listed, ShouldHaveLength, 1500)
+			So(sdkMock.ListObjectsV2Calls(), ShouldHaveLength, 2)
+			So(sdkMock.ListObjectsV2Calls()[0].In.MaxKeys, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a bucket whose listing fails", t, func() {
+		errList := errors.New("access denied")
+		sdkMock := &mock.S3SDKClientMock{
+			ListObjectsV2Func: func(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				return nil, errList
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("List yields the error and stops", func() {
+			listed, err := collect(cli.List(context.Background(), "", dps3.ListOptions{}))
+			So(errors.Is(err, errList), ShouldBeTrue)
+			So(listed, ShouldBeEmpty)
+			So(sdkMock.ListObjectsV2Calls(), ShouldHaveLength, 1)
+		})
+	})
+}
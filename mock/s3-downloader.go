@@ -0,0 +1,97 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	v3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	s3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"io"
+	"sync"
+)
+
+// Ensure, that S3SDKDownloaderMock does implement v3.S3SDKDownloader.
+// If this is not the case, regenerate this file with moq.
+var _ v3.S3SDKDownloader = &S3SDKDownloaderMock{}
+
+// S3SDKDownloaderMock is a mock implementation of v3.S3SDKDownloader.
+//
+//	func TestSomethingThatUsesS3SDKDownloader(t *testing.T) {
+//
+//		// make and configure a mocked v3.S3SDKDownloader
+//		mockedS3SDKDownloader := &S3SDKDownloaderMock{
+//			DownloadFunc: func(ctx context.Context, w io.WriterAt, in *s3.GetObjectInput, options ...func(*manager.Downloader)) (int64, error) {
+//				panic("mock out the Download method")
+//			},
+//		}
+//
+//		// use mockedS3SDKDownloader in code that requires v3.S3SDKDownloader
+//		// and then make assertions.
+//
+//	}
+type S3SDKDownloaderMock struct {
+	// DownloadFunc mocks the Download method.
+	DownloadFunc func(ctx context.Context, w io.WriterAt, in *s3.GetObjectInput, options ...func(*manager.Downloader)) (int64, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Download holds details about calls to the Download method.
+		Download []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// W is the w argument value.
+			W io.WriterAt
+			// In is the in argument value.
+			In *s3.GetObjectInput
+			// Options is the options argument value.
+			Options []func(*manager.Downloader)
+		}
+	}
+	lockDownload sync.RWMutex
+}
+
+// Download calls DownloadFunc.
+func (mock *S3SDKDownloaderMock) Download(ctx context.Context, w io.WriterAt, in *s3.GetObjectInput, options ...func(*manager.Downloader)) (int64, error) {
+	if mock.DownloadFunc == nil {
+		panic("S3SDKDownloaderMock.DownloadFunc: method is nil but S3SDKDownloader.Download was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		W       io.WriterAt
+		In      *s3.GetObjectInput
+		Options []func(*manager.Downloader)
+	}{
+		Ctx:     ctx,
+		W:       w,
+		In:      in,
+		Options: options,
+	}
+	mock.lockDownload.Lock()
+	mock.calls.Download = append(mock.calls.Download, callInfo)
+	mock.lockDownload.Unlock()
+	return mock.DownloadFunc(ctx, w, in, options...)
+}
+
+// DownloadCalls gets all the calls that were made to Download.
+// Check the length with:
+//
+//	len(mockedS3SDKDownloader.DownloadCalls())
+func (mock *S3SDKDownloaderMock) DownloadCalls() []struct {
+	Ctx     context.Context
+	W       io.WriterAt
+	In      *s3.GetObjectInput
+	Options []func(*manager.Downloader)
+} {
+	var calls []struct {
+		Ctx     context.Context
+		W       io.WriterAt
+		In      *s3.GetObjectInput
+		Options []func(*manager.Downloader)
+	}
+	mock.lockDownload.RLock()
+	calls = mock.calls.Download
+	mock.lockDownload.RUnlock()
+	return calls
+}
@@ -20,18 +20,54 @@ var _ v3.S3SDKClient = &S3SDKClientMock{}
 //
 //		// make and configure a mocked v3.S3SDKClient
 //		mockedS3SDKClient := &S3SDKClientMock{
+//			AbortMultipartUploadFunc: func(ctx context.Context, in *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+//				panic("mock out the AbortMultipartUpload method")
+//			},
 //			CompleteMultipartUploadFunc: func(ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
 //				panic("mock out the CompleteMultipartUpload method")
 //			},
+//			CopyObjectFunc: func(ctx context.Context, in *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+//				panic("mock out the CopyObject method")
+//			},
 //			CreateMultipartUploadFunc: func(ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
 //				panic("mock out the CreateMultipartUpload method")
 //			},
+//			DeleteBucketLifecycleFunc: func(ctx context.Context, in *s3.DeleteBucketLifecycleInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error) {
+//				panic("mock out the DeleteBucketLifecycle method")
+//			},
+//			DeleteBucketPolicyFunc: func(ctx context.Context, in *s3.DeleteBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketPolicyOutput, error) {
+//				panic("mock out the DeleteBucketPolicy method")
+//			},
+//			DeleteObjectFunc: func(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+//				panic("mock out the DeleteObject method")
+//			},
+//			DeleteObjectTaggingFunc: func(ctx context.Context, in *s3.DeleteObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectTaggingOutput, error) {
+//				panic("mock out the DeleteObjectTagging method")
+//			},
+//			DeleteObjectsFunc: func(ctx context.Context, in *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+//				panic("mock out the DeleteObjects method")
+//			},
+//			GetBucketLifecycleConfigurationFunc: func(ctx context.Context, in *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+//				panic("mock out the GetBucketLifecycleConfiguration method")
+//			},
+//			GetBucketLocationFunc: func(ctx context.Context, in *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+//				panic("mock out the GetBucketLocation method")
+//			},
 //			GetBucketPolicyFunc: func(ctx context.Context, in *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
 //				panic("mock out the GetBucketPolicy method")
 //			},
 //			GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
 //				panic("mock out the GetObject method")
 //			},
+//			GetObjectLegalHoldFunc: func(ctx context.Context, in *s3.GetObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error) {
+//				panic("mock out the GetObjectLegalHold method")
+//			},
+//			GetObjectRetentionFunc: func(ctx context.Context, in *s3.GetObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.GetObjectRetentionOutput, error) {
+//				panic("mock out the GetObjectRetention method")
+//			},
+//			GetObjectTaggingFunc: func(ctx context.Context, in *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+//				panic("mock out the GetObjectTagging method")
+//			},
 //			HeadBucketFunc: func(ctx context.Context, in *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
 //				panic("mock out the HeadBucket method")
 //			},
@@ -41,18 +77,42 @@ var _ v3.S3SDKClient = &S3SDKClientMock{}
 //			ListMultipartUploadsFunc: func(ctx context.Context, in *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
 //				panic("mock out the ListMultipartUploads method")
 //			},
+//			ListObjectVersionsFunc: func(ctx context.Context, in *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+//				panic("mock out the ListObjectVersions method")
+//			},
 //			ListObjectsFunc: func(ctx context.Context, in *s3.ListObjectsInput, optFns ...func(*s3.Options)) (*s3.ListObjectsOutput, error) {
 //				panic("mock out the ListObjects method")
 //			},
+//			ListObjectsV2Func: func(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+//				panic("mock out the ListObjectsV2 method")
+//			},
 //			ListPartsFunc: func(ctx context.Context, in *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
 //				panic("mock out the ListParts method")
 //			},
+//			PutBucketLifecycleConfigurationFunc: func(ctx context.Context, in *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+//				panic("mock out the PutBucketLifecycleConfiguration method")
+//			},
 //			PutBucketPolicyFunc: func(ctx context.Context, in *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error) {
 //				panic("mock out the PutBucketPolicy method")
 //			},
+//			PutObjectFunc: func(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+//				panic("mock out the PutObject method")
+//			},
+//			PutObjectLegalHoldFunc: func(ctx context.Context, in *s3.PutObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.PutObjectLegalHoldOutput, error) {
+//				panic("mock out the PutObjectLegalHold method")
+//			},
+//			PutObjectRetentionFunc: func(ctx context.Context, in *s3.PutObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.PutObjectRetentionOutput, error) {
+//				panic("mock out the PutObjectRetention method")
+//			},
+//			PutObjectTaggingFunc: func(ctx context.Context, in *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+//				panic("mock out the PutObjectTagging method")
+//			},
 //			UploadPartFunc: func(ctx context.Context, in *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
 //				panic("mock out the UploadPart method")
 //			},
+//			UploadPartCopyFunc: func(ctx context.Context, in *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+//				panic("mock out the UploadPartCopy method")
+//			},
 //		}
 //
 //		// use mockedS3SDKClient in code that requires v3.S3SDKClient
@@ -60,18 +120,54 @@ var _ v3.S3SDKClient = &S3SDKClientMock{}
 //
 //	}
 type S3SDKClientMock struct {
+	// AbortMultipartUploadFunc mocks the AbortMultipartUpload method.
+	AbortMultipartUploadFunc func(ctx context.Context, in *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+
 	// CompleteMultipartUploadFunc mocks the CompleteMultipartUpload method.
 	CompleteMultipartUploadFunc func(ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
 
+	// CopyObjectFunc mocks the CopyObject method.
+	CopyObjectFunc func(ctx context.Context, in *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+
 	// CreateMultipartUploadFunc mocks the CreateMultipartUpload method.
 	CreateMultipartUploadFunc func(ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
 
+	// DeleteBucketLifecycleFunc mocks the DeleteBucketLifecycle method.
+	DeleteBucketLifecycleFunc func(ctx context.Context, in *s3.DeleteBucketLifecycleInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error)
+
+	// DeleteBucketPolicyFunc mocks the DeleteBucketPolicy method.
+	DeleteBucketPolicyFunc func(ctx context.Context, in *s3.DeleteBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketPolicyOutput, error)
+
+	// DeleteObjectFunc mocks the DeleteObject method.
+	DeleteObjectFunc func(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+
+	// DeleteObjectTaggingFunc mocks the DeleteObjectTagging method.
+	DeleteObjectTaggingFunc func(ctx context.Context, in *s3.DeleteObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectTaggingOutput, error)
+
+	// DeleteObjectsFunc mocks the DeleteObjects method.
+	DeleteObjectsFunc func(ctx context.Context, in *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+
+	// GetBucketLifecycleConfigurationFunc mocks the GetBucketLifecycleConfiguration method.
+	GetBucketLifecycleConfigurationFunc func(ctx context.Context, in *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error)
+
+	// GetBucketLocationFunc mocks the GetBucketLocation method.
+	GetBucketLocationFunc func(ctx context.Context, in *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error)
+
 	// GetBucketPolicyFunc mocks the GetBucketPolicy method.
 	GetBucketPolicyFunc func(ctx context.Context, in *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error)
 
 	// GetObjectFunc mocks the GetObject method.
 	GetObjectFunc func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 
+	// GetObjectLegalHoldFunc mocks the GetObjectLegalHold method.
+	GetObjectLegalHoldFunc func(ctx context.Context, in *s3.GetObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error)
+
+	// GetObjectRetentionFunc mocks the GetObjectRetention method.
+	GetObjectRetentionFunc func(ctx context.Context, in *s3.GetObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.GetObjectRetentionOutput, error)
+
+	// GetObjectTaggingFunc mocks the GetObjectTagging method.
+	GetObjectTaggingFunc func(ctx context.Context, in *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+
 	// HeadBucketFunc mocks the HeadBucket method.
 	HeadBucketFunc func(ctx context.Context, in *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
 
@@ -81,20 +177,53 @@ type S3SDKClientMock struct {
 	// ListMultipartUploadsFunc mocks the ListMultipartUploads method.
 	ListMultipartUploadsFunc func(ctx context.Context, in *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error)
 
+	// ListObjectVersionsFunc mocks the ListObjectVersions method.
+	ListObjectVersionsFunc func(ctx context.Context, in *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+
 	// ListObjectsFunc mocks the ListObjects method.
 	ListObjectsFunc func(ctx context.Context, in *s3.ListObjectsInput, optFns ...func(*s3.Options)) (*s3.ListObjectsOutput, error)
 
+	// ListObjectsV2Func mocks the ListObjectsV2 method.
+	ListObjectsV2Func func(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+
 	// ListPartsFunc mocks the ListParts method.
 	ListPartsFunc func(ctx context.Context, in *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error)
 
+	// PutBucketLifecycleConfigurationFunc mocks the PutBucketLifecycleConfiguration method.
+	PutBucketLifecycleConfigurationFunc func(ctx context.Context, in *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error)
+
 	// PutBucketPolicyFunc mocks the PutBucketPolicy method.
 	PutBucketPolicyFunc func(ctx context.Context, in *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error)
 
+	// PutObjectFunc mocks the PutObject method.
+	PutObjectFunc func(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+
+	// PutObjectLegalHoldFunc mocks the PutObjectLegalHold method.
+	PutObjectLegalHoldFunc func(ctx context.Context, in *s3.PutObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.PutObjectLegalHoldOutput, error)
+
+	// PutObjectRetentionFunc mocks the PutObjectRetention method.
+	PutObjectRetentionFunc func(ctx context.Context, in *s3.PutObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.PutObjectRetentionOutput, error)
+
+	// PutObjectTaggingFunc mocks the PutObjectTagging method.
+	PutObjectTaggingFunc func(ctx context.Context, in *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
+
 	// UploadPartFunc mocks the UploadPart method.
 	UploadPartFunc func(ctx context.Context, in *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
 
+	// UploadPartCopyFunc mocks the UploadPartCopy method.
+	UploadPartCopyFunc func(ctx context.Context, in *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// AbortMultipartUpload holds details about calls to the AbortMultipartUpload method.
+		AbortMultipartUpload []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.AbortMultipartUploadInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
 		// CompleteMultipartUpload holds details about calls to the CompleteMultipartUpload method.
 		CompleteMultipartUpload []struct {
 			// Ctx is the ctx argument value.
@@ -104,6 +233,15 @@ type S3SDKClientMock struct {
 			// OptFns is the optFns argument value.
 			OptFns []func(*s3.Options)
 		}
+		// CopyObject holds details about calls to the CopyObject method.
+		CopyObject []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.CopyObjectInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
 		// CreateMultipartUpload holds details about calls to the CreateMultipartUpload method.
 		CreateMultipartUpload []struct {
 			// Ctx is the ctx argument value.
@@ -113,6 +251,69 @@ type S3SDKClientMock struct {
 			// OptFns is the optFns argument value.
 			OptFns []func(*s3.Options)
 		}
+		// DeleteBucketLifecycle holds details about calls to the DeleteBucketLifecycle method.
+		DeleteBucketLifecycle []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.DeleteBucketLifecycleInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+		// DeleteBucketPolicy holds details about calls to the DeleteBucketPolicy method.
+		DeleteBucketPolicy []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.DeleteBucketPolicyInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+		// DeleteObject holds details about calls to the DeleteObject method.
+		DeleteObject []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.DeleteObjectInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+		// DeleteObjectTagging holds details about calls to the DeleteObjectTagging method.
+		DeleteObjectTagging []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.DeleteObjectTaggingInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+		// DeleteObjects holds details about calls to the DeleteObjects method.
+		DeleteObjects []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.DeleteObjectsInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+		// GetBucketLifecycleConfiguration holds details about calls to the GetBucketLifecycleConfiguration method.
+		GetBucketLifecycleConfiguration []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.GetBucketLifecycleConfigurationInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+		// GetBucketLocation holds details about calls to the GetBucketLocation method.
+		GetBucketLocation []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.GetBucketLocationInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
 		// GetBucketPolicy holds details about calls to the GetBucketPolicy method.
 		GetBucketPolicy []struct {
 			// Ctx is the ctx argument value.
@@ -131,6 +332,33 @@ type S3SDKClientMock struct {
 			// OptFns is the optFns argument value.
 			OptFns []func(*s3.Options)
 		}
+		// GetObjectLegalHold holds details about calls to the GetObjectLegalHold method.
+		GetObjectLegalHold []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.GetObjectLegalHoldInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+		// GetObjectRetention holds details about calls to the GetObjectRetention method.
+		GetObjectRetention []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.GetObjectRetentionInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+		// GetObjectTagging holds details about calls to the GetObjectTagging method.
+		GetObjectTagging []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.GetObjectTaggingInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
 		// HeadBucket holds details about calls to the HeadBucket method.
 		HeadBucket []struct {
 			// Ctx is the ctx argument value.
@@ -158,6 +386,15 @@ type S3SDKClientMock struct {
 			// OptFns is the optFns argument value.
 			OptFns []func(*s3.Options)
 		}
+		// ListObjectVersions holds details about calls to the ListObjectVersions method.
+		ListObjectVersions []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.ListObjectVersionsInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
 		// ListObjects holds details about calls to the ListObjects method.
 		ListObjects []struct {
 			// Ctx is the ctx argument value.
@@ -167,6 +404,15 @@ type S3SDKClientMock struct {
 			// OptFns is the optFns argument value.
 			OptFns []func(*s3.Options)
 		}
+		// ListObjectsV2 holds details about calls to the ListObjectsV2 method.
+		ListObjectsV2 []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.ListObjectsV2Input
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
 		// ListParts holds details about calls to the ListParts method.
 		ListParts []struct {
 			// Ctx is the ctx argument value.
@@ -176,6 +422,15 @@ type S3SDKClientMock struct {
 			// OptFns is the optFns argument value.
 			OptFns []func(*s3.Options)
 		}
+		// PutBucketLifecycleConfiguration holds details about calls to the PutBucketLifecycleConfiguration method.
+		PutBucketLifecycleConfiguration []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.PutBucketLifecycleConfigurationInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
 		// PutBucketPolicy holds details about calls to the PutBucketPolicy method.
 		PutBucketPolicy []struct {
 			// Ctx is the ctx argument value.
@@ -185,6 +440,42 @@ type S3SDKClientMock struct {
 			// OptFns is the optFns argument value.
 			OptFns []func(*s3.Options)
 		}
+		// PutObject holds details about calls to the PutObject method.
+		PutObject []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.PutObjectInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+		// PutObjectLegalHold holds details about calls to the PutObjectLegalHold method.
+		PutObjectLegalHold []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.PutObjectLegalHoldInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+		// PutObjectRetention holds details about calls to the PutObjectRetention method.
+		PutObjectRetention []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.PutObjectRetentionInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+		// PutObjectTagging holds details about calls to the PutObjectTagging method.
+		PutObjectTagging []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.PutObjectTaggingInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
 		// UploadPart holds details about calls to the UploadPart method.
 		UploadPart []struct {
 			// Ctx is the ctx argument value.
@@ -194,18 +485,87 @@ type S3SDKClientMock struct {
 			// OptFns is the optFns argument value.
 			OptFns []func(*s3.Options)
 		}
+		// UploadPartCopy holds details about calls to the UploadPartCopy method.
+		UploadPartCopy []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// In is the in argument value.
+			In *s3.UploadPartCopyInput
+			// OptFns is the optFns argument value.
+			OptFns []func(*s3.Options)
+		}
+	}
+	lockAbortMultipartUpload            sync.RWMutex
+	lockCompleteMultipartUpload         sync.RWMutex
+	lockCopyObject                      sync.RWMutex
+	lockCreateMultipartUpload           sync.RWMutex
+	lockDeleteBucketLifecycle           sync.RWMutex
+	lockDeleteBucketPolicy              sync.RWMutex
+	lockDeleteObject                    sync.RWMutex
+	lockDeleteObjectTagging             sync.RWMutex
+	lockDeleteObjects                   sync.RWMutex
+	lockGetBucketLifecycleConfiguration sync.RWMutex
+	lockGetBucketLocation               sync.RWMutex
+	lockGetBucketPolicy                 sync.RWMutex
+	lockGetObject                       sync.RWMutex
+	lockGetObjectLegalHold              sync.RWMutex
+	lockGetObjectRetention              sync.RWMutex
+	lockGetObjectTagging                sync.RWMutex
+	lockHeadBucket                      sync.RWMutex
+	lockHeadObject                      sync.RWMutex
+	lockListMultipartUploads            sync.RWMutex
+	lockListObjectVersions              sync.RWMutex
+	lockListObjects                     sync.RWMutex
+	lockListObjectsV2                   sync.RWMutex
+	lockListParts                       sync.RWMutex
+	lockPutBucketLifecycleConfiguration sync.RWMutex
+	lockPutBucketPolicy                 sync.RWMutex
+	lockPutObject                       sync.RWMutex
+	lockPutObjectLegalHold              sync.RWMutex
+	lockPutObjectRetention              sync.RWMutex
+	lockPutObjectTagging                sync.RWMutex
+	lockUploadPart                      sync.RWMutex
+	lockUploadPartCopy                  sync.RWMutex
+}
+
+// AbortMultipartUpload calls AbortMultipartUploadFunc.
+func (mock *S3SDKClientMock) AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	if mock.AbortMultipartUploadFunc == nil {
+		panic("S3SDKClientMock.AbortMultipartUploadFunc: method is nil but S3SDKClient.AbortMultipartUpload was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.AbortMultipartUploadInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
 	}
-	lockCompleteMultipartUpload sync.RWMutex
-	lockCreateMultipartUpload   sync.RWMutex
-	lockGetBucketPolicy         sync.RWMutex
-	lockGetObject               sync.RWMutex
-	lockHeadBucket              sync.RWMutex
-	lockHeadObject              sync.RWMutex
-	lockListMultipartUploads    sync.RWMutex
-	lockListObjects             sync.RWMutex
-	lockListParts               sync.RWMutex
-	lockPutBucketPolicy         sync.RWMutex
-	lockUploadPart              sync.RWMutex
+	mock.lockAbortMultipartUpload.Lock()
+	mock.calls.AbortMultipartUpload = append(mock.calls.AbortMultipartUpload, callInfo)
+	mock.lockAbortMultipartUpload.Unlock()
+	return mock.AbortMultipartUploadFunc(ctx, in, optFns...)
+}
+
+// AbortMultipartUploadCalls gets all the calls that were made to AbortMultipartUpload.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.AbortMultipartUploadCalls())
+func (mock *S3SDKClientMock) AbortMultipartUploadCalls() []struct {
+	Ctx    context.Context
+	In     *s3.AbortMultipartUploadInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.AbortMultipartUploadInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockAbortMultipartUpload.RLock()
+	calls = mock.calls.AbortMultipartUpload
+	mock.lockAbortMultipartUpload.RUnlock()
+	return calls
 }
 
 // CompleteMultipartUpload calls CompleteMultipartUploadFunc.
@@ -248,6 +608,46 @@ func (mock *S3SDKClientMock) CompleteMultipartUploadCalls() []struct {
 	return calls
 }
 
+// CopyObject calls CopyObjectFunc.
+func (mock *S3SDKClientMock) CopyObject(ctx context.Context, in *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	if mock.CopyObjectFunc == nil {
+		panic("S3SDKClientMock.CopyObjectFunc: method is nil but S3SDKClient.CopyObject was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.CopyObjectInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockCopyObject.Lock()
+	mock.calls.CopyObject = append(mock.calls.CopyObject, callInfo)
+	mock.lockCopyObject.Unlock()
+	return mock.CopyObjectFunc(ctx, in, optFns...)
+}
+
+// CopyObjectCalls gets all the calls that were made to CopyObject.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.CopyObjectCalls())
+func (mock *S3SDKClientMock) CopyObjectCalls() []struct {
+	Ctx    context.Context
+	In     *s3.CopyObjectInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.CopyObjectInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockCopyObject.RLock()
+	calls = mock.calls.CopyObject
+	mock.lockCopyObject.RUnlock()
+	return calls
+}
+
 // CreateMultipartUpload calls CreateMultipartUploadFunc.
 func (mock *S3SDKClientMock) CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
 	if mock.CreateMultipartUploadFunc == nil {
@@ -282,9 +682,289 @@ func (mock *S3SDKClientMock) CreateMultipartUploadCalls() []struct {
 		In     *s3.CreateMultipartUploadInput
 		OptFns []func(*s3.Options)
 	}
-	mock.lockCreateMultipartUpload.RLock()
-	calls = mock.calls.CreateMultipartUpload
-	mock.lockCreateMultipartUpload.RUnlock()
+	mock.lockCreateMultipartUpload.RLock()
+	calls = mock.calls.CreateMultipartUpload
+	mock.lockCreateMultipartUpload.RUnlock()
+	return calls
+}
+
+// DeleteBucketLifecycle calls DeleteBucketLifecycleFunc.
+func (mock *S3SDKClientMock) DeleteBucketLifecycle(ctx context.Context, in *s3.DeleteBucketLifecycleInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error) {
+	if mock.DeleteBucketLifecycleFunc == nil {
+		panic("S3SDKClientMock.DeleteBucketLifecycleFunc: method is nil but S3SDKClient.DeleteBucketLifecycle was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.DeleteBucketLifecycleInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockDeleteBucketLifecycle.Lock()
+	mock.calls.DeleteBucketLifecycle = append(mock.calls.DeleteBucketLifecycle, callInfo)
+	mock.lockDeleteBucketLifecycle.Unlock()
+	return mock.DeleteBucketLifecycleFunc(ctx, in, optFns...)
+}
+
+// DeleteBucketLifecycleCalls gets all the calls that were made to DeleteBucketLifecycle.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.DeleteBucketLifecycleCalls())
+func (mock *S3SDKClientMock) DeleteBucketLifecycleCalls() []struct {
+	Ctx    context.Context
+	In     *s3.DeleteBucketLifecycleInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.DeleteBucketLifecycleInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockDeleteBucketLifecycle.RLock()
+	calls = mock.calls.DeleteBucketLifecycle
+	mock.lockDeleteBucketLifecycle.RUnlock()
+	return calls
+}
+
+// DeleteBucketPolicy calls DeleteBucketPolicyFunc.
+func (mock *S3SDKClientMock) DeleteBucketPolicy(ctx context.Context, in *s3.DeleteBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketPolicyOutput, error) {
+	if mock.DeleteBucketPolicyFunc == nil {
+		panic("S3SDKClientMock.DeleteBucketPolicyFunc: method is nil but S3SDKClient.DeleteBucketPolicy was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.DeleteBucketPolicyInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockDeleteBucketPolicy.Lock()
+	mock.calls.DeleteBucketPolicy = append(mock.calls.DeleteBucketPolicy, callInfo)
+	mock.lockDeleteBucketPolicy.Unlock()
+	return mock.DeleteBucketPolicyFunc(ctx, in, optFns...)
+}
+
+// DeleteBucketPolicyCalls gets all the calls that were made to DeleteBucketPolicy.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.DeleteBucketPolicyCalls())
+func (mock *S3SDKClientMock) DeleteBucketPolicyCalls() []struct {
+	Ctx    context.Context
+	In     *s3.DeleteBucketPolicyInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.DeleteBucketPolicyInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockDeleteBucketPolicy.RLock()
+	calls = mock.calls.DeleteBucketPolicy
+	mock.lockDeleteBucketPolicy.RUnlock()
+	return calls
+}
+
+// DeleteObject calls DeleteObjectFunc.
+func (mock *S3SDKClientMock) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	if mock.DeleteObjectFunc == nil {
+		panic("S3SDKClientMock.DeleteObjectFunc: method is nil but S3SDKClient.DeleteObject was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.DeleteObjectInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockDeleteObject.Lock()
+	mock.calls.DeleteObject = append(mock.calls.DeleteObject, callInfo)
+	mock.lockDeleteObject.Unlock()
+	return mock.DeleteObjectFunc(ctx, in, optFns...)
+}
+
+// DeleteObjectCalls gets all the calls that were made to DeleteObject.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.DeleteObjectCalls())
+func (mock *S3SDKClientMock) DeleteObjectCalls() []struct {
+	Ctx    context.Context
+	In     *s3.DeleteObjectInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.DeleteObjectInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockDeleteObject.RLock()
+	calls = mock.calls.DeleteObject
+	mock.lockDeleteObject.RUnlock()
+	return calls
+}
+
+// DeleteObjectTagging calls DeleteObjectTaggingFunc.
+func (mock *S3SDKClientMock) DeleteObjectTagging(ctx context.Context, in *s3.DeleteObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectTaggingOutput, error) {
+	if mock.DeleteObjectTaggingFunc == nil {
+		panic("S3SDKClientMock.DeleteObjectTaggingFunc: method is nil but S3SDKClient.DeleteObjectTagging was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.DeleteObjectTaggingInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockDeleteObjectTagging.Lock()
+	mock.calls.DeleteObjectTagging = append(mock.calls.DeleteObjectTagging, callInfo)
+	mock.lockDeleteObjectTagging.Unlock()
+	return mock.DeleteObjectTaggingFunc(ctx, in, optFns...)
+}
+
+// DeleteObjectTaggingCalls gets all the calls that were made to DeleteObjectTagging.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.DeleteObjectTaggingCalls())
+func (mock *S3SDKClientMock) DeleteObjectTaggingCalls() []struct {
+	Ctx    context.Context
+	In     *s3.DeleteObjectTaggingInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.DeleteObjectTaggingInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockDeleteObjectTagging.RLock()
+	calls = mock.calls.DeleteObjectTagging
+	mock.lockDeleteObjectTagging.RUnlock()
+	return calls
+}
+
+// DeleteObjects calls DeleteObjectsFunc.
+func (mock *S3SDKClientMock) DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	if mock.DeleteObjectsFunc == nil {
+		panic("S3SDKClientMock.DeleteObjectsFunc: method is nil but S3SDKClient.DeleteObjects was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.DeleteObjectsInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockDeleteObjects.Lock()
+	mock.calls.DeleteObjects = append(mock.calls.DeleteObjects, callInfo)
+	mock.lockDeleteObjects.Unlock()
+	return mock.DeleteObjectsFunc(ctx, in, optFns...)
+}
+
+// DeleteObjectsCalls gets all the calls that were made to DeleteObjects.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.DeleteObjectsCalls())
+func (mock *S3SDKClientMock) DeleteObjectsCalls() []struct {
+	Ctx    context.Context
+	In     *s3.DeleteObjectsInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.DeleteObjectsInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockDeleteObjects.RLock()
+	calls = mock.calls.DeleteObjects
+	mock.lockDeleteObjects.RUnlock()
+	return calls
+}
+
+// GetBucketLifecycleConfiguration calls GetBucketLifecycleConfigurationFunc.
+func (mock *S3SDKClientMock) GetBucketLifecycleConfiguration(ctx context.Context, in *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	if mock.GetBucketLifecycleConfigurationFunc == nil {
+		panic("S3SDKClientMock.GetBucketLifecycleConfigurationFunc: method is nil but S3SDKClient.GetBucketLifecycleConfiguration was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.GetBucketLifecycleConfigurationInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockGetBucketLifecycleConfiguration.Lock()
+	mock.calls.GetBucketLifecycleConfiguration = append(mock.calls.GetBucketLifecycleConfiguration, callInfo)
+	mock.lockGetBucketLifecycleConfiguration.Unlock()
+	return mock.GetBucketLifecycleConfigurationFunc(ctx, in, optFns...)
+}
+
+// GetBucketLifecycleConfigurationCalls gets all the calls that were made to GetBucketLifecycleConfiguration.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.GetBucketLifecycleConfigurationCalls())
+func (mock *S3SDKClientMock) GetBucketLifecycleConfigurationCalls() []struct {
+	Ctx    context.Context
+	In     *s3.GetBucketLifecycleConfigurationInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.GetBucketLifecycleConfigurationInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockGetBucketLifecycleConfiguration.RLock()
+	calls = mock.calls.GetBucketLifecycleConfiguration
+	mock.lockGetBucketLifecycleConfiguration.RUnlock()
+	return calls
+}
+
+// GetBucketLocation calls GetBucketLocationFunc.
+func (mock *S3SDKClientMock) GetBucketLocation(ctx context.Context, in *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	if mock.GetBucketLocationFunc == nil {
+		panic("S3SDKClientMock.GetBucketLocationFunc: method is nil but S3SDKClient.GetBucketLocation was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.GetBucketLocationInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockGetBucketLocation.Lock()
+	mock.calls.GetBucketLocation = append(mock.calls.GetBucketLocation, callInfo)
+	mock.lockGetBucketLocation.Unlock()
+	return mock.GetBucketLocationFunc(ctx, in, optFns...)
+}
+
+// GetBucketLocationCalls gets all the calls that were made to GetBucketLocation.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.GetBucketLocationCalls())
+func (mock *S3SDKClientMock) GetBucketLocationCalls() []struct {
+	Ctx    context.Context
+	In     *s3.GetBucketLocationInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.GetBucketLocationInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockGetBucketLocation.RLock()
+	calls = mock.calls.GetBucketLocation
+	mock.lockGetBucketLocation.RUnlock()
 	return calls
 }
 
@@ -368,6 +1048,126 @@ func (mock *S3SDKClientMock) GetObjectCalls() []struct {
 	return calls
 }
 
+// GetObjectLegalHold calls GetObjectLegalHoldFunc.
+func (mock *S3SDKClientMock) GetObjectLegalHold(ctx context.Context, in *s3.GetObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error) {
+	if mock.GetObjectLegalHoldFunc == nil {
+		panic("S3SDKClientMock.GetObjectLegalHoldFunc: method is nil but S3SDKClient.GetObjectLegalHold was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.GetObjectLegalHoldInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockGetObjectLegalHold.Lock()
+	mock.calls.GetObjectLegalHold = append(mock.calls.GetObjectLegalHold, callInfo)
+	mock.lockGetObjectLegalHold.Unlock()
+	return mock.GetObjectLegalHoldFunc(ctx, in, optFns...)
+}
+
+// GetObjectLegalHoldCalls gets all the calls that were made to GetObjectLegalHold.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.GetObjectLegalHoldCalls())
+func (mock *S3SDKClientMock) GetObjectLegalHoldCalls() []struct {
+	Ctx    context.Context
+	In     *s3.GetObjectLegalHoldInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.GetObjectLegalHoldInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockGetObjectLegalHold.RLock()
+	calls = mock.calls.GetObjectLegalHold
+	mock.lockGetObjectLegalHold.RUnlock()
+	return calls
+}
+
+// GetObjectRetention calls GetObjectRetentionFunc.
+func (mock *S3SDKClientMock) GetObjectRetention(ctx context.Context, in *s3.GetObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.GetObjectRetentionOutput, error) {
+	if mock.GetObjectRetentionFunc == nil {
+		panic("S3SDKClientMock.GetObjectRetentionFunc: method is nil but S3SDKClient.GetObjectRetention was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.GetObjectRetentionInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockGetObjectRetention.Lock()
+	mock.calls.GetObjectRetention = append(mock.calls.GetObjectRetention, callInfo)
+	mock.lockGetObjectRetention.Unlock()
+	return mock.GetObjectRetentionFunc(ctx, in, optFns...)
+}
+
+// GetObjectRetentionCalls gets all the calls that were made to GetObjectRetention.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.GetObjectRetentionCalls())
+func (mock *S3SDKClientMock) GetObjectRetentionCalls() []struct {
+	Ctx    context.Context
+	In     *s3.GetObjectRetentionInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.GetObjectRetentionInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockGetObjectRetention.RLock()
+	calls = mock.calls.GetObjectRetention
+	mock.lockGetObjectRetention.RUnlock()
+	return calls
+}
+
+// GetObjectTagging calls GetObjectTaggingFunc.
+func (mock *S3SDKClientMock) GetObjectTagging(ctx context.Context, in *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	if mock.GetObjectTaggingFunc == nil {
+		panic("S3SDKClientMock.GetObjectTaggingFunc: method is nil but S3SDKClient.GetObjectTagging was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.GetObjectTaggingInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockGetObjectTagging.Lock()
+	mock.calls.GetObjectTagging = append(mock.calls.GetObjectTagging, callInfo)
+	mock.lockGetObjectTagging.Unlock()
+	return mock.GetObjectTaggingFunc(ctx, in, optFns...)
+}
+
+// GetObjectTaggingCalls gets all the calls that were made to GetObjectTagging.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.GetObjectTaggingCalls())
+func (mock *S3SDKClientMock) GetObjectTaggingCalls() []struct {
+	Ctx    context.Context
+	In     *s3.GetObjectTaggingInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.GetObjectTaggingInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockGetObjectTagging.RLock()
+	calls = mock.calls.GetObjectTagging
+	mock.lockGetObjectTagging.RUnlock()
+	return calls
+}
+
 // HeadBucket calls HeadBucketFunc.
 func (mock *S3SDKClientMock) HeadBucket(ctx context.Context, in *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
 	if mock.HeadBucketFunc == nil {
@@ -488,6 +1288,46 @@ func (mock *S3SDKClientMock) ListMultipartUploadsCalls() []struct {
 	return calls
 }
 
+// ListObjectVersions calls ListObjectVersionsFunc.
+func (mock *S3SDKClientMock) ListObjectVersions(ctx context.Context, in *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	if mock.ListObjectVersionsFunc == nil {
+		panic("S3SDKClientMock.ListObjectVersionsFunc: method is nil but S3SDKClient.ListObjectVersions was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.ListObjectVersionsInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockListObjectVersions.Lock()
+	mock.calls.ListObjectVersions = append(mock.calls.ListObjectVersions, callInfo)
+	mock.lockListObjectVersions.Unlock()
+	return mock.ListObjectVersionsFunc(ctx, in, optFns...)
+}
+
+// ListObjectVersionsCalls gets all the calls that were made to ListObjectVersions.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.ListObjectVersionsCalls())
+func (mock *S3SDKClientMock) ListObjectVersionsCalls() []struct {
+	Ctx    context.Context
+	In     *s3.ListObjectVersionsInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.ListObjectVersionsInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockListObjectVersions.RLock()
+	calls = mock.calls.ListObjectVersions
+	mock.lockListObjectVersions.RUnlock()
+	return calls
+}
+
 // ListObjects calls ListObjectsFunc.
 func (mock *S3SDKClientMock) ListObjects(ctx context.Context, in *s3.ListObjectsInput, optFns ...func(*s3.Options)) (*s3.ListObjectsOutput, error) {
 	if mock.ListObjectsFunc == nil {
@@ -528,6 +1368,46 @@ func (mock *S3SDKClientMock) ListObjectsCalls() []struct {
 	return calls
 }
 
+// ListObjectsV2 calls ListObjectsV2Func.
+func (mock *S3SDKClientMock) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if mock.ListObjectsV2Func == nil {
+		panic("S3SDKClientMock.ListObjectsV2Func: method is nil but S3SDKClient.ListObjectsV2 was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.ListObjectsV2Input
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockListObjectsV2.Lock()
+	mock.calls.ListObjectsV2 = append(mock.calls.ListObjectsV2, callInfo)
+	mock.lockListObjectsV2.Unlock()
+	return mock.ListObjectsV2Func(ctx, in, optFns...)
+}
+
+// ListObjectsV2Calls gets all the calls that were made to ListObjectsV2.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.ListObjectsV2Calls())
+func (mock *S3SDKClientMock) ListObjectsV2Calls() []struct {
+	Ctx    context.Context
+	In     *s3.ListObjectsV2Input
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.ListObjectsV2Input
+		OptFns []func(*s3.Options)
+	}
+	mock.lockListObjectsV2.RLock()
+	calls = mock.calls.ListObjectsV2
+	mock.lockListObjectsV2.RUnlock()
+	return calls
+}
+
 // ListParts calls ListPartsFunc.
 func (mock *S3SDKClientMock) ListParts(ctx context.Context, in *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
 	if mock.ListPartsFunc == nil {
@@ -568,6 +1448,46 @@ func (mock *S3SDKClientMock) ListPartsCalls() []struct {
 	return calls
 }
 
+// PutBucketLifecycleConfiguration calls PutBucketLifecycleConfigurationFunc.
+func (mock *S3SDKClientMock) PutBucketLifecycleConfiguration(ctx context.Context, in *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	if mock.PutBucketLifecycleConfigurationFunc == nil {
+		panic("S3SDKClientMock.PutBucketLifecycleConfigurationFunc: method is nil but S3SDKClient.PutBucketLifecycleConfiguration was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.PutBucketLifecycleConfigurationInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockPutBucketLifecycleConfiguration.Lock()
+	mock.calls.PutBucketLifecycleConfiguration = append(mock.calls.PutBucketLifecycleConfiguration, callInfo)
+	mock.lockPutBucketLifecycleConfiguration.Unlock()
+	return mock.PutBucketLifecycleConfigurationFunc(ctx, in, optFns...)
+}
+
+// PutBucketLifecycleConfigurationCalls gets all the calls that were made to PutBucketLifecycleConfiguration.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.PutBucketLifecycleConfigurationCalls())
+func (mock *S3SDKClientMock) PutBucketLifecycleConfigurationCalls() []struct {
+	Ctx    context.Context
+	In     *s3.PutBucketLifecycleConfigurationInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.PutBucketLifecycleConfigurationInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockPutBucketLifecycleConfiguration.RLock()
+	calls = mock.calls.PutBucketLifecycleConfiguration
+	mock.lockPutBucketLifecycleConfiguration.RUnlock()
+	return calls
+}
+
 // PutBucketPolicy calls PutBucketPolicyFunc.
 func (mock *S3SDKClientMock) PutBucketPolicy(ctx context.Context, in *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error) {
 	if mock.PutBucketPolicyFunc == nil {
@@ -608,6 +1528,166 @@ func (mock *S3SDKClientMock) PutBucketPolicyCalls() []struct {
 	return calls
 }
 
+// PutObject calls PutObjectFunc.
+func (mock *S3SDKClientMock) PutObject(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if mock.PutObjectFunc == nil {
+		panic("S3SDKClientMock.PutObjectFunc: method is nil but S3SDKClient.PutObject was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.PutObjectInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockPutObject.Lock()
+	mock.calls.PutObject = append(mock.calls.PutObject, callInfo)
+	mock.lockPutObject.Unlock()
+	return mock.PutObjectFunc(ctx, in, optFns...)
+}
+
+// PutObjectCalls gets all the calls that were made to PutObject.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.PutObjectCalls())
+func (mock *S3SDKClientMock) PutObjectCalls() []struct {
+	Ctx    context.Context
+	In     *s3.PutObjectInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.PutObjectInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockPutObject.RLock()
+	calls = mock.calls.PutObject
+	mock.lockPutObject.RUnlock()
+	return calls
+}
+
+// PutObjectLegalHold calls PutObjectLegalHoldFunc.
+func (mock *S3SDKClientMock) PutObjectLegalHold(ctx context.Context, in *s3.PutObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.PutObjectLegalHoldOutput, error) {
+	if mock.PutObjectLegalHoldFunc == nil {
+		panic("S3SDKClientMock.PutObjectLegalHoldFunc: method is nil but S3SDKClient.PutObjectLegalHold was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.PutObjectLegalHoldInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockPutObjectLegalHold.Lock()
+	mock.calls.PutObjectLegalHold = append(mock.calls.PutObjectLegalHold, callInfo)
+	mock.lockPutObjectLegalHold.Unlock()
+	return mock.PutObjectLegalHoldFunc(ctx, in, optFns...)
+}
+
+// PutObjectLegalHoldCalls gets all the calls that were made to PutObjectLegalHold.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.PutObjectLegalHoldCalls())
+func (mock *S3SDKClientMock) PutObjectLegalHoldCalls() []struct {
+	Ctx    context.Context
+	In     *s3.PutObjectLegalHoldInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.PutObjectLegalHoldInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockPutObjectLegalHold.RLock()
+	calls = mock.calls.PutObjectLegalHold
+	mock.lockPutObjectLegalHold.RUnlock()
+	return calls
+}
+
+// PutObjectRetention calls PutObjectRetentionFunc.
+func (mock *S3SDKClientMock) PutObjectRetention(ctx context.Context, in *s3.PutObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.PutObjectRetentionOutput, error) {
+	if mock.PutObjectRetentionFunc == nil {
+		panic("S3SDKClientMock.PutObjectRetentionFunc: method is nil but S3SDKClient.PutObjectRetention was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.PutObjectRetentionInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockPutObjectRetention.Lock()
+	mock.calls.PutObjectRetention = append(mock.calls.PutObjectRetention, callInfo)
+	mock.lockPutObjectRetention.Unlock()
+	return mock.PutObjectRetentionFunc(ctx, in, optFns...)
+}
+
+// PutObjectRetentionCalls gets all the calls that were made to PutObjectRetention.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.PutObjectRetentionCalls())
+func (mock *S3SDKClientMock) PutObjectRetentionCalls() []struct {
+	Ctx    context.Context
+	In     *s3.PutObjectRetentionInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.PutObjectRetentionInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockPutObjectRetention.RLock()
+	calls = mock.calls.PutObjectRetention
+	mock.lockPutObjectRetention.RUnlock()
+	return calls
+}
+
+// PutObjectTagging calls PutObjectTaggingFunc.
+func (mock *S3SDKClientMock) PutObjectTagging(ctx context.Context, in *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	if mock.PutObjectTaggingFunc == nil {
+		panic("S3SDKClientMock.PutObjectTaggingFunc: method is nil but S3SDKClient.PutObjectTagging was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.PutObjectTaggingInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockPutObjectTagging.Lock()
+	mock.calls.PutObjectTagging = append(mock.calls.PutObjectTagging, callInfo)
+	mock.lockPutObjectTagging.Unlock()
+	return mock.PutObjectTaggingFunc(ctx, in, optFns...)
+}
+
+// PutObjectTaggingCalls gets all the calls that were made to PutObjectTagging.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.PutObjectTaggingCalls())
+func (mock *S3SDKClientMock) PutObjectTaggingCalls() []struct {
+	Ctx    context.Context
+	In     *s3.PutObjectTaggingInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.PutObjectTaggingInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockPutObjectTagging.RLock()
+	calls = mock.calls.PutObjectTagging
+	mock.lockPutObjectTagging.RUnlock()
+	return calls
+}
+
 // UploadPart calls UploadPartFunc.
 func (mock *S3SDKClientMock) UploadPart(ctx context.Context, in *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
 	if mock.UploadPartFunc == nil {
@@ -647,3 +1727,43 @@ func (mock *S3SDKClientMock) UploadPartCalls() []struct {
 	mock.lockUploadPart.RUnlock()
 	return calls
 }
+
+// UploadPartCopy calls UploadPartCopyFunc.
+func (mock *S3SDKClientMock) UploadPartCopy(ctx context.Context, in *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+	if mock.UploadPartCopyFunc == nil {
+		panic("S3SDKClientMock.UploadPartCopyFunc: method is nil but S3SDKClient.UploadPartCopy was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		In     *s3.UploadPartCopyInput
+		OptFns []func(*s3.Options)
+	}{
+		Ctx:    ctx,
+		In:     in,
+		OptFns: optFns,
+	}
+	mock.lockUploadPartCopy.Lock()
+	mock.calls.UploadPartCopy = append(mock.calls.UploadPartCopy, callInfo)
+	mock.lockUploadPartCopy.Unlock()
+	return mock.UploadPartCopyFunc(ctx, in, optFns...)
+}
+
+// UploadPartCopyCalls gets all the calls that were made to UploadPartCopy.
+// Check the length with:
+//
+//	len(mockedS3SDKClient.UploadPartCopyCalls())
+func (mock *S3SDKClientMock) UploadPartCopyCalls() []struct {
+	Ctx    context.Context
+	In     *s3.UploadPartCopyInput
+	OptFns []func(*s3.Options)
+} {
+	var calls []struct {
+		Ctx    context.Context
+		In     *s3.UploadPartCopyInput
+		OptFns []func(*s3.Options)
+	}
+	mock.lockUploadPartCopy.RLock()
+	calls = mock.calls.UploadPartCopy
+	mock.lockUploadPartCopy.RUnlock()
+	return calls
+}
@@ -0,0 +1,91 @@
+// file: object_info.go
+//
+// Contains the ObjectInfo struct, which represents the metadata of an S3 object
+// returned alongside its content by GetObject and GetObjectWithPSK, or on its own by Head.
+package s3
+
+import (
+	"time"
+
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ObjectInfo represents the metadata of an S3 object, as returned by GetObject, GetObjectWithPSK and Head
+type ObjectInfo struct {
+	Key string
+
+	// ContentLength is the size in bytes of the content returned to the caller,
+	// which is the uncompressed length for compressed objects, or nil if it is not known.
+	ContentLength *int64
+
+	ContentType        string
+	ContentEncoding    string
+	ContentDisposition string
+	ETag               string
+	LastModified       time.Time
+	VersionID          string
+	StorageClass       types.StorageClass
+
+	// Metadata is the user-defined metadata of the object, including the keys recorded by this library for compression and encryption
+	Metadata map[string]string
+
+	// ServerSideEncryption is the server-side encryption algorithm used by S3 to store the object
+	ServerSideEncryption types.ServerSideEncryption
+
+	// SSEKMSKeyID is the ID of the KMS key used for server-side encryption, if any
+	SSEKMSKeyID string
+
+	// PSKEncrypted is true if the object content was encrypted with a psk by this library.
+	// Objects written by versions that did not record the encryption format cannot be detected.
+	PSKEncrypted bool
+
+	// Compression is the codec the object content was compressed with by this library
+	Compression Compression
+}
+
+// newObjectInfo returns the ObjectInfo for the provided key and metadata, setting the fields that depend on the library metadata
+func newObjectInfo(key string, metadata map[string]string, length *int64) *ObjectInfo {
+	info := &ObjectInfo{
+		Key:           key,
+		ContentLength: contentLength(metadata, length),
+		Metadata:      metadata,
+	}
+	if codec, ok := crypto.MetadataValue(metadata, CompressionMetadataKey); ok {
+		info.Compression = Compression(codec)
+	}
+	_, info.PSKEncrypted = crypto.MetadataValue(metadata, crypto.FormatMetadataKey)
+	return info
+}
+
+// objectInfoFromGet returns the ObjectInfo for a GetObject response
+func objectInfoFromGet(key string, out *s3.GetObjectOutput) *ObjectInfo {
+	info := newObjectInfo(key, out.Metadata, out.ContentLength)
+	info.ContentType = aws.ToString(out.ContentType)
+	info.ContentEncoding = aws.ToString(out.ContentEncoding)
+	info.ContentDisposition = aws.ToString(out.ContentDisposition)
+	info.ETag = aws.ToString(out.ETag)
+	info.LastModified = aws.ToTime(out.LastModified)
+	info.VersionID = aws.ToString(out.VersionId)
+	info.StorageClass = out.StorageClass
+	info.ServerSideEncryption = out.ServerSideEncryption
+	info.SSEKMSKeyID = aws.ToString(out.SSEKMSKeyId)
+	return info
+}
+
+// objectInfoFromHead returns the ObjectInfo for a HeadObject response
+func objectInfoFromHead(key string, out *s3.HeadObjectOutput) *ObjectInfo {
+	info := newObjectInfo(key, out.Metadata, out.ContentLength)
+	info.ContentType = aws.ToString(out.ContentType)
+	info.ContentEncoding = aws.ToString(out.ContentEncoding)
+	info.ContentDisposition = aws.ToString(out.ContentDisposition)
+	info.ETag = aws.ToString(out.ETag)
+	info.LastModified = aws.ToTime(out.LastModified)
+	info.VersionID = aws.ToString(out.VersionId)
+	info.StorageClass = out.StorageClass
+	info.ServerSideEncryption = out.ServerSideEncryption
+	info.SSEKMSKeyID = aws.ToString(out.SSEKMSKeyId)
+	return info
+}
@@ -0,0 +1,207 @@
+// file: object_lock.go
+//
+// Contains methods to manage the Object Lock retention and legal hold of objects of the bucket configured for the client,
+// which must have Object Lock enabled. Locked object versions cannot be deleted or overwritten until their retention expires
+// and their legal hold is removed.
+//
+// Requires "s3:GetObjectRetention", "s3:PutObjectRetention", "s3:GetObjectLegalHold" and "s3:PutObjectLegalHold" actions
+// allowed by IAM policy for objects inside the bucket, and "s3:BypassGovernanceRetention" to shorten governance retention with PutRetention.
+// Retention cannot be removed, and versions under governance retention cannot be deleted by this client until it expires.
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// errCodeNoObjectLockConfiguration is the AWS error code returned when an object version has no retention or legal hold
+const errCodeNoObjectLockConfiguration = "NoSuchObjectLockConfiguration"
+
+// Retention represents the Object Lock retention of an object version, which cannot be deleted or overwritten until RetainUntil.
+// In governance mode, users with the "s3:BypassGovernanceRetention" permission can shorten the retention (see PutRetention);
+// in compliance mode, nobody can, including the root user.
+type Retention struct {
+	Mode        types.ObjectLockMode
+	RetainUntil time.Time
+}
+
+// validate returns an error if the retention does not have a valid mode and a retain until date in the future
+func (r Retention) validate() error {
+	if r.Mode != types.ObjectLockModeGovernance && r.Mode != types.ObjectLockModeCompliance {
+		return fmt.Errorf("invalid object lock mode %q", r.Mode)
+	}
+	if !r.RetainUntil.After(time.Now()) {
+		return errors.New("object lock retain until date must be in the future")
+	}
+	return nil
+}
+
+// GetRetention returns the retention of the provided version (or the latest version, if it is empty) of the object for the given key
+// (inside the bucket configured for this client), or nil if it has no retention
+func (cli *Client) GetRetention(ctx context.Context, key, versionID string) (*Retention, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"version_id":  versionID,
+	}
+
+	input := &s3.GetObjectRetentionInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	out, err := cli.sdkClient.GetObjectRetention(ctx, input)
+	if err != nil {
+		if errorCode(err) == errCodeNoObjectLockConfiguration {
+			return nil, nil
+		}
+		return nil, NewError(fmt.Errorf("error getting object retention from s3: %w", err), logData)
+	}
+	if out.Retention == nil || out.Retention.Mode == "" {
+		return nil, nil
+	}
+	return &Retention{
+		Mode:        types.ObjectLockMode(out.Retention.Mode),
+		RetainUntil: aws.ToTime(out.Retention.RetainUntilDate),
+	}, nil
+}
+
+// PutRetention sets the retention of the provided version (or the latest version, if it is empty) of the object for the given key
+// (inside the bucket configured for this client). Retention can always be extended, but shortening governance retention,
+// or changing it to a shorter or weaker one, requires bypassGovernance; an ErrObjectLocked error is returned if it is not allowed.
+func (cli *Client) PutRetention(ctx context.Context, key, versionID string, retention Retention, bypassGovernance bool) error {
+	logData := log.Data{
+		"bucket_name":       cli.bucketName,
+		"s3_key":            key, // key is the s3 filename with path (it's not a cryptographic key)
+		"version_id":        versionID,
+		"mode":              retention.Mode,
+		"retain_until":      retention.RetainUntil,
+		"bypass_governance": bypassGovernance,
+	}
+
+	if err := retention.validate(); err != nil {
+		return NewError(err, logData)
+	}
+
+	input := &s3.PutObjectRetentionInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+		Retention: &types.ObjectLockRetention{
+			Mode:            types.ObjectLockRetentionMode(retention.Mode),
+			RetainUntilDate: aws.Time(retention.RetainUntil),
+		},
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	if bypassGovernance {
+		input.BypassGovernanceRetention = aws.Bool(true)
+	}
+	if _, err := cli.sdkClient.PutObjectRetention(ctx, input); err != nil {
+		return lockError(fmt.Errorf("error putting object retention to s3: %w", err), logData)
+	}
+	return nil
+}
+
+// GetLegalHold returns true if the provided version (or the latest version, if it is empty) of the object for the given key
+// (inside the bucket configured for this client) has a legal hold
+func (cli *Client) GetLegalHold(ctx context.Context, key, versionID string) (bool, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"version_id":  versionID,
+	}
+
+	input := &s3.GetObjectLegalHoldInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	out, err := cli.sdkClient.GetObjectLegalHold(ctx, input)
+	if err != nil {
+		if errorCode(err) == errCodeNoObjectLockConfiguration {
+			return false, nil
+		}
+		return false, NewError(fmt.Errorf("error getting object legal hold from s3: %w", err), logData)
+	}
+	return out.LegalHold != nil && out.LegalHold.Status == types.ObjectLockLegalHoldStatusOn, nil
+}
+
+// PutLegalHold places (on) or removes (off) a legal hold on the provided version (or the latest version, if it is empty)
+// of the object for the given key (inside the bucket configured for this client).
+// A legal hold prevents the version from being deleted or overwritten until it is removed, regardless of its retention.
+func (cli *Client) PutLegalHold(ctx context.Context, key, versionID string, on bool) error {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"version_id":  versionID,
+		"legal_hold":  on,
+	}
+
+	input := &s3.PutObjectLegalHoldInput{
+		Bucket:    aws.String(cli.bucketName),
+		Key:       aws.String(key),
+		LegalHold: &types.ObjectLockLegalHold{Status: legalHoldStatus(on)},
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	if _, err := cli.sdkClient.PutObjectLegalHold(ctx, input); err != nil {
+		return NewError(fmt.Errorf("error putting object legal hold to s3: %w", err), logData)
+	}
+	return nil
+}
+
+// legalHoldStatus returns the legal hold status for the provided flag
+func legalHoldStatus(on bool) types.ObjectLockLegalHoldStatus {
+	if on {
+		return types.ObjectLockLegalHoldStatusOn
+	}
+	return types.ObjectLockLegalHoldStatusOff
+}
+
+// validateObjectLock returns an error if the provided retention is not valid, if any
+func validateObjectLock(retention *Retention) error {
+	if retention == nil {
+		return nil
+	}
+	return retention.validate()
+}
+
+// isObjectLocked returns true if the provided error was caused by an object version protected by Object Lock.
+// S3 reports these as access denied or invalid requests, which are only distinguished from other causes by their message.
+func isObjectLocked(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return isObjectLockedMessage(apiErr.ErrorCode(), apiErr.ErrorMessage())
+}
+
+// isObjectLockedMessage returns true if the provided AWS error code and message correspond to an object version protected by Object Lock
+func isObjectLockedMessage(code, message string) bool {
+	message = strings.ToLower(message)
+	return (code == "AccessDenied" && strings.Contains(message, "object lock")) ||
+		(code == "InvalidRequest" && strings.Contains(message, "worm protected"))
+}
+
+// lockError returns an ErrObjectLocked error if the provided error was caused by Object Lock, or an S3Error otherwise
+func lockError(err error, logData log.Data) error {
+	if isObjectLocked(err) {
+		return NewObjectLockedError(err, logData)
+	}
+	return NewError(err, logData)
+}
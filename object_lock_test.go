@@ -0,0 +1,243 @@
+package s3_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// objectLockedMessage is the message of the errors returned by S3 for object versions protected by Object Lock
+const objectLockedMessage = "Access Denied because object protected by object lock."
+
+// newObjectLockedError returns an error like the ones returned by the SDK for object versions protected by Object Lock
+func newObjectLockedError() error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusForbidden}},
+			Err:      &smithy.GenericAPIError{Code: "AccessDenied", Message: objectLockedMessage},
+		},
+	}
+}
+
+// shouldBeObjectLocked asserts that the provided error is an ErrObjectLocked error
+func shouldBeObjectLocked(actual any, expected ...any) string {
+	var errLocked *dps3.ErrObjectLocked
+	if err, ok := actual.(error); ok && errors.As(err, &errLocked) {
+		return ""
+	}
+	return "expected an ErrObjectLocked error"
+}
+
+func TestRetention(t *testing.T) {
+	Convey("Given an S3 client and an object version with retention", t, func() {
+		ctx := context.Background()
+		retainUntil := time.Now().Add(365 * 24 * time.Hour).UTC().Truncate(time.Second)
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectRetentionFunc: func(ctx context.Context, in *s3.GetObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.GetObjectRetentionOutput, error) {
+				return &s3.GetObjectRetentionOutput{Retention: &types.ObjectLockRetention{
+					Mode:            types.ObjectLockRetentionModeCompliance,
+					RetainUntilDate: aws.Time(retainUntil),
+				}}, nil
+			},
+			PutObjectRetentionFunc: func(ctx context.Context, in *s3.PutObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.PutObjectRetentionOutput, error) {
+				return &s3.PutObjectRetentionOutput{}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetRetention returns its retention", func() {
+			retention, err := cli.GetRetention(ctx, testS3Key, "v1")
+			So(err, ShouldBeNil)
+			So(retention, ShouldResemble, &dps3.Retention{Mode: types.ObjectLockModeCompliance, RetainUntil: retainUntil})
+			So(*sdkMock.GetObjectRetentionCalls()[0].In.VersionId, ShouldEqual, "v1")
+		})
+
+		Convey("GetRetention returns nil for an object version without retention", func() {
+			sdkMock.GetObjectRetentionFunc = func(ctx context.Context, in *s3.GetObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.GetObjectRetentionOutput, error) {
+				return nil, newResponseError(http.StatusNotFound, "NoSuchObjectLockConfiguration")
+			}
+			retention, err := cli.GetRetention(ctx, testS3Key, "")
+			So(err, ShouldBeNil)
+			So(retention, ShouldBeNil)
+			So(sdkMock.GetObjectRetentionCalls()[0].In.VersionId, ShouldBeNil)
+		})
+
+		Convey("PutRetention sets the retention of the latest version", func() {
+			err := cli.PutRetention(ctx, testS3Key, "", dps3.Retention{Mode: types.ObjectLockModeGovernance, RetainUntil: retainUntil}, true)
+			So(err, ShouldBeNil)
+			in := sdkMock.PutObjectRetentionCalls()[0].In
+			So(*in.Key, ShouldEqual, testS3Key)
+			So(in.VersionId, ShouldBeNil)
+			So(in.Retention.Mode, ShouldEqual, types.ObjectLockRetentionModeGovernance)
+			So(*in.Retention.RetainUntilDate, ShouldEqual, retainUntil)
+			So(*in.BypassGovernanceRetention, ShouldBeTrue)
+		})
+
+		Convey("PutRetention fails without sending any request if the mode or the retain until date are not valid", func() {
+			err := cli.PutRetention(ctx, testS3Key, "", dps3.Retention{Mode: "FOREVER", RetainUntil: retainUntil}, false)
+			So(err, ShouldNotBeNil)
+			err = cli.PutRetention(ctx, testS3Key, "", dps3.Retention{Mode: types.ObjectLockModeCompliance, RetainUntil: time.Now().Add(-time.Hour)}, false)
+			So(err, ShouldNotBeNil)
+			So(sdkMock.PutObjectRetentionCalls(), ShouldBeEmpty)
+		})
+
+		Convey("PutRetention fails with ErrObjectLocked if S3 does not allow shortening the retention", func() {
+			sdkMock.PutObjectRetentionFunc = func(ctx context.Context, in *s3.PutObjectRetentionInput, optFns ...func(*s3.Options)) (*s3.PutObjectRetentionOutput, error) {
+				return nil, newObjectLockedError()
+			}
+			err := cli.PutRetention(ctx, testS3Key, "v1", dps3.Retention{Mode: types.ObjectLockModeCompliance, RetainUntil: time.Now().Add(time.Hour)}, false)
+			So(err, shouldBeObjectLocked)
+		})
+	})
+}
+
+func TestLegalHold(t *testing.T) {
+	Convey("Given an S3 client and an object version with a legal hold", t, func() {
+		ctx := context.Background()
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectLegalHoldFunc: func(ctx context.Context, in *s3.GetObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error) {
+				return &s3.GetObjectLegalHoldOutput{LegalHold: &types.ObjectLockLegalHold{Status: types.ObjectLockLegalHoldStatusOn}}, nil
+			},
+			PutObjectLegalHoldFunc: func(ctx context.Context, in *s3.PutObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.PutObjectLegalHoldOutput, error) {
+				return &s3.PutObjectLegalHoldOutput{}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetLegalHold returns true", func() {
+			on, err := cli.GetLegalHold(ctx, testS3Key, "v1")
+			So(err, ShouldBeNil)
+			So(on, ShouldBeTrue)
+		})
+
+		Convey("GetLegalHold returns false for an object version without legal hold", func() {
+			sdkMock.GetObjectLegalHoldFunc = func(ctx context.Context, in *s3.GetObjectLegalHoldInput, optFns ...func(*s3.Options)) (*s3.GetObjectLegalHoldOutput, error) {
+				return nil, newResponseError(http.StatusNotFound, "NoSuchObjectLockConfiguration")
+			}
+			on, err := cli.GetLegalHold(ctx, testS3Key, "")
+			So(err, ShouldBeNil)
+			So(on, ShouldBeFalse)
+		})
+
+		Convey("PutLegalHold removes the legal hold", func() {
+			err := cli.PutLegalHold(ctx, testS3Key, "v1", false)
+			So(err, ShouldBeNil)
+			in := sdkMock.PutObjectLegalHoldCalls()[0].In
+			So(*in.VersionId, ShouldEqual, "v1")
+			So(in.LegalHold.Status, ShouldEqual, types.ObjectLockLegalHoldStatusOff)
+		})
+	})
+}
+
+func TestObjectLockOptions(t *testing.T) {
+	Convey("Given an S3 client for a bucket with Object Lock", t, func() {
+		ctx := context.Background()
+		retention := &dps3.Retention{Mode: types.ObjectLockModeCompliance, RetainUntil: time.Now().Add(24 * time.Hour)}
+
+		Convey("Copy locks the destination object with the retention and legal hold of the options", func() {
+			sdkMock := newCopyMock(1024, 1024, copySrcETag)
+			cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			_, err := cli.Copy(ctx, testS3Key, copyDstKey, dps3.CopyOptions{Retention: retention, LegalHold: true})
+			So(err, ShouldBeNil)
+			in := sdkMock.CopyObjectCalls()[0].In
+			So(in.ObjectLockMode, ShouldEqual, types.ObjectLockModeCompliance)
+			So(*in.ObjectLockRetainUntilDate, ShouldEqual, retention.RetainUntil)
+			So(in.ObjectLockLegalHoldStatus, ShouldEqual, types.ObjectLockLegalHoldStatusOn)
+
+			_, err = cli.Copy(ctx, testS3Key, copyDstKey, dps3.CopyOptions{Retention: &dps3.Retention{Mode: types.ObjectLockModeGovernance}})
+			So(err, ShouldNotBeNil)
+			So(sdkMock.CopyObjectCalls(), ShouldHaveLength, 1)
+		})
+
+		Convey("UploadPart creates the multipart upload with the retention and legal hold of the request", func() {
+			sdkMock := &mock.S3SDKClientMock{
+				ListMultipartUploadsFunc: func(ctx context.Context, in *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+					return &s3.ListMultipartUploadsOutput{}, nil
+				},
+				CreateMultipartUploadFunc: func(ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+					return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+				},
+				UploadPartFunc: func(ctx context.Context, in *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+					return &s3.UploadPartOutput{ETag: aws.String(`"part"`)}, nil
+				},
+				ListPartsFunc: func(ctx context.Context, in *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+					return &s3.ListPartsOutput{}, nil
+				},
+			}
+			cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			_, err := cli.UploadPart(ctx, &dps3.UploadPartRequest{UploadKey: testS3Key, ChunkNumber: 1, TotalChunks: 2, Retention: retention, LegalHold: true}, []byte("chunk"))
+			So(err, ShouldBeNil)
+			in := sdkMock.CreateMultipartUploadCalls()[0].In
+			So(in.ObjectLockMode, ShouldEqual, types.ObjectLockModeCompliance)
+			So(*in.ObjectLockRetainUntilDate, ShouldEqual, retention.RetainUntil)
+			So(in.ObjectLockLegalHoldStatus, ShouldEqual, types.ObjectLockLegalHoldStatusOn)
+		})
+
+		Convey("Upload fails without uploading if the Object Lock mode is provided without a retain until date", func() {
+			uploaderMock := &mock.S3SDKUploaderMock{}
+			cli := dps3.InstantiateClient(nil, nil, uploaderMock, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			_, err := cli.Upload(ctx, &s3.PutObjectInput{Key: aws.String(testS3Key), ObjectLockMode: types.ObjectLockModeCompliance})
+			So(err, ShouldNotBeNil)
+			So(uploaderMock.UploadCalls(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestObjectLocked(t *testing.T) {
+	Convey("Given an S3 client for object versions protected by Object Lock", t, func() {
+		ctx := context.Background()
+		sdkMock := &mock.S3SDKClientMock{
+			DeleteObjectFunc: func(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+				return nil, newObjectLockedError()
+			},
+			DeleteObjectsFunc: func(ctx context.Context, in *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+				return &s3.DeleteObjectsOutput{Errors: []types.Error{
+					{Key: aws.String("locked.csv"), Code: aws.String("AccessDenied"), Message: aws.String(objectLockedMessage)},
+					{Key: aws.String("denied.csv"), Code: aws.String("AccessDenied"), Message: aws.String("Access Denied")},
+				}}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("DeleteVersion fails with ErrObjectLocked", func() {
+			err := cli.DeleteVersion(ctx, testS3Key, "v1")
+			So(err, shouldBeObjectLocked)
+		})
+
+		Convey("DeleteMany reports ErrObjectLocked for the locked objects only", func() {
+			result, err := cli.DeleteMany(ctx, []string{"locked.csv", "denied.csv", "deleted.csv"})
+			So(err, ShouldNotBeNil)
+			So(result.Deleted, ShouldResemble, []string{"deleted.csv"})
+			So(result.Failed, ShouldHaveLength, 2)
+			So(result.Failed[0].Key, ShouldEqual, "denied.csv")
+			So(result.Failed[0].Err, ShouldNotBeNil)
+			var errLocked *dps3.ErrObjectLocked
+			So(errors.As(result.Failed[0].Err, &errLocked), ShouldBeFalse)
+			So(result.Failed[1].Err, shouldBeObjectLocked)
+		})
+
+		Convey("Other access denied errors are not ErrObjectLocked", func() {
+			sdkMock.DeleteObjectFunc = func(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+				return nil, newResponseError(http.StatusForbidden, "AccessDenied")
+			}
+			err := cli.Delete(ctx, testS3Key)
+			var errLocked *dps3.ErrObjectLocked
+			So(errors.As(err, &errLocked), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,539 @@
+// file: policy.go
+//
+// Contains a typed model of bucket policy documents, with their statements, principals and conditions,
+// and methods to get and put the policy of the bucket configured for the client (or another bucket, if explicitly provided),
+// and to add or remove statements idempotently by Sid.
+//
+// Requires "s3:GetBucketPolicy", "s3:PutBucketPolicy" and "s3:DeleteBucketPolicy" actions allowed by IAM policy for the bucket.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	// PolicyVersion is the version of the policy language used by policies that do not provide one
+	PolicyVersion = "2012-10-17"
+
+	// EnforceTLSSid is the Sid of the statement returned by EnforceTLSStatement
+	EnforceTLSSid = "EnforceTLS"
+
+	// MaxPolicySize is the maximum size in bytes S3 allows for the policy document of a bucket
+	MaxPolicySize = 20 * 1024
+
+	// errCodeNoSuchBucketPolicy is the AWS error code returned when a bucket has no policy
+	errCodeNoSuchBucketPolicy = "NoSuchBucketPolicy"
+)
+
+// PolicyEffect is the effect of a policy statement
+type PolicyEffect string
+
+// Possible policy statement effects
+const (
+	PolicyAllow PolicyEffect = "Allow"
+	PolicyDeny  PolicyEffect = "Deny"
+)
+
+// Policy represents a bucket policy document
+type Policy struct {
+	Version   string            `json:"Version,omitempty"`
+	ID        string            `json:"Id,omitempty"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+// PolicyStatement represents a statement of a bucket policy, which allows or denies the actions on the resources to the principals,
+// when its conditions are met. Statements are identified by their Sid, which is required to add or remove them idempotently.
+type PolicyStatement struct {
+	Sid          string           `json:"Sid,omitempty"`
+	Effect       PolicyEffect     `json:"Effect"`
+	Principal    *PolicyPrincipal `json:"Principal,omitempty"`
+	NotPrincipal *PolicyPrincipal `json:"NotPrincipal,omitempty"`
+	Action       PolicyValues     `json:"Action,omitempty"`
+	NotAction    PolicyValues     `json:"NotAction,omitempty"`
+	Resource     PolicyValues     `json:"Resource,omitempty"`
+	NotResource  PolicyValues     `json:"NotResource,omitempty"`
+	Condition    PolicyConditions `json:"Condition,omitempty"`
+}
+
+// PolicyPrincipal represents the principals of a policy statement: everyone ("*"), or the provided ARNs, services and accounts
+type PolicyPrincipal struct {
+	Everyone      bool         `json:"-"`
+	AWS           PolicyValues `json:"AWS,omitempty"`
+	Service       PolicyValues `json:"Service,omitempty"`
+	Federated     PolicyValues `json:"Federated,omitempty"`
+	CanonicalUser PolicyValues `json:"CanonicalUser,omitempty"`
+}
+
+// PolicyConditions maps condition operators (e.g. "StringLike") to the condition keys (e.g. "s3:prefix") and the values they are compared with
+type PolicyConditions map[string]map[string]PolicyValues
+
+// PolicyValues represents a policy element that can be a single value or a list of values.
+// Boolean and numeric values are parsed as strings, which is how S3 evaluates them.
+type PolicyValues []string
+
+// policyDocument has the fields of Policy, with statements that can be a single statement or a list of them
+type policyDocument struct {
+	Version   string          `json:"Version,omitempty"`
+	ID        string          `json:"Id,omitempty"`
+	Statement json.RawMessage `json:"Statement"`
+}
+
+// policyPrincipal has the fields of PolicyPrincipal, without its JSON methods
+type policyPrincipal PolicyPrincipal
+
+// ParsePolicy parses the provided bucket policy document
+func ParsePolicy(document string) (*Policy, error) {
+	var doc policyDocument
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		return nil, fmt.Errorf("error parsing policy document: %w", err)
+	}
+
+	policy := &Policy{Version: doc.Version, ID: doc.ID}
+	statements := bytes.TrimSpace(doc.Statement)
+	switch {
+	case len(statements) == 0 || bytes.Equal(statements, []byte("null")):
+	case statements[0] == '{':
+		var stmt PolicyStatement
+		if err := json.Unmarshal(statements, &stmt); err != nil {
+			return nil, fmt.Errorf("error parsing policy statement: %w", err)
+		}
+		policy.Statement = []PolicyStatement{stmt}
+	default:
+		if err := json.Unmarshal(statements, &policy.Statement); err != nil {
+			return nil, fmt.Errorf("error parsing policy statements: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// Marshal returns the policy document, with the default PolicyVersion if it does not have a version
+func (p *Policy) Marshal() (string, error) {
+	doc := *p
+	if doc.Version == "" {
+		doc.Version = PolicyVersion
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling policy document: %w", err)
+	}
+	return string(b), nil
+}
+
+// FindStatement returns the statement with the provided sid, and true if it exists
+func (p *Policy) FindStatement(sid string) (PolicyStatement, bool) {
+	i := p.statementIndex(sid)
+	if i < 0 {
+		return PolicyStatement{}, false
+	}
+	return p.Statement[i], true
+}
+
+// AddStatement adds the provided statement to the policy, replacing the statement with the same sid if it is different.
+// It returns true if the policy changed, which is not the case if an equivalent statement already exists.
+func (p *Policy) AddStatement(stmt PolicyStatement) (bool, error) {
+	if stmt.Sid == "" {
+		return false, errors.New("statements must have a sid to be added")
+	}
+	if err := stmt.validate(); err != nil {
+		return false, fmt.Errorf("invalid policy statement %q: %w", stmt.Sid, err)
+	}
+
+	i := p.statementIndex(stmt.Sid)
+	if i < 0 {
+		p.Statement = append(p.Statement, stmt)
+		return true, nil
+	}
+	if reflect.DeepEqual(p.Statement[i].normalised(), stmt.normalised()) {
+		return false, nil
+	}
+	p.Statement[i] = stmt
+	return true, nil
+}
+
+// RemoveStatement removes the statement with the provided sid from the policy, and returns true if it existed
+func (p *Policy) RemoveStatement(sid string) bool {
+	i := p.statementIndex(sid)
+	if i < 0 {
+		return false
+	}
+	p.Statement = slices.Delete(p.Statement, i, i+1)
+	return true
+}
+
+// statementIndex returns the index of the statement with the provided non-empty sid, or -1 if there is none
+func (p *Policy) statementIndex(sid string) int {
+	if sid == "" {
+		return -1
+	}
+	return slices.IndexFunc(p.Statement, func(stmt PolicyStatement) bool {
+		return stmt.Sid == sid
+	})
+}
+
+// validate returns an error if the policy has no statements, duplicated sids or invalid statements
+func (p *Policy) validate() error {
+	if len(p.Statement) == 0 {
+		return errors.New("policy must have at least one statement")
+	}
+	sids := make(map[string]struct{}, len(p.Statement))
+	for i, stmt := range p.Statement {
+		if err := stmt.validate(); err != nil {
+			return fmt.Errorf("invalid policy statement %d %q: %w", i, stmt.Sid, err)
+		}
+		if stmt.Sid == "" {
+			continue
+		}
+		if _, ok := sids[stmt.Sid]; ok {
+			return fmt.Errorf("policy statement sid %q is duplicated", stmt.Sid)
+		}
+		sids[stmt.Sid] = struct{}{}
+	}
+	return nil
+}
+
+// validate returns an error if the statement does not have a valid effect, and exactly one of each of the principal, action and resource elements,
+// as required by bucket policies
+func (s PolicyStatement) validate() error {
+	if s.Effect != PolicyAllow && s.Effect != PolicyDeny {
+		return fmt.Errorf("invalid effect %q", s.Effect)
+	}
+	if (s.Principal == nil) == (s.NotPrincipal == nil) {
+		return errors.New("exactly one of principal or not principal must be provided")
+	}
+	if (len(s.Action) == 0) == (len(s.NotAction) == 0) {
+		return errors.New("exactly one of action or not action must be provided")
+	}
+	if (len(s.Resource) == 0) == (len(s.NotResource) == 0) {
+		return errors.New("exactly one of resource or not resource must be provided")
+	}
+	return nil
+}
+
+// normalised returns a copy of the statement with sorted values and without empty elements, so that equivalent statements are equal
+func (s PolicyStatement) normalised() PolicyStatement {
+	s.Principal = s.Principal.normalised()
+	s.NotPrincipal = s.NotPrincipal.normalised()
+	s.Action = s.Action.normalised()
+	s.NotAction = s.NotAction.normalised()
+	s.Resource = s.Resource.normalised()
+	s.NotResource = s.NotResource.normalised()
+
+	var conditions PolicyConditions
+	for operator, keys := range s.Condition {
+		for key, values := range keys {
+			if conditions == nil {
+				conditions = PolicyConditions{}
+			}
+			if conditions[operator] == nil {
+				conditions[operator] = map[string]PolicyValues{}
+			}
+			conditions[operator][key] = values.normalised()
+		}
+	}
+	s.Condition = conditions
+	return s
+}
+
+// normalised returns a copy of the principal with sorted values and without empty elements
+func (p *PolicyPrincipal) normalised() *PolicyPrincipal {
+	if p == nil {
+		return nil
+	}
+	return &PolicyPrincipal{
+		Everyone:      p.Everyone,
+		AWS:           p.AWS.normalised(),
+		Service:       p.Service.normalised(),
+		Federated:     p.Federated.normalised(),
+		CanonicalUser: p.CanonicalUser.normalised(),
+	}
+}
+
+// normalised returns a sorted copy of the values, or nil if there are none
+func (v PolicyValues) normalised() PolicyValues {
+	if len(v) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(v)
+	slices.Sort(sorted)
+	return sorted
+}
+
+// MarshalJSON encodes everyone as "*", and the rest of principals as an object
+func (p PolicyPrincipal) MarshalJSON() ([]byte, error) {
+	if p.Everyone {
+		return json.Marshal("*")
+	}
+	return json.Marshal(policyPrincipal(p))
+}
+
+// UnmarshalJSON decodes "*" as everyone, and objects as the rest of principals
+func (p *PolicyPrincipal) UnmarshalJSON(data []byte) error {
+	var everyone string
+	if err := json.Unmarshal(data, &everyone); err == nil {
+		if everyone != "*" {
+			return fmt.Errorf("invalid policy principal %q", everyone)
+		}
+		*p = PolicyPrincipal{Everyone: true}
+		return nil
+	}
+	var principal policyPrincipal
+	if err := json.Unmarshal(data, &principal); err != nil {
+		return err
+	}
+	*p = PolicyPrincipal(principal)
+	return nil
+}
+
+// MarshalJSON encodes a single value as a string, and any other number of values as a list
+func (v PolicyValues) MarshalJSON() ([]byte, error) {
+	if len(v) == 1 {
+		return json.Marshal(v[0])
+	}
+	return json.Marshal([]string(v))
+}
+
+// UnmarshalJSON decodes a single value or a list of values, which can be strings, booleans or numbers
+func (v *PolicyValues) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw any
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	list, ok := raw.([]any)
+	if !ok {
+		list = []any{raw}
+	}
+	values := make(PolicyValues, 0, len(list))
+	for _, item := range list {
+		switch item := item.(type) {
+		case nil:
+		case string:
+			values = append(values, item)
+		case bool:
+			values = append(values, strconv.FormatBool(item))
+		case json.Number:
+			values = append(values, item.String())
+		default:
+			return fmt.Errorf("invalid policy value %s", data)
+		}
+	}
+	*v = values
+	return nil
+}
+
+// BucketARN returns the ARN of the provided bucket, to be used as a policy resource
+func BucketARN(bucket string) string {
+	return "arn:aws:s3:::" + bucket
+}
+
+// ObjectARN returns the ARN of the objects of the provided bucket that match the key, which can contain wildcards, to be used as a policy resource
+func ObjectARN(bucket, key string) string {
+	return BucketARN(bucket) + "/" + key
+}
+
+// EnforceTLSStatement returns a statement that denies any request to the provided bucket or its objects that is not sent over TLS
+func EnforceTLSStatement(bucket string) PolicyStatement {
+	return PolicyStatement{
+		Sid:       EnforceTLSSid,
+		Effect:    PolicyDeny,
+		Principal: &PolicyPrincipal{Everyone: true},
+		Action:    PolicyValues{"s3:*"},
+		Resource:  PolicyValues{BucketARN(bucket), ObjectARN(bucket, "*")},
+		Condition: PolicyConditions{"Bool": {"aws:SecureTransport": {"false"}}},
+	}
+}
+
+// AllowReadStatement returns a statement with the provided sid that allows the principal (e.g. an IAM role) with the provided ARN
+// to get the objects under the prefix of the provided bucket
+func AllowReadStatement(sid, principalARN, bucket, prefix string) PolicyStatement {
+	return PolicyStatement{
+		Sid:       sid,
+		Effect:    PolicyAllow,
+		Principal: &PolicyPrincipal{AWS: PolicyValues{principalARN}},
+		Action:    PolicyValues{"s3:GetObject"},
+		Resource:  PolicyValues{ObjectARN(bucket, prefix+"*")},
+	}
+}
+
+// PolicyOptions represents the optional settings of the bucket policy methods
+type PolicyOptions struct {
+	// Bucket is the bucket whose policy is managed, instead of the bucket configured for the client
+	Bucket string
+}
+
+// PolicyOption is an option that can be provided to the bucket policy methods
+type PolicyOption func(*PolicyOptions)
+
+// PolicyBucket manages the policy of the provided bucket, instead of the bucket configured for the client
+func PolicyBucket(bucket string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Bucket = bucket
+	}
+}
+
+// policyBucket returns the bucket whose policy is managed with the provided options,
+// which is the bucket configured for the client unless another one is provided
+func (cli *Client) policyBucket(opts []PolicyOption) string {
+	var o PolicyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Bucket == "" {
+		return cli.bucketName
+	}
+	return o.Bucket
+}
+
+// GetPolicy returns the policy of the bucket configured for this client (or the bucket provided with PolicyBucket), or nil if it has no policy
+func (cli *Client) GetPolicy(ctx context.Context, opts ...PolicyOption) (*Policy, error) {
+	bucket := cli.policyBucket(opts)
+	logData := log.Data{
+		"bucket_name": bucket,
+	}
+
+	policy, err := cli.getPolicy(ctx, bucket)
+	if err != nil {
+		return nil, NewError(err, logData)
+	}
+	return policy, nil
+}
+
+// PutPolicy replaces the policy of the bucket configured for this client with the provided one, which is validated before it is sent.
+// If the policy is nil or has no statements, the bucket policy is deleted.
+func (cli *Client) PutPolicy(ctx context.Context, policy *Policy, opts ...PolicyOption) error {
+	bucket := cli.policyBucket(opts)
+	logData := log.Data{
+		"bucket_name": bucket,
+	}
+
+	if err := cli.putPolicy(ctx, bucket, policy); err != nil {
+		return NewError(err, logData)
+	}
+	return nil
+}
+
+// AddPolicyStatements adds the provided statements to the policy of the bucket configured for this client, replacing the statements with the same sids,
+// and creating the policy if it does not exist. The policy is only put if it changed, which is returned.
+// Concurrent changes to the policy made between getting and putting it are lost, as S3 does not support conditional policy updates.
+func (cli *Client) AddPolicyStatements(ctx context.Context, statements []PolicyStatement, opts ...PolicyOption) (bool, error) {
+	bucket := cli.policyBucket(opts)
+	logData := log.Data{
+		"bucket_name":    bucket,
+		"num_statements": len(statements),
+	}
+
+	policy, err := cli.getPolicy(ctx, bucket)
+	if err != nil {
+		return false, NewError(err, logData)
+	}
+	if policy == nil {
+		policy = &Policy{Version: PolicyVersion}
+	}
+
+	changed := false
+	for _, stmt := range statements {
+		added, err := policy.AddStatement(stmt)
+		if err != nil {
+			return false, NewError(err, logData)
+		}
+		changed = changed || added
+	}
+	if !changed {
+		return false, nil
+	}
+	if err := cli.putPolicy(ctx, bucket, policy); err != nil {
+		return false, NewError(err, logData)
+	}
+	return true, nil
+}
+
+// RemovePolicyStatements removes the statements with the provided sids from the policy of the bucket configured for this client,
+// deleting the policy if no statements are left. The policy is only put if it changed, which is returned.
+func (cli *Client) RemovePolicyStatements(ctx context.Context, sids []string, opts ...PolicyOption) (bool, error) {
+	bucket := cli.policyBucket(opts)
+	logData := log.Data{
+		"bucket_name": bucket,
+		"sids":        sids,
+	}
+
+	policy, err := cli.getPolicy(ctx, bucket)
+	if err != nil {
+		return false, NewError(err, logData)
+	}
+	if policy == nil {
+		return false, nil
+	}
+
+	changed := false
+	for _, sid := range sids {
+		changed = policy.RemoveStatement(sid) || changed
+	}
+	if !changed {
+		return false, nil
+	}
+	if err := cli.putPolicy(ctx, bucket, policy); err != nil {
+		return false, NewError(err, logData)
+	}
+	return true, nil
+}
+
+// getPolicy returns the parsed policy of the provided bucket, or nil if it has no policy
+func (cli *Client) getPolicy(ctx context.Context, bucket string) (*Policy, error) {
+	out, err := cli.sdkClient.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		if errorCode(err) == errCodeNoSuchBucketPolicy {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting bucket policy from s3: %w", err)
+	}
+	if aws.ToString(out.Policy) == "" {
+		return nil, nil
+	}
+	return ParsePolicy(aws.ToString(out.Policy))
+}
+
+// putPolicy validates and replaces the policy of the provided bucket, deleting it if there are no statements, as S3 does not allow empty policies
+func (cli *Client) putPolicy(ctx context.Context, bucket string, policy *Policy) error {
+	if policy == nil || len(policy.Statement) == 0 {
+		if _, err := cli.sdkClient.DeleteBucketPolicy(ctx, &s3.DeleteBucketPolicyInput{
+			Bucket: aws.String(bucket),
+		}); err != nil {
+			return fmt.Errorf("error deleting bucket policy from s3: %w", err)
+		}
+		return nil
+	}
+
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	document, err := policy.Marshal()
+	if err != nil {
+		return err
+	}
+	if len(document) > MaxPolicySize {
+		return fmt.Errorf("policy document has %d bytes, but buckets can have policies up to %d bytes", len(document), MaxPolicySize)
+	}
+
+	if _, err := cli.sdkClient.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
+		Bucket: aws.String(bucket),
+		Policy: aws.String(document),
+	}); err != nil {
+		return fmt.Errorf("error putting bucket policy to s3: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package s3_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// readerRole is the ARN of the IAM role that is allowed to read the objects under the public prefix
+const readerRole = "arn:aws:iam::123456789012:role/reader"
+
+// s3Policy is a bucket policy document as S3 returns it, with a single statement and boolean condition values
+const s3Policy = `{
+	"Version": "2012-10-17",
+	"Id": "dp-policy",
+	"Statement": {
+		"Sid": "EnforceTLS",
+		"Effect": "Deny",
+		"Principal": "*",
+		"Action": "s3:*",
+		"Resource": ["arn:aws:s3:::csv-exported/*", "arn:aws:s3:::csv-exported"],
+		"Condition": {"Bool": {"aws:SecureTransport": false}, "NumericLessThan": {"s3:TlsVersion": 1.2}}
+	}
+}`
+
+// newPolicyMock returns an S3 client mock for buckets with the provided policy documents, or without a policy if there is none,
+// which are replaced when they are put or deleted
+func newPolicyMock(policies map[string]string) *mock.S3SDKClientMock {
+	return &mock.S3SDKClientMock{
+		GetBucketPolicyFunc: func(ctx context.Context, in *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
+			policy, ok := policies[*in.Bucket]
+			if !ok {
+				return nil, newResponseError(http.StatusNotFound, "NoSuchBucketPolicy")
+			}
+			return &s3.GetBucketPolicyOutput{Policy: aws.String(policy)}, nil
+		},
+		PutBucketPolicyFunc: func(ctx context.Context, in *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error) {
+			policies[*in.Bucket] = *in.Policy
+			return &s3.PutBucketPolicyOutput{}, nil
+		},
+		DeleteBucketPolicyFunc: func(ctx context.Context, in *s3.DeleteBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketPolicyOutput, error) {
+			delete(policies, *in.Bucket)
+			return &s3.DeleteBucketPolicyOutput{}, nil
+		},
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	Convey("ParsePolicy parses single statements and values, everyone principals and non-string condition values", t, func() {
+		policy, err := dps3.ParsePolicy(s3Policy)
+		So(err, ShouldBeNil)
+		So(policy.Version, ShouldEqual, dps3.PolicyVersion)
+		So(policy.ID, ShouldEqual, "dp-policy")
+		So(policy.Statement, ShouldHaveLength, 1)
+		stmt := policy.Statement[0]
+		So(stmt.Effect, ShouldEqual, dps3.PolicyDeny)
+		So(stmt.Principal, ShouldResemble, &dps3.PolicyPrincipal{Everyone: true})
+		So(stmt.Action, ShouldResemble, dps3.PolicyValues{"s3:*"})
+		So(stmt.Condition, ShouldResemble, dps3.PolicyConditions{
+			"Bool":            {"aws:SecureTransport": {"false"}},
+			"NumericLessThan": {"s3:TlsVersion": {"1.2"}},
+		})
+
+		Convey("Which is equivalent to the statement returned by EnforceTLSStatement, regardless of the order of values", func() {
+			stmt := dps3.EnforceTLSStatement("csv-exported")
+			stmt.Condition["NumericLessThan"] = map[string]dps3.PolicyValues{"s3:TlsVersion": {"1.2"}}
+			changed, err := policy.AddStatement(stmt)
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeFalse)
+		})
+	})
+
+	Convey("ParsePolicy parses principals objects", t, func() {
+		policy, err := dps3.ParsePolicy(`{"Statement": [{"Effect": "Allow", "Principal": {"AWS": ["a", "b"], "Service": "s3.amazonaws.com"}, "Action": "s3:GetObject", "Resource": "*"}]}`)
+		So(err, ShouldBeNil)
+		So(policy.Statement[0].Principal, ShouldResemble, &dps3.PolicyPrincipal{AWS: dps3.PolicyValues{"a", "b"}, Service: dps3.PolicyValues{"s3.amazonaws.com"}})
+	})
+
+	Convey("ParsePolicy fails for invalid policy documents", t, func() {
+		for _, document := range []string{`not json`, `{"Statement": [{"Principal": "someone"}]}`, `{"Statement": [{"Action": {"a": "b"}}]}`} {
+			_, err := dps3.ParsePolicy(document)
+			So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("Marshal returns a policy document that can be parsed to the same policy, with the default version", t, func() {
+		policy := &dps3.Policy{Statement: []dps3.PolicyStatement{
+			dps3.EnforceTLSStatement("csv-exported"),
+			dps3.AllowReadStatement("AllowReader", readerRole, "csv-exported", "public/"),
+		}}
+		document, err := policy.Marshal()
+		So(err, ShouldBeNil)
+		So(document, ShouldContainSubstring, `"Principal":"*"`)
+		So(document, ShouldContainSubstring, `"Principal":{"AWS":"`+readerRole+`"}`)
+
+		parsed, err := dps3.ParsePolicy(document)
+		So(err, ShouldBeNil)
+		So(parsed.Version, ShouldEqual, dps3.PolicyVersion)
+		So(parsed.Statement, ShouldResemble, policy.Statement)
+	})
+}
+
+func TestPolicyStatements(t *testing.T) {
+	Convey("Given a policy with a statement", t, func() {
+		policy := &dps3.Policy{Statement: []dps3.PolicyStatement{dps3.EnforceTLSStatement("csv-exported")}}
+
+		Convey("AddStatement adds statements with new sids, and replaces the statements with the same sid only if they are different", func() {
+			read := dps3.AllowReadStatement("AllowReader", readerRole, "csv-exported", "public/")
+			changed, err := policy.AddStatement(read)
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeTrue)
+			So(policy.Statement, ShouldHaveLength, 2)
+
+			changed, err = policy.AddStatement(read)
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeFalse)
+
+			changed, err = policy.AddStatement(dps3.AllowReadStatement("AllowReader", readerRole, "csv-exported", "private/"))
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeTrue)
+			So(policy.Statement, ShouldHaveLength, 2)
+			stmt, ok := policy.FindStatement("AllowReader")
+			So(ok, ShouldBeTrue)
+			So(stmt.Resource, ShouldResemble, dps3.PolicyValues{"arn:aws:s3:::csv-exported/private/*"})
+		})
+
+		Convey("AddStatement fails for statements without sid or with invalid elements", func() {
+			invalid := []dps3.PolicyStatement{
+				dps3.AllowReadStatement("", readerRole, "csv-exported", ""),
+				{Sid: "NoEffect", Principal: &dps3.PolicyPrincipal{Everyone: true}, Action: dps3.PolicyValues{"s3:*"}, Resource: dps3.PolicyValues{"*"}},
+				{Sid: "NoPrincipal", Effect: dps3.PolicyAllow, Action: dps3.PolicyValues{"s3:*"}, Resource: dps3.PolicyValues{"*"}},
+				{Sid: "NoAction", Effect: dps3.PolicyAllow, Principal: &dps3.PolicyPrincipal{Everyone: true}, Resource: dps3.PolicyValues{"*"}},
+				{Sid: "BothResources", Effect: dps3.PolicyAllow, Principal: &dps3.PolicyPrincipal{Everyone: true}, Action: dps3.PolicyValues{"s3:*"}, Resource: dps3.PolicyValues{"*"}, NotResource: dps3.PolicyValues{"*"}},
+			}
+			for _, stmt := range invalid {
+				_, err := policy.AddStatement(stmt)
+				So(err, ShouldNotBeNil)
+			}
+			So(policy.Statement, ShouldHaveLength, 1)
+		})
+
+		Convey("RemoveStatement removes the statement with the provided sid, if it exists", func() {
+			So(policy.RemoveStatement("AllowReader"), ShouldBeFalse)
+			So(policy.RemoveStatement(dps3.EnforceTLSSid), ShouldBeTrue)
+			So(policy.Statement, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestBucketPolicy(t *testing.T) {
+	Convey("Given an S3 client for a bucket without policy", t, func() {
+		ctx := context.Background()
+		policies := map[string]string{}
+		sdkMock := newPolicyMock(policies)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetPolicy returns a nil policy, as does the deprecated GetBucketPolicy", func() {
+			policy, err := cli.GetPolicy(ctx)
+			So(err, ShouldBeNil)
+			So(policy, ShouldBeNil)
+			So(*sdkMock.GetBucketPolicyCalls()[0].In.Bucket, ShouldEqual, ExistingBucket)
+
+			out, err := cli.GetBucketPolicy(ctx, "")
+			So(err, ShouldBeNil)
+			So(out, ShouldBeNil)
+			So(*sdkMock.GetBucketPolicyCalls()[1].In.Bucket, ShouldEqual, ExistingBucket)
+		})
+
+		Convey("AddPolicyStatements creates the policy, and does not put it again if the statements are added again", func() {
+			statements := []dps3.PolicyStatement{
+				dps3.EnforceTLSStatement(ExistingBucket),
+				dps3.AllowReadStatement("AllowReader", readerRole, ExistingBucket, "public/"),
+			}
+			changed, err := cli.AddPolicyStatements(ctx, statements)
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeTrue)
+			So(sdkMock.PutBucketPolicyCalls(), ShouldHaveLength, 1)
+			So(*sdkMock.PutBucketPolicyCalls()[0].In.Bucket, ShouldEqual, ExistingBucket)
+
+			policy, err := cli.GetPolicy(ctx)
+			So(err, ShouldBeNil)
+			So(policy.Version, ShouldEqual, dps3.PolicyVersion)
+			So(policy.Statement, ShouldResemble, statements)
+
+			changed, err = cli.AddPolicyStatements(ctx, statements)
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeFalse)
+			So(sdkMock.PutBucketPolicyCalls(), ShouldHaveLength, 1)
+
+			Convey("And RemovePolicyStatements removes them, deleting the policy when no statements are left", func() {
+				changed, err := cli.RemovePolicyStatements(ctx, []string{"AllowReader", "Unknown"})
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeTrue)
+				So(sdkMock.PutBucketPolicyCalls(), ShouldHaveLength, 2)
+
+				changed, err = cli.RemovePolicyStatements(ctx, []string{dps3.EnforceTLSSid})
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeTrue)
+				So(sdkMock.DeleteBucketPolicyCalls(), ShouldHaveLength, 1)
+				So(policies, ShouldBeEmpty)
+
+				changed, err = cli.RemovePolicyStatements(ctx, []string{dps3.EnforceTLSSid})
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeFalse)
+			})
+		})
+
+		Convey("The policy of another bucket is managed if it is explicitly provided", func() {
+			changed, err := cli.AddPolicyStatements(ctx, []dps3.PolicyStatement{dps3.EnforceTLSStatement("other-bucket")}, dps3.PolicyBucket("other-bucket"))
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeTrue)
+			So(*sdkMock.GetBucketPolicyCalls()[0].In.Bucket, ShouldEqual, "other-bucket")
+			So(*sdkMock.PutBucketPolicyCalls()[0].In.Bucket, ShouldEqual, "other-bucket")
+			So(policies, ShouldContainKey, "other-bucket")
+			So(policies, ShouldNotContainKey, ExistingBucket)
+		})
+
+		Convey("PutPolicy fails without sending any request if the policy is not valid", func() {
+			err := cli.PutPolicy(ctx, &dps3.Policy{Statement: []dps3.PolicyStatement{
+				dps3.EnforceTLSStatement(ExistingBucket),
+				dps3.EnforceTLSStatement(ExistingBucket),
+			}})
+			So(err, ShouldNotBeNil)
+			So(sdkMock.PutBucketPolicyCalls(), ShouldBeEmpty)
+		})
+
+		Convey("PutPolicy without statements deletes the policy", func() {
+			So(cli.PutPolicy(ctx, nil), ShouldBeNil)
+			So(sdkMock.DeleteBucketPolicyCalls(), ShouldHaveLength, 1)
+			So(*sdkMock.DeleteBucketPolicyCalls()[0].In.Bucket, ShouldEqual, ExistingBucket)
+		})
+	})
+
+	Convey("Given an S3 client for a bucket that fails to return its policy", t, func() {
+		errPolicy := errors.New("access denied")
+		sdkMock := &mock.S3SDKClientMock{
+			GetBucketPolicyFunc: func(ctx context.Context, in *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
+				return nil, errPolicy
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetPolicy, AddPolicyStatements and RemovePolicyStatements fail with its error without putting any policy", func() {
+			_, err := cli.GetPolicy(context.Background())
+			So(errors.Is(err, errPolicy), ShouldBeTrue)
+			_, err = cli.AddPolicyStatements(context.Background(), []dps3.PolicyStatement{dps3.EnforceTLSStatement(ExistingBucket)})
+			So(errors.Is(err, errPolicy), ShouldBeTrue)
+			_, err = cli.RemovePolicyStatements(context.Background(), []string{dps3.EnforceTLSSid})
+			So(errors.Is(err, errPolicy), ShouldBeTrue)
+		})
+	})
+}
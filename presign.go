@@ -0,0 +1,180 @@
+// file: presign.go
+//
+// Contains methods to generate presigned URLs for objects of the bucket configured for the client,
+// so that they can be downloaded or uploaded directly from S3 without proxying their content.
+// URLs are signed with the credentials of the client, using its endpoint options.
+//
+// Presigned requests are authorised with the permissions of the client credentials, which require "s3:GetObject" action
+// allowed by IAM policy to get and head objects, "s3:PutObject" to put objects and upload parts,
+// and "s3:ListBucketMultipartUploads" for the bucket to find the multipart upload of a part.
+package s3
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// MaxPresignExpiry is the maximum time a presigned URL can be valid for with SigV4 (7 days).
+// URLs signed with temporary credentials expire when the credentials do, if that happens earlier.
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// PresignPutOptions represents the constraints of a presigned PUT request. The ones that are provided are signed,
+// so the request must be sent with the same values. The content type and length are headers, returned in the SignedHeader
+// of the presigned request, and the checksum is a query parameter of its URL.
+type PresignPutOptions struct {
+	ContentType string
+
+	// ContentLength is the exact size of the content to upload, if it is greater than zero
+	ContentLength int64
+
+	// ChecksumSHA256 is the SHA-256 digest of the content to upload, which S3 verifies
+	ChecksumSHA256 []byte
+}
+
+// PresignGet returns a presigned GET request for the object for the given key (inside the bucket configured for this client),
+// which is valid for the provided duration. Presign options, like a custom presigner, can be provided.
+func (cli *Client) PresignGet(ctx context.Context, key string, expires time.Duration, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"expires":     expires.String(),
+	}
+
+	if err := validatePresignExpiry(expires); err != nil {
+		return nil, NewInvalidExpiryError(err, logData)
+	}
+
+	req, err := cli.presignClient().PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+	}, presignOptions(expires, optFns)...)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error presigning get request: %w", err), logData)
+	}
+	return req, nil
+}
+
+// PresignHead returns a presigned HEAD request for the object for the given key (inside the bucket configured for this client),
+// which is valid for the provided duration. Presign options, like a custom presigner, can be provided.
+func (cli *Client) PresignHead(ctx context.Context, key string, expires time.Duration, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"expires":     expires.String(),
+	}
+
+	if err := validatePresignExpiry(expires); err != nil {
+		return nil, NewInvalidExpiryError(err, logData)
+	}
+
+	req, err := cli.presignClient().PresignHeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+	}, presignOptions(expires, optFns)...)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error presigning head request: %w", err), logData)
+	}
+	return req, nil
+}
+
+// PresignPut returns a presigned PUT request to upload the object for the given key (inside the bucket configured for this client),
+// which is valid for the provided duration and constrained by the provided options.
+// Presign options, like a custom presigner, can be provided.
+func (cli *Client) PresignPut(ctx context.Context, key string, expires time.Duration, opts PresignPutOptions, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"expires":     expires.String(),
+	}
+
+	if err := validatePresignExpiry(expires); err != nil {
+		return nil, NewInvalidExpiryError(err, logData)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.ContentLength > 0 {
+		input.ContentLength = aws.Int64(opts.ContentLength)
+	}
+	if len(opts.ChecksumSHA256) > 0 {
+		input.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(opts.ChecksumSHA256))
+	}
+
+	req, err := cli.presignClient().PresignPutObject(ctx, input, presignOptions(expires, optFns)...)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error presigning put request: %w", err), logData)
+	}
+	return req, nil
+}
+
+// PresignUploadPart returns a presigned request to upload the provided part of the multipart upload for the given key
+// (inside the bucket configured for this client), which is valid for the provided duration.
+// The multipart upload in progress for the key is used, or created if there is none, like UploadPart does,
+// and it is completed by CheckPartUploaded once all its parts are uploaded.
+// Presign options, like a custom presigner, can be provided.
+func (cli *Client) PresignUploadPart(ctx context.Context, uploadKey string, partNumber int32, expires time.Duration, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"upload_key":  uploadKey,
+		"part_number": partNumber,
+		"expires":     expires.String(),
+	}
+
+	if err := validatePresignExpiry(expires); err != nil {
+		return nil, NewInvalidExpiryError(err, logData)
+	}
+	if partNumber < 1 || partNumber > maxParts {
+		return nil, NewError(fmt.Errorf("part number must be between 1 and %d", maxParts), logData)
+	}
+
+	uploadID, err := cli.doGetOrCreateMultipartUpload(ctx, &UploadPartRequest{UploadKey: uploadKey}, nil)
+	if err != nil {
+		return nil, NewError(err, logData)
+	}
+	logData["upload_id"] = uploadID
+
+	req, err := cli.presignClient().PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(cli.bucketName),
+		Key:        aws.String(uploadKey),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(partNumber),
+	}, presignOptions(expires, optFns)...)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error presigning upload part request: %w", err), logData)
+	}
+	return req, nil
+}
+
+// presignClient returns an SDK presign client for the config and endpoint options of this client
+func (cli *Client) presignClient() *s3.PresignClient {
+	return s3.NewPresignClient(s3.NewFromConfig(cli.cfg, cli.optFns...))
+}
+
+// presignOptions returns the provided presign options, preceded by the expiry, so that they can override it
+func presignOptions(expires time.Duration, optFns []func(*s3.PresignOptions)) []func(*s3.PresignOptions) {
+	return append([]func(*s3.PresignOptions){s3.WithPresignExpires(expires)}, optFns...)
+}
+
+// validatePresignExpiry returns an error if the provided expiry is not positive or exceeds MaxPresignExpiry
+func validatePresignExpiry(expires time.Duration) error {
+	if expires <= 0 {
+		return errors.New("presigned url expiry must be positive")
+	}
+	if expires > MaxPresignExpiry {
+		return fmt.Errorf("presigned url expiry cannot exceed %s", MaxPresignExpiry)
+	}
+	return nil
+}
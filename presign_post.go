@@ -0,0 +1,166 @@
+// file: presign_post.go
+//
+// Contains methods to generate presigned POST policies for objects of the bucket configured for the client,
+// so that they can be uploaded directly to S3 from HTML forms, constrained by the conditions of a PostPolicy.
+//
+// Presigned POST requests are authorised with the permissions of the client credentials,
+// which require "s3:PutObject" action allowed by IAM policy for the uploaded objects.
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// filenameVariable is replaced by S3 with the name of the file uploaded by a form
+const filenameVariable = "${filename}"
+
+// PostPolicy is a builder of the conditions of a presigned POST policy. Conditions on form fields that have a fixed value
+// also add the field to the presigned POST, so that it can be included in the form as it is.
+type PostPolicy struct {
+	keyPrefix  string
+	conditions []interface{}
+	fields     map[string]string
+	errs       []error
+}
+
+// NewPostPolicy returns a new PostPolicy without conditions
+func NewPostPolicy() *PostPolicy {
+	return &PostPolicy{fields: map[string]string{}}
+}
+
+// KeyStartsWith allows any key that starts with the provided prefix, instead of only the key of the presigned POST
+func (p *PostPolicy) KeyStartsWith(prefix string) *PostPolicy {
+	p.keyPrefix = prefix
+	p.conditions = append(p.conditions, []interface{}{"starts-with", "$key", prefix})
+	return p
+}
+
+// ContentLengthRange requires the size of the uploaded file to be between the provided minimum and maximum, in bytes
+func (p *PostPolicy) ContentLengthRange(min, max int64) *PostPolicy {
+	if min < 0 || max < min {
+		p.errs = append(p.errs, fmt.Errorf("invalid content length range [%d, %d]", min, max))
+	}
+	p.conditions = append(p.conditions, []interface{}{"content-length-range", min, max})
+	return p
+}
+
+// ContentType requires the Content-Type field of the form to be the provided one
+func (p *PostPolicy) ContentType(contentType string) *PostPolicy {
+	return p.field("Content-Type", contentType)
+}
+
+// ContentTypeStartsWith requires the Content-Type field of the form to start with the provided prefix (e.g. "image/")
+func (p *PostPolicy) ContentTypeStartsWith(prefix string) *PostPolicy {
+	p.conditions = append(p.conditions, []interface{}{"starts-with", "$Content-Type", prefix})
+	return p
+}
+
+// SuccessActionRedirect makes S3 redirect the browser to the provided URL once the file is uploaded
+func (p *PostPolicy) SuccessActionRedirect(redirectURL string) *PostPolicy {
+	return p.field("success_action_redirect", redirectURL)
+}
+
+// SuccessActionStatus makes S3 respond with the provided status once the file is uploaded, which must be 200, 201 or 204
+func (p *PostPolicy) SuccessActionStatus(status int) *PostPolicy {
+	if status != 200 && status != 201 && status != 204 {
+		p.errs = append(p.errs, fmt.Errorf("invalid success action status %d", status))
+	}
+	return p.field("success_action_status", strconv.Itoa(status))
+}
+
+// Metadata requires the provided user metadata to be set for the uploaded object
+func (p *PostPolicy) Metadata(name, value string) *PostPolicy {
+	return p.field("x-amz-meta-"+strings.ToLower(name), value)
+}
+
+// field requires the provided form field to have the provided value, and adds it to the fields of the presigned POST
+func (p *PostPolicy) field(name, value string) *PostPolicy {
+	p.conditions = append(p.conditions, map[string]string{name: value})
+	p.fields[name] = value
+	return p
+}
+
+// PresignPostOptions represents the configuration of a presigned POST
+type PresignPostOptions struct {
+	// Key is the key of the uploaded object. If the policy allows a key prefix, it is the default key of the form,
+	// which can contain the ${filename} variable, and it is the prefix followed by ${filename} if it is empty.
+	Key string
+
+	// Expires is the duration the policy is valid for, which must be positive and up to MaxPresignExpiry
+	Expires time.Duration
+
+	// Policy contains the conditions of the upload. If it is nil, only the key is constrained.
+	Policy *PostPolicy
+}
+
+// PresignedPost represents a presigned POST policy. The form must be sent to the URL, with all the fields,
+// and the file field last.
+type PresignedPost struct {
+	URL    string
+	Fields map[string]string
+}
+
+// PresignPost returns a presigned POST policy to upload an object to the bucket configured for this client from an HTML form,
+// constrained by the conditions of the provided policy. Presign options, like a custom post presigner, can be provided.
+func (cli *Client) PresignPost(ctx context.Context, opts PresignPostOptions, optFns ...func(*s3.PresignPostOptions)) (*PresignedPost, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      opts.Key, // key is the s3 filename with path (it's not a cryptographic key)
+		"expires":     opts.Expires.String(),
+	}
+
+	if err := validatePresignExpiry(opts.Expires); err != nil {
+		return nil, NewInvalidExpiryError(err, logData)
+	}
+
+	policy := opts.Policy
+	if policy == nil {
+		policy = NewPostPolicy()
+	}
+	if err := errors.Join(policy.errs...); err != nil {
+		return nil, NewError(fmt.Errorf("invalid post policy: %w", err), logData)
+	}
+
+	key := opts.Key
+	switch {
+	case key == "" && policy.keyPrefix == "":
+		return nil, NewError(errors.New("a key or a key prefix must be provided for a presigned post"), logData)
+	case key == "":
+		key = policy.keyPrefix + filenameVariable
+	case !strings.HasPrefix(key, policy.keyPrefix):
+		logData["key_prefix"] = policy.keyPrefix
+		return nil, NewError(errors.New("the key of a presigned post must start with the key prefix of its policy"), logData)
+	}
+
+	req, err := cli.presignClient().PresignPostObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+	}, append([]func(*s3.PresignPostOptions){func(o *s3.PresignPostOptions) {
+		o.Expires = opts.Expires
+		o.Conditions = policy.conditions
+	}}, optFns...)...)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error presigning post policy: %w", err), logData)
+	}
+
+	fields := make(map[string]string, len(req.Values)+len(policy.fields))
+	for name, value := range policy.fields {
+		fields[name] = value
+	}
+	for name, value := range req.Values {
+		fields[name] = value
+	}
+	return &PresignedPost{
+		URL:    req.URL,
+		Fields: fields,
+	}, nil
+}
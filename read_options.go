@@ -0,0 +1,152 @@
+// file: read_options.go
+//
+// Contains the options that can be provided to Get, GetWithPSK, Head and FileExists:
+// the version of the object to read, and preconditions to implement HTTP conditional request semantics
+// on top of the client, for example to avoid re-fetching objects that have not changed since they were cached.
+package s3
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ReadOptions represents the optional version and HTTP preconditions of a read request. Zero values are not sent.
+type ReadOptions struct {
+	// VersionID selects a version of the object in a versioned bucket, instead of the latest one
+	VersionID string
+
+	// IfMatch returns the object only if its ETag matches, failing with ErrPreconditionFailed otherwise
+	IfMatch string
+
+	// IfNoneMatch returns the object only if its ETag does not match, failing with ErrNotModified otherwise
+	IfNoneMatch string
+
+	// IfModifiedSince returns the object only if it has been modified after this time, failing with ErrNotModified otherwise
+	IfModifiedSince time.Time
+
+	// IfUnmodifiedSince returns the object only if it has not been modified after this time, failing with ErrPreconditionFailed otherwise
+	IfUnmodifiedSince time.Time
+}
+
+// ReadOption is an option that can be provided to Get, GetWithPSK, Head and FileExists
+type ReadOption func(*ReadOptions)
+
+// VersionID selects the provided version of the object
+func VersionID(versionID string) ReadOption {
+	return func(c *ReadOptions) {
+		c.VersionID = versionID
+	}
+}
+
+// IfMatch sets the If-Match precondition to the provided ETag
+func IfMatch(etag string) ReadOption {
+	return func(c *ReadOptions) {
+		c.IfMatch = etag
+	}
+}
+
+// IfNoneMatch sets the If-None-Match precondition to the provided ETag
+func IfNoneMatch(etag string) ReadOption {
+	return func(c *ReadOptions) {
+		c.IfNoneMatch = etag
+	}
+}
+
+// IfModifiedSince sets the If-Modified-Since precondition to the provided time
+func IfModifiedSince(t time.Time) ReadOption {
+	return func(c *ReadOptions) {
+		c.IfModifiedSince = t
+	}
+}
+
+// IfUnmodifiedSince sets the If-Unmodified-Since precondition to the provided time
+func IfUnmodifiedSince(t time.Time) ReadOption {
+	return func(c *ReadOptions) {
+		c.IfUnmodifiedSince = t
+	}
+}
+
+// newReadOptions returns the ReadOptions resulting from applying the provided options in order
+func newReadOptions(opts []ReadOption) ReadOptions {
+	c := ReadOptions{}
+	for _, cond := range opts {
+		cond(&c)
+	}
+	return c
+}
+
+// applyToGet sets the version and preconditions on the provided GetObjectInput
+func (c ReadOptions) applyToGet(input *s3.GetObjectInput) {
+	if c.VersionID != "" {
+		input.VersionId = &c.VersionID
+	}
+	if c.IfMatch != "" {
+		input.IfMatch = &c.IfMatch
+	}
+	if c.IfNoneMatch != "" {
+		input.IfNoneMatch = &c.IfNoneMatch
+	}
+	if !c.IfModifiedSince.IsZero() {
+		input.IfModifiedSince = &c.IfModifiedSince
+	}
+	if !c.IfUnmodifiedSince.IsZero() {
+		input.IfUnmodifiedSince = &c.IfUnmodifiedSince
+	}
+}
+
+// applyToHead sets the version and preconditions on the provided HeadObjectInput
+func (c ReadOptions) applyToHead(input *s3.HeadObjectInput) {
+	if c.VersionID != "" {
+		input.VersionId = &c.VersionID
+	}
+	if c.IfMatch != "" {
+		input.IfMatch = &c.IfMatch
+	}
+	if c.IfNoneMatch != "" {
+		input.IfNoneMatch = &c.IfNoneMatch
+	}
+	if !c.IfModifiedSince.IsZero() {
+		input.IfModifiedSince = &c.IfModifiedSince
+	}
+	if !c.IfUnmodifiedSince.IsZero() {
+		input.IfUnmodifiedSince = &c.IfUnmodifiedSince
+	}
+}
+
+// addToLogData adds the version and preconditions that are set to the provided log data
+func (c ReadOptions) addToLogData(logData map[string]interface{}) {
+	if c.VersionID != "" {
+		logData["version_id"] = c.VersionID
+	}
+	if c.IfMatch != "" {
+		logData["if_match"] = c.IfMatch
+	}
+	if c.IfNoneMatch != "" {
+		logData["if_none_match"] = c.IfNoneMatch
+	}
+	if !c.IfModifiedSince.IsZero() {
+		logData["if_modified_since"] = c.IfModifiedSince
+	}
+	if !c.IfUnmodifiedSince.IsZero() {
+		logData["if_unmodified_since"] = c.IfUnmodifiedSince
+	}
+}
+
+// conditionalError returns an ErrNotModified or ErrPreconditionFailed error wrapping the provided error
+// if it corresponds to a 304 or 412 response respectively, or nil otherwise.
+func conditionalError(err error, logData map[string]interface{}) error {
+	switch {
+	case httpStatusCode(err) == http.StatusNotModified || errorCode(err) == "NotModified":
+		return NewNotModifiedError(err, logData)
+	case isPreconditionFailed(err):
+		return NewPreconditionFailedError(err, logData)
+	}
+	return nil
+}
+
+// isPreconditionFailed returns true if the provided error corresponds to a 412 response
+func isPreconditionFailed(err error) bool {
+	return httpStatusCode(err) == http.StatusPreconditionFailed || errorCode(err) == "PreconditionFailed"
+}
@@ -0,0 +1,152 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newResponseError returns an error like the ones returned by the SDK for the provided HTTP status code
+func newResponseError(statusCode int, code string) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: statusCode}},
+			Err:      &smithy.GenericAPIError{Code: code},
+		},
+	}
+}
+
+func TestGetConditional(t *testing.T) {
+	Convey("Given an S3 client that returns an object", t, func() {
+		ctx := context.Background()
+		modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(testCSV))}, nil
+			},
+			HeadObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return &s3.HeadObjectOutput{}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Get sends the provided preconditions", func() {
+			_, _, err := cli.Get(ctx, testS3Key, dps3.IfNoneMatch(`"abc"`), dps3.IfModifiedSince(modified))
+			So(err, ShouldBeNil)
+			So(sdkMock.GetObjectCalls()[0].In, ShouldResemble, &s3.GetObjectInput{
+				Bucket:          &testBucket,
+				Key:             &testS3Key,
+				IfNoneMatch:     aws.String(`"abc"`),
+				IfModifiedSince: &modified,
+			})
+		})
+
+		Convey("Head sends the provided preconditions", func() {
+			_, err := cli.Head(ctx, testS3Key, dps3.IfMatch(`"abc"`), dps3.IfUnmodifiedSince(modified))
+			So(err, ShouldBeNil)
+			So(sdkMock.HeadObjectCalls()[0].In, ShouldResemble, &s3.HeadObjectInput{
+				Bucket:            &testBucket,
+				Key:               &testS3Key,
+				IfMatch:           aws.String(`"abc"`),
+				IfUnmodifiedSince: &modified,
+			})
+		})
+	})
+
+	Convey("Given an S3 client that responds with 304 Not Modified", t, func() {
+		errNotModified := newResponseError(http.StatusNotModified, "NotModified")
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return nil, errNotModified
+			},
+			HeadObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return nil, errNotModified
+			},
+		}
+		cryptoMock := &mock.S3CryptoClientMock{
+			GetObjectWithPSKFunc: func(ctx context.Context, input *s3.GetObjectInput, psk []byte) (*s3.GetObjectOutput, error) {
+				return nil, errNotModified
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, cryptoMock, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Get returns an ErrNotModified error", func() {
+			_, _, err := cli.Get(context.Background(), testS3Key, dps3.IfNoneMatch(`"abc"`))
+			var errNM *dps3.ErrNotModified
+			So(errors.As(err, &errNM), ShouldBeTrue)
+			So(errors.Is(err, errNotModified), ShouldBeTrue)
+			So(errNM.LogData()["if_none_match"], ShouldEqual, `"abc"`)
+		})
+
+		Convey("GetWithPSK returns an ErrNotModified error", func() {
+			_, _, err := cli.GetWithPSK(context.Background(), testS3Key, []byte("test psk"), dps3.IfNoneMatch(`"abc"`))
+			var errNM *dps3.ErrNotModified
+			So(errors.As(err, &errNM), ShouldBeTrue)
+		})
+
+		Convey("Head returns an ErrNotModified error", func() {
+			_, err := cli.Head(context.Background(), testS3Key, dps3.IfNoneMatch(`"abc"`))
+			var errNM *dps3.ErrNotModified
+			So(errors.As(err, &errNM), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an S3 client that responds with a PreconditionFailed error", t, func() {
+		errPrecondition := &smithy.GenericAPIError{Code: "PreconditionFailed"}
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return nil, errPrecondition
+			},
+			HeadObjectFunc: func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				return nil, newResponseError(http.StatusPreconditionFailed, "PreconditionFailed")
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Get returns an ErrPreconditionFailed error", func() {
+			_, _, err := cli.Get(context.Background(), testS3Key, dps3.IfMatch(`"abc"`))
+			var errPF *dps3.ErrPreconditionFailed
+			So(errors.As(err, &errPF), ShouldBeTrue)
+			So(errors.Is(err, errPrecondition), ShouldBeTrue)
+		})
+
+		Convey("Head returns an ErrPreconditionFailed error", func() {
+			_, err := cli.Head(context.Background(), testS3Key, dps3.IfMatch(`"abc"`))
+			var errPF *dps3.ErrPreconditionFailed
+			So(errors.As(err, &errPF), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an S3 client that fails with a generic error", t, func() {
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, input *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return nil, newResponseError(http.StatusInternalServerError, "InternalError")
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Get returns a generic S3Error", func() {
+			_, _, err := cli.Get(context.Background(), testS3Key, dps3.IfMatch(`"abc"`))
+			var errNM *dps3.ErrNotModified
+			var errPF *dps3.ErrPreconditionFailed
+			So(errors.As(err, &errNM), ShouldBeFalse)
+			So(errors.As(err, &errPF), ShouldBeFalse)
+			_, ok := err.(*dps3.S3Error)
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,136 @@
+// file: region.go
+//
+// Contains methods to discover the region a bucket was created in,
+// so that a client configured with the wrong region can be rebuilt for the right one,
+// instead of failing every call with a 301 PermanentRedirect.
+//
+// Requires "s3:ListBucket" action allowed by IAM policy for the bucket,
+// and "s3:GetBucketLocation" if the region cannot be obtained from the HeadBucket response.
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// bucketRegionHeader is the header S3 uses to report the region of a bucket, even in redirect responses
+const bucketRegionHeader = "X-Amz-Bucket-Region"
+
+// ClientOption is an option for the NewClient and NewClientWithCredentials constructors
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	discoverRegion bool
+}
+
+// WithRegionDiscovery makes the constructor discover the region of the bucket and configure the client for it,
+// if it is different from the provided one. See DiscoverRegion.
+func WithRegionDiscovery() ClientOption {
+	return func(o *clientOptions) {
+		o.discoverRegion = true
+	}
+}
+
+// applyClientOptions applies the provided options to the new client
+func (cli *Client) applyClientOptions(ctx context.Context, opts []ClientOption) error {
+	o := &clientOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.discoverRegion {
+		return cli.DiscoverRegion(ctx)
+	}
+	return nil
+}
+
+// DiscoverRegion obtains the region of the bucket configured for this client, from the HeadBucket response
+// (which reports it even if the client region is wrong) or with a GetBucketLocation call otherwise.
+// If it is different from the client region, the SDK and crypto clients are rebuilt for it,
+// so it must be called before the client is used concurrently. The new region is returned by Region().
+// Clients created with InstantiateClient cannot be rebuilt, as their SDK and crypto clients were provided by the caller,
+// so an ErrUnexpectedRegion error is returned instead, with the discovered region in its log data.
+func (cli *Client) DiscoverRegion(ctx context.Context) error {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"region":      cli.region,
+	}
+
+	region, err := cli.bucketRegion(ctx)
+	if err != nil {
+		return NewError(fmt.Errorf("error discovering bucket region: %w", err), logData)
+	}
+
+	if region != cli.region {
+		logData["discovered_region"] = region
+		if !cli.ownClients {
+			return NewUnexpectedRegionError(errors.New("bucket is not in the configured region, and the client was instantiated with clients that cannot be rebuilt for it"), logData)
+		}
+		log.Warn(ctx, "bucket is not in the configured region, using discovered region", logData)
+		cli.setRegion(region)
+	}
+	return nil
+}
+
+// bucketRegion returns the region of the bucket configured for this client
+func (cli *Client) bucketRegion(ctx context.Context) (string, error) {
+	out, err := cli.sdkClient.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(cli.bucketName),
+	})
+	if err == nil && aws.ToString(out.BucketRegion) != "" {
+		return *out.BucketRegion, nil
+	}
+	if region := regionFromError(err); region != "" {
+		return region, nil
+	}
+
+	location, locErr := cli.sdkClient.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
+		Bucket: aws.String(cli.bucketName),
+	})
+	if locErr != nil {
+		return "", errors.Join(err, locErr)
+	}
+	return regionFromLocation(string(location.LocationConstraint)), nil
+}
+
+// setRegion rebuilds the SDK and crypto clients of this client for the provided region.
+// It must only be called for clients created by NewClientWithConfig, whose clients and options are all owned by this client.
+func (cli *Client) setRegion(region string) {
+	cfg := cli.cfg.Copy()
+	cfg.Region = region
+
+	rebuilt := NewClientWithConfig(cli.bucketName, cfg, cli.optFns...)
+	cli.sdkClient = rebuilt.sdkClient
+	cli.cryptoClient = rebuilt.cryptoClient
+	cli.sdkUploader = rebuilt.sdkUploader
+	cli.cryptoUploader = rebuilt.cryptoUploader
+	cli.sdkDownloader = rebuilt.sdkDownloader
+	cli.region = region
+	cli.cfg = cfg
+}
+
+// regionFromError returns the bucket region reported in the response that caused the provided error, if any
+func regionFromError(err error) string {
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && respErr.Response != nil && respErr.Response.Response != nil {
+		return respErr.Response.Header.Get(bucketRegionHeader)
+	}
+	return ""
+}
+
+// regionFromLocation returns the region for the provided GetBucketLocation location constraint,
+// which is empty for us-east-1, and may be the legacy 'EU' value for eu-west-1.
+func regionFromLocation(location string) string {
+	switch location {
+	case "":
+		return "us-east-1"
+	case "EU":
+		return "eu-west-1"
+	}
+	return location
+}
@@ -0,0 +1,195 @@
+package s3_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newRedirectError returns an error like the one returned by the SDK when a bucket is accessed from the wrong region
+func newRedirectError(region string) error {
+	header := http.Header{}
+	header.Set("x-amz-bucket-region", region)
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusMovedPermanently, Header: header}},
+			Err:      &smithy.GenericAPIError{Code: "PermanentRedirect", Message: "Moved Permanently"},
+		},
+	}
+}
+
+// discoveredRegion returns the discovered region reported by the provided ErrUnexpectedRegion error, if it is one
+func discoveredRegion(err error) string {
+	var errRegion *dps3.ErrUnexpectedRegion
+	if !errors.As(err, &errRegion) {
+		return ""
+	}
+	region, _ := errRegion.LogData()["discovered_region"].(string)
+	return region
+}
+
+// regionHTTPClient is an HTTP client for the SDK that responds to every request with the bucket region header, recording the requests
+type regionHTTPClient struct {
+	region   string
+	mutex    sync.Mutex
+	requests []*http.Request
+}
+
+func (c *regionHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests = append(c.requests, req)
+	header := http.Header{}
+	header.Set("x-amz-bucket-region", c.region)
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: http.NoBody, Request: req}, nil
+}
+
+func TestDiscoverRegion(t *testing.T) {
+	ctx := context.Background()
+	cfg := aws.Config{Region: "eu-west-2"}
+
+	Convey("Given an S3 client created with a config for the wrong region and some SDK options", t, func() {
+		httpClient := &regionHTTPClient{region: "eu-west-1"}
+		cfg := aws.Config{
+			Region:      "eu-west-2",
+			Credentials: credentials.NewStaticCredentialsProvider("key", "secret", ""),
+			HTTPClient:  httpClient,
+		}
+		cli := dps3.NewClientWithConfig(ExistingBucket, cfg, func(o *s3.Options) { o.UsePathStyle = true })
+
+		Convey("DiscoverRegion rebuilds the clients for the discovered region, keeping the SDK options", func() {
+			err := cli.DiscoverRegion(ctx)
+			So(err, ShouldBeNil)
+			So(cli.Region(), ShouldEqual, "eu-west-1")
+			So(cli.Config().Region, ShouldEqual, "eu-west-1")
+
+			So(cli.ValidateBucket(ctx), ShouldBeNil)
+			So(httpClient.requests, ShouldHaveLength, 2)
+			So(httpClient.requests[0].URL.Host, ShouldEqual, "s3.eu-west-2.amazonaws.com")
+			So(httpClient.requests[1].URL.Host, ShouldEqual, "s3.eu-west-1.amazonaws.com")
+			So(httpClient.requests[1].URL.Path, ShouldEqual, "/"+ExistingBucket)
+
+			Convey("And GetFromS3URL validates URL regions against the discovered region", func() {
+				_, _, err := cli.GetFromS3URL(ctx, "https://s3-eu-west-2.amazonaws.com/"+ExistingBucket+"/my/file.csv", dps3.PathStyle)
+				var errRegion *dps3.ErrUnexpectedRegion
+				So(errors.As(err, &errRegion), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given an S3 client configured with the wrong region, whose HeadBucket response reports the bucket region", t, func() {
+		sdkMock := &mock.S3SDKClientMock{
+			HeadBucketFunc: func(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+				return &s3.HeadBucketOutput{BucketRegion: aws.String("eu-west-1")}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, cfg.Region, cfg)
+
+		Convey("DiscoverRegion fails with the discovered region, as the provided clients cannot be rebuilt, and keeps the configured region", func() {
+			err := cli.DiscoverRegion(ctx)
+			So(discoveredRegion(err), ShouldEqual, "eu-west-1")
+			So(cli.Region(), ShouldEqual, cfg.Region)
+			So(cli.Config().Region, ShouldEqual, cfg.Region)
+			So(*sdkMock.HeadBucketCalls()[0].In.Bucket, ShouldEqual, ExistingBucket)
+			So(sdkMock.GetBucketLocationCalls(), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given an S3 client whose HeadBucket call fails with a redirect to the bucket region", t, func() {
+		sdkMock := &mock.S3SDKClientMock{
+			HeadBucketFunc: func(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+				return nil, newRedirectError("us-west-2")
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, cfg.Region, cfg)
+
+		Convey("DiscoverRegion obtains the region from the error response", func() {
+			err := cli.DiscoverRegion(ctx)
+			So(discoveredRegion(err), ShouldEqual, "us-west-2")
+			So(sdkMock.GetBucketLocationCalls(), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given an S3 client whose HeadBucket response does not report the bucket region", t, func() {
+		location := types.BucketLocationConstraint("")
+		sdkMock := &mock.S3SDKClientMock{
+			HeadBucketFunc: func(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+				return &s3.HeadBucketOutput{}, nil
+			},
+			GetBucketLocationFunc: func(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+				return &s3.GetBucketLocationOutput{LocationConstraint: location}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, cfg.Region, cfg)
+
+		Convey("DiscoverRegion obtains the region with GetBucketLocation", func() {
+			location = types.BucketLocationConstraintApSouth1
+			err := cli.DiscoverRegion(ctx)
+			So(discoveredRegion(err), ShouldEqual, "ap-south-1")
+			So(*sdkMock.GetBucketLocationCalls()[0].In.Bucket, ShouldEqual, ExistingBucket)
+		})
+
+		Convey("An empty location constraint is discovered as us-east-1", func() {
+			err := cli.DiscoverRegion(ctx)
+			So(discoveredRegion(err), ShouldEqual, "us-east-1")
+		})
+
+		Convey("The legacy EU location constraint is discovered as eu-west-1", func() {
+			location = types.BucketLocationConstraintEu
+			err := cli.DiscoverRegion(ctx)
+			So(discoveredRegion(err), ShouldEqual, "eu-west-1")
+		})
+	})
+
+	Convey("Given an S3 client already configured with the bucket region", t, func() {
+		sdkMock := &mock.S3SDKClientMock{
+			HeadBucketFunc: func(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+				return &s3.HeadBucketOutput{BucketRegion: aws.String(cfg.Region)}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, cfg.Region, cfg)
+
+		Convey("DiscoverRegion keeps the existing SDK client", func() {
+			err := cli.DiscoverRegion(ctx)
+			So(err, ShouldBeNil)
+			So(cli.Region(), ShouldEqual, cfg.Region)
+
+			So(cli.ValidateBucket(ctx), ShouldBeNil)
+			So(sdkMock.HeadBucketCalls(), ShouldHaveLength, 2)
+		})
+	})
+
+	Convey("Given an S3 client for which neither HeadBucket nor GetBucketLocation succeed", t, func() {
+		errHead := errors.New("head failed")
+		errLocation := errors.New("location failed")
+		sdkMock := &mock.S3SDKClientMock{
+			HeadBucketFunc: func(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+				return nil, errHead
+			},
+			GetBucketLocationFunc: func(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+				return nil, errLocation
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, cfg.Region, cfg)
+
+		Convey("DiscoverRegion fails with both errors and keeps the configured region", func() {
+			err := cli.DiscoverRegion(ctx)
+			So(errors.Is(err, errHead), ShouldBeTrue)
+			So(errors.Is(err, errLocation), ShouldBeTrue)
+			So(cli.Region(), ShouldEqual, cfg.Region)
+		})
+	})
+}
@@ -0,0 +1,160 @@
+// file: registry.go
+//
+// Contains the ClientRegistry, which lazily creates and caches a Client for each permitted bucket,
+// sharing the same AWS config, so that objects can be accessed by URL regardless of their bucket.
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// BucketConfig represents a bucket permitted by a ClientRegistry, and the configuration of its client
+type BucketConfig struct {
+	// Name is the name of the bucket
+	Name string
+
+	// Region is the region of the bucket. If it is empty, the region of the registry AWS config is used.
+	Region string
+
+	// OptFns are the options of the S3 SDK client for the bucket, like a custom endpoint
+	OptFns []func(*s3.Options)
+}
+
+// NewClientFunc is a function that creates a Client for the provided bucket with the provided AWS config,
+// whose region is the one of the bucket
+type NewClientFunc func(cfg aws.Config, bucket BucketConfig) *Client
+
+// ClientRegistry holds the clients of a set of permitted buckets, which are created on first use with a shared AWS config
+type ClientRegistry struct {
+	cfg       aws.Config
+	newClient NewClientFunc
+	buckets   map[string]BucketConfig
+	clients   map[string]*Client
+	mutex     *sync.Mutex
+}
+
+// NewClientRegistry creates a new ClientRegistry with the provided AWS config, which permits access to the provided buckets only.
+func NewClientRegistry(cfg aws.Config, buckets ...BucketConfig) *ClientRegistry {
+	return InstantiateClientRegistry(cfg, func(cfg aws.Config, bucket BucketConfig) *Client {
+		return NewClientWithConfig(bucket.Name, cfg, bucket.OptFns...)
+	}, buckets...)
+}
+
+// InstantiateClientRegistry creates a new ClientRegistry with the provided AWS config and function to create clients,
+// which permits access to the provided buckets only.
+func InstantiateClientRegistry(cfg aws.Config, newClient NewClientFunc, buckets ...BucketConfig) *ClientRegistry {
+	r := &ClientRegistry{
+		cfg:       cfg,
+		newClient: newClient,
+		buckets:   make(map[string]BucketConfig, len(buckets)),
+		clients:   make(map[string]*Client, len(buckets)),
+		mutex:     &sync.Mutex{},
+	}
+	for _, bucket := range buckets {
+		if bucket.Region == "" {
+			bucket.Region = cfg.Region
+		}
+		r.buckets[bucket.Name] = bucket
+	}
+	return r
+}
+
+// Config returns the AWS config shared by the clients of this registry
+func (r *ClientRegistry) Config() aws.Config {
+	return r.cfg
+}
+
+// Client returns the client for the provided bucket, which is created the first time it is requested.
+// If the bucket is not permitted by this registry, an ErrUnexpectedBucket error is returned.
+func (r *ClientRegistry) Client(bucketName string) (*Client, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if cli, ok := r.clients[bucketName]; ok {
+		return cli, nil
+	}
+
+	bucket, ok := r.buckets[bucketName]
+	if !ok {
+		return nil, NewUnexpectedBucketError(errors.New("bucket not permitted by client registry"), log.Data{
+			"bucket_name": bucketName,
+		})
+	}
+
+	cfg := r.cfg.Copy()
+	cfg.Region = bucket.Region
+	cli := r.newClient(cfg, bucket)
+	r.clients[bucketName] = cli
+	return cli, nil
+}
+
+// GetFromURL returns an io.ReadCloser instance and the content length (size in bytes) for the given S3 URL,
+// in the format specified by URLStyle, using the client for the bucket of the URL.
+// If the bucket is not permitted by this registry, or the URL defines a region different from the one of the bucket,
+// an ErrUnexpectedBucket or ErrUnexpectedRegion error is returned.
+//
+// The caller is responsible for closing the returned ReadCloser.
+// For example, it may be closed in a defer statement: defer r.Close()
+func (r *ClientRegistry) GetFromURL(ctx context.Context, rawURL string, style URLStyle, opts ...ReadOption) (io.ReadCloser, *int64, error) {
+	cli, s3Url, err := r.clientForURL(rawURL, style)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cli.Get(ctx, s3Url.Key, opts...)
+}
+
+// GetFromURLWithPSK returns an io.ReadCloser instance and the content length (size in bytes) for the given S3 URL,
+// in the format specified by URLStyle, using the provided PSK for encryption, like GetFromURL does.
+//
+// The caller is responsible for closing the returned ReadCloser.
+// For example, it may be closed in a defer statement: defer r.Close()
+func (r *ClientRegistry) GetFromURLWithPSK(ctx context.Context, rawURL string, style URLStyle, psk []byte, opts ...ReadOption) (io.ReadCloser, *int64, error) {
+	cli, s3Url, err := r.clientForURL(rawURL, style)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cli.GetWithPSK(ctx, s3Url.Key, psk, opts...)
+}
+
+// HeadURL returns the metadata of the object for the given S3 URL, in the format specified by URLStyle,
+// using the client for the bucket of the URL, like GetFromURL does.
+func (r *ClientRegistry) HeadURL(ctx context.Context, rawURL string, style URLStyle, opts ...ReadOption) (*ObjectInfo, error) {
+	cli, s3Url, err := r.clientForURL(rawURL, style)
+	if err != nil {
+		return nil, err
+	}
+	return cli.Head(ctx, s3Url.Key, opts...)
+}
+
+// clientForURL parses the provided URL and returns the client for its bucket, validating its region if the URL defines one
+func (r *ClientRegistry) clientForURL(rawURL string, style URLStyle) (*Client, *S3Url, error) {
+	logData := log.Data{
+		"raw_url":   rawURL,
+		"url_style": style.String(),
+	}
+
+	s3Url, err := ParseURL(rawURL, style)
+	if err != nil {
+		return nil, nil, NewError(fmt.Errorf("error parsing url: %w", err), logData)
+	}
+
+	cli, err := r.Client(s3Url.BucketName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(s3Url.Region) > 0 && s3Url.Region != cli.region {
+		logData["bucket_name"] = s3Url.BucketName
+		logData["region"] = cli.region
+		return nil, nil, NewUnexpectedRegionError(errors.New("unexpected aws region in url"), logData)
+	}
+	return cli, s3Url, nil
+}
@@ -0,0 +1,127 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClientRegistry(t *testing.T) {
+	Convey("Given a client registry that permits two buckets, one of them in a different region", t, func() {
+		ctx := context.Background()
+		cfg := aws.Config{Region: "eu-west-2"}
+
+		mutex := &sync.Mutex{}
+		created := map[string]aws.Config{}
+		mocks := map[string]*mock.S3SDKClientMock{}
+		newClient := func(cfg aws.Config, bucket dps3.BucketConfig) *dps3.Client {
+			mutex.Lock()
+			defer mutex.Unlock()
+			created[bucket.Name] = cfg
+			sdkMock := &mock.S3SDKClientMock{
+				GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+					return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader([]byte(*in.Bucket))), ContentLength: aws.Int64(int64(len(*in.Bucket)))}, nil
+				},
+				HeadObjectFunc: func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+					return &s3.HeadObjectOutput{ETag: aws.String(`"abc"`)}, nil
+				},
+			}
+			mocks[bucket.Name] = sdkMock
+			return dps3.InstantiateClient(sdkMock, nil, nil, nil, bucket.Name, cfg.Region, cfg)
+		}
+
+		registry := dps3.InstantiateClientRegistry(cfg, newClient,
+			dps3.BucketConfig{Name: "inputs"},
+			dps3.BucketConfig{Name: "archive", Region: "eu-west-1"},
+		)
+
+		Convey("No clients are created until they are needed", func() {
+			So(created, ShouldBeEmpty)
+		})
+
+		Convey("Client returns the same client for a bucket every time, created with the bucket region", func() {
+			cli1, err := registry.Client("archive")
+			So(err, ShouldBeNil)
+			cli2, err := registry.Client("archive")
+			So(err, ShouldBeNil)
+			So(cli1, ShouldEqual, cli2)
+			So(cli1.BucketName(), ShouldEqual, "archive")
+			So(created, ShouldHaveLength, 1)
+			So(created["archive"].Region, ShouldEqual, "eu-west-1")
+			So(registry.Config().Region, ShouldEqual, "eu-west-2")
+		})
+
+		Convey("Client fails with ErrUnexpectedBucket for buckets that are not permitted", func() {
+			_, err := registry.Client("secrets")
+			var errBucket *dps3.ErrUnexpectedBucket
+			So(errors.As(err, &errBucket), ShouldBeTrue)
+			So(created, ShouldBeEmpty)
+		})
+
+		Convey("GetFromURL routes each URL to the client of its bucket", func() {
+			ret, _, err := registry.GetFromURL(ctx, "https://s3-eu-west-2.amazonaws.com/inputs/my/file.csv", dps3.PathStyle)
+			So(err, ShouldBeNil)
+			So(string(readBytes(ret)), ShouldEqual, "inputs")
+			So(*mocks["inputs"].GetObjectCalls()[0].In.Key, ShouldEqual, "my/file.csv")
+
+			ret, _, err = registry.GetFromURL(ctx, "https://archive.s3.amazonaws.com/old/file.csv", dps3.GlobalVirtualHostedStyle)
+			So(err, ShouldBeNil)
+			So(string(readBytes(ret)), ShouldEqual, "archive")
+			So(created, ShouldHaveLength, 2)
+		})
+
+		Convey("GetFromURL passes the read options to the client", func() {
+			_, _, err := registry.GetFromURL(ctx, "https://s3.amazonaws.com/inputs/my/file.csv", dps3.GlobalPathStyle, dps3.VersionID("v1"))
+			So(err, ShouldBeNil)
+			So(*mocks["inputs"].GetObjectCalls()[0].In.VersionId, ShouldEqual, "v1")
+		})
+
+		Convey("HeadURL routes the URL to the client of its bucket", func() {
+			info, err := registry.HeadURL(ctx, "https://archive.s3-eu-west-1.amazonaws.com/old/file.csv", dps3.VirtualHostedStyle)
+			So(err, ShouldBeNil)
+			So(info.ETag, ShouldEqual, `"abc"`)
+			So(*mocks["archive"].HeadObjectCalls()[0].In.Key, ShouldEqual, "old/file.csv")
+		})
+
+		Convey("GetFromURL fails with ErrUnexpectedBucket for URLs of buckets that are not permitted", func() {
+			_, _, err := registry.GetFromURL(ctx, "https://s3.amazonaws.com/secrets/my/file.csv", dps3.GlobalPathStyle)
+			var errBucket *dps3.ErrUnexpectedBucket
+			So(errors.As(err, &errBucket), ShouldBeTrue)
+		})
+
+		Convey("HeadURL fails with ErrUnexpectedRegion for URLs whose region is not the one of the bucket", func() {
+			_, err := registry.HeadURL(ctx, "https://s3-eu-west-2.amazonaws.com/archive/old/file.csv", dps3.PathStyle)
+			var errRegion *dps3.ErrUnexpectedRegion
+			So(errors.As(err, &errRegion), ShouldBeTrue)
+			So(mocks["archive"].HeadObjectCalls(), ShouldBeEmpty)
+		})
+
+		Convey("GetFromURL fails for URLs that cannot be parsed", func() {
+			_, _, err := registry.GetFromURL(ctx, "https://s3.amazonaws.com/", dps3.GlobalPathStyle)
+			So(err, ShouldNotBeNil)
+			So(created, ShouldBeEmpty)
+		})
+
+		Convey("Client can be called concurrently, creating a single client per bucket", func() {
+			wg := &sync.WaitGroup{}
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					registry.Client("inputs")
+				}()
+			}
+			wg.Wait()
+			So(created, ShouldHaveLength, 1)
+		})
+	})
+}
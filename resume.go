@@ -0,0 +1,338 @@
+// file: resume.go
+//
+// Contains methods to get objects from S3 with a reader that resumes the stream
+// from the current offset if it is interrupted, instead of failing the whole download.
+//
+// Requires "s3:GetObject" action allowed by IAM policy for objects inside the bucket,
+// as defined by `read-{bucketName}-bucket` policies in dp-setup
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	// DefaultResumeRetries is the default number of times a stream is resumed before failing
+	DefaultResumeRetries = 5
+
+	// DefaultResumeBackoff is the default delay before the first attempt to resume a stream
+	DefaultResumeBackoff = 200 * time.Millisecond
+
+	// DefaultResumeMaxBackoff is the default maximum delay between attempts to resume a stream
+	DefaultResumeMaxBackoff = 5 * time.Second
+)
+
+// ResumeOptions represents the retry budget of a resumable reader
+type ResumeOptions struct {
+	// MaxRetries is the total number of times the stream can be resumed, including failed attempts.
+	// If it is zero, DefaultResumeRetries is used.
+	MaxRetries int
+
+	// Backoff is the delay before the first attempt to resume the stream, which is doubled for every subsequent attempt.
+	// If it is zero, DefaultResumeBackoff is used.
+	Backoff time.Duration
+
+	// MaxBackoff is the maximum delay between attempts. If it is zero, DefaultResumeMaxBackoff is used.
+	MaxBackoff time.Duration
+}
+
+// withDefaults returns a copy of the options with the default values for the ones that are not provided
+func (opts ResumeOptions) withDefaults() ResumeOptions {
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = DefaultResumeRetries
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultResumeBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultResumeMaxBackoff
+	}
+	return opts
+}
+
+// GetResumable returns an io.ReadCloser instance for the given path (inside the bucket configured for this client)
+// and the content length (size in bytes), like Get does.
+// If reading the content fails with a retryable error (e.g. the connection is reset), the returned reader requests the rest of the object
+// from the current offset, pinned to the ETag of the original response, until the retry budget is exhausted.
+// Other errors, or the cancellation of the provided context, fail the read straight away.
+// If the object changed in the meantime, reading fails with an ErrObjectChanged error.
+//
+// The caller is responsible for closing the returned ReadCloser.
+// For example, it may be closed in a defer statement: defer r.Close()
+func (cli *Client) GetResumable(ctx context.Context, key string, opts ResumeOptions) (io.ReadCloser, *int64, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"user_psk":    false,
+	}
+
+	r, result, err := cli.newResumingReader(ctx, key, opts, logData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := decompressReader(r, result.Metadata)
+	if err != nil {
+		return nil, nil, NewError(fmt.Errorf("error decompressing object from s3: %w", err), logData)
+	}
+
+	return body, contentLength(result.Metadata, result.ContentLength), nil
+}
+
+// GetResumableWithPSK returns an io.ReadCloser instance for the given path (inside the bucket configured for this client)
+// and the content length (size in bytes), decrypting it with the provided PSK like GetWithPSK does.
+// If reading the content fails with a retryable error, the returned reader requests the rest of the object from the start of the current encryption chunk,
+// pinned to the ETag of the original response, so that only complete chunks are decrypted. Partial chunks are discarded.
+// If the object changed in the meantime, reading fails with an ErrObjectChanged error.
+//
+// The caller is responsible for closing the returned ReadCloser.
+// For example, it may be closed in a defer statement: defer r.Close()
+func (cli *Client) GetResumableWithPSK(ctx context.Context, key string, psk []byte, opts ResumeOptions) (io.ReadCloser, *int64, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"user_psk":    true,
+	}
+
+	r, result, err := cli.newResumingReader(ctx, key, opts, logData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := crypto.CheckPSK(result.Metadata, psk); err != nil {
+		r.Close()
+		return nil, nil, NewWrongPSKError(fmt.Errorf("error validating psk: %w", err), logData)
+	}
+
+	chunkSize, err := crypto.ChunkSizeFromMetadata(result.Metadata, crypto.DefaultChunkSize)
+	if err != nil {
+		r.Close()
+		return nil, nil, NewError(fmt.Errorf("error reading encryption metadata: %w", err), logData)
+	}
+	r.psk = psk
+	r.chunkSize = int64(chunkSize)
+
+	// The digest is calculated over the content before it was compressed, if the client had a codec
+	body, err := decompressReader(r, result.Metadata)
+	if err != nil {
+		return nil, nil, NewError(fmt.Errorf("error decompressing object from s3: %w", err), logData)
+	}
+	body = crypto.NewIntegrityReader(body, result.Metadata, psk)
+
+	return body, contentLength(result.Metadata, result.ContentLength), nil
+}
+
+// newResumingReader gets the object for the provided key and returns a resumingReader for its content, along with the original response
+func (cli *Client) newResumingReader(ctx context.Context, key string, opts ResumeOptions, logData log.Data) (*resumingReader, *s3.GetObjectOutput, error) {
+	result, err := cli.sdkClient.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, nil, NewError(fmt.Errorf("error getting object from s3: %w", err), logData)
+	}
+
+	if result.ETag == nil {
+		result.Body.Close()
+		return nil, nil, NewError(errors.New("s3 did not return an etag to resume the object stream"), logData)
+	}
+	logData["etag"] = *result.ETag
+
+	size := int64(-1)
+	if result.ContentLength != nil {
+		size = *result.ContentLength
+	}
+
+	return &resumingReader{
+		ctx:     ctx,
+		cli:     cli,
+		key:     key,
+		etag:    *result.ETag,
+		size:    size,
+		body:    result.Body,
+		opts:    opts.withDefaults(),
+		logData: logData,
+	}, result, nil
+}
+
+// resumingReader is an io.ReadCloser for the content of an object, which requests the rest of the object
+// from the current offset when reading fails. If a psk is set, the content is read and decrypted in whole chunks,
+// and the stream is resumed from the start of the current chunk.
+type resumingReader struct {
+	ctx     context.Context
+	cli     *Client
+	key     string
+	etag    string
+	size    int64 // -1 if unknown
+	body    io.ReadCloser
+	offset  int64 // offset of the next byte to be read from body
+	opts    ResumeOptions
+	retries int
+	logData log.Data
+
+	psk       []byte
+	chunkSize int64
+	plaintext []byte // decrypted content of the current chunk that has not been read yet
+}
+
+func (r *resumingReader) Read(p []byte) (int, error) {
+	if r.psk == nil {
+		return r.readContent(p)
+	}
+
+	if len(r.plaintext) == 0 {
+		if err := r.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plaintext)
+	r.plaintext = r.plaintext[n:]
+	return n, nil
+}
+
+// readContent reads content from the current offset, resuming the stream on failure
+func (r *resumingReader) readContent(p []byte) (int, error) {
+	for {
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+		if err == io.EOF && r.size >= 0 && r.offset < r.size {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+
+		if resumeErr := r.resume(err); resumeErr != nil {
+			return n, resumeErr
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// readChunk reads and decrypts the next encryption chunk, resuming the stream from its start on failure
+func (r *resumingReader) readChunk() error {
+	length := r.chunkSize
+	if r.size >= 0 {
+		length = min(length, r.size-r.offset)
+		if length <= 0 {
+			return io.EOF
+		}
+	}
+
+	chunk := make([]byte, length)
+	for {
+		n, err := io.ReadFull(r.body, chunk)
+		if err == io.EOF || (err == io.ErrUnexpectedEOF && r.size < 0) {
+			// the end of the object, if its size is unknown
+			if n == 0 {
+				return io.EOF
+			}
+			chunk, err = chunk[:n], nil
+		}
+		if err == nil {
+			r.offset += int64(n)
+			plaintext, err := crypto.DecryptChunk(r.psk, chunk)
+			if err != nil {
+				return NewError(fmt.Errorf("error decrypting object content: %w", err), r.logData)
+			}
+			r.plaintext = plaintext
+			return nil
+		}
+
+		// the partial chunk is discarded and requested again from its start
+		if resumeErr := r.resume(err); resumeErr != nil {
+			return resumeErr
+		}
+	}
+}
+
+// resume closes the current body and requests the rest of the object from the current offset, pinned to the original ETag,
+// waiting with exponential backoff between attempts. An error is returned if the cause, or the error of an attempt, is not retryable,
+// if the context is done, if the retry budget is exhausted or if the object changed.
+func (r *resumingReader) resume(cause error) error {
+	r.body.Close()
+	r.body = io.NopCloser(&failingReader{err: cause})
+
+	for {
+		if r.ctx.Err() != nil || !isRetryable(cause) {
+			return NewError(fmt.Errorf("error reading object from s3: %w", cause), r.logData)
+		}
+		if r.retries >= r.opts.MaxRetries {
+			return NewError(fmt.Errorf("error reading object from s3 after %d retries: %w", r.retries, cause), r.logData)
+		}
+
+		backoff := min(r.opts.Backoff<<r.retries, r.opts.MaxBackoff)
+		r.retries++
+		select {
+		case <-r.ctx.Done():
+			return NewError(fmt.Errorf("error reading object from s3: %w", r.ctx.Err()), r.logData)
+		case <-time.After(backoff):
+		}
+
+		r.logData["offset"] = r.offset
+		r.logData["attempt"] = r.retries
+		r.logData["cause"] = cause.Error()
+		log.Warn(r.ctx, "resuming interrupted s3 object stream", r.logData)
+
+		result, err := r.cli.sdkClient.GetObject(r.ctx, &s3.GetObjectInput{
+			Bucket:  aws.String(r.cli.bucketName),
+			Key:     aws.String(r.key),
+			Range:   aws.String(fmt.Sprintf("bytes=%d-", r.offset)),
+			IfMatch: aws.String(r.etag),
+		})
+		if err != nil {
+			err = fmt.Errorf("error resuming object stream from s3: %w", err)
+			if isPreconditionFailed(err) {
+				return NewObjectChangedError(err, r.logData)
+			}
+			cause = err
+			continue
+		}
+
+		r.body = result.Body
+		return nil
+	}
+}
+
+func (r *resumingReader) Close() error {
+	return r.body.Close()
+}
+
+// isRetryable returns true if the provided error is transient, so that the stream can be resumed:
+// a truncated response, a reset connection, a network timeout or a server error
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	return httpStatusCode(err) >= http.StatusInternalServerError
+}
+
+// failingReader is an io.Reader that always fails with the provided error
+type failingReader struct {
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	return 0, f.err
+}
@@ -0,0 +1,245 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testResumeOptions = dps3.ResumeOptions{MaxRetries: 3, Backoff: time.Millisecond}
+
+// interruptedReader is a reader that fails with a connection reset after the provided number of bytes
+type interruptedReader struct {
+	r     io.Reader
+	limit int
+}
+
+func (i *interruptedReader) Read(p []byte) (int, error) {
+	if i.limit <= 0 {
+		return 0, syscall.ECONNRESET
+	}
+	n, err := i.r.Read(p[:min(len(p), i.limit)])
+	i.limit -= n
+	return n, err
+}
+
+// failingReader is a reader that always fails with the provided error
+type failingReader struct {
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	return 0, f.err
+}
+
+// newResumableGetObjectFunc returns a GetObject mock function that serves ranges of the provided content with the provided ETag,
+// interrupting the first responses after the provided number of bytes.
+func newResumableGetObjectFunc(content []byte, metadata map[string]string, interruptions ...int) func(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	etag := `"abc"`
+	return func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		if in.IfMatch != nil && *in.IfMatch != etag {
+			return nil, newResponseError(http.StatusPreconditionFailed, "PreconditionFailed")
+		}
+
+		start := 0
+		if in.Range != nil {
+			start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(*in.Range, "bytes="), "-"))
+		}
+		var body io.Reader = bytes.NewReader(content[start:])
+		if len(interruptions) > 0 {
+			body = &interruptedReader{r: body, limit: interruptions[0]}
+			interruptions = interruptions[1:]
+		}
+		return &s3.GetObjectOutput{
+			Body:          io.NopCloser(body),
+			ContentLength: aws.Int64(int64(len(content) - start)),
+			ETag:          &etag,
+			Metadata:      metadata,
+		}, nil
+	}
+}
+
+func TestGetResumable(t *testing.T) {
+	Convey("Given an S3 client whose object streams are interrupted twice", t, func() {
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: newResumableGetObjectFunc(testCSV, nil, 1000, 1500),
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetResumable returns the whole content, resuming from the current offset pinned to the ETag", func() {
+			ret, cLen, err := cli.GetResumable(context.Background(), testS3Key, testResumeOptions)
+			So(err, ShouldBeNil)
+			So(*cLen, ShouldEqual, len(testCSV))
+			So(readBytes(ret), ShouldResemble, testCSV)
+
+			calls := sdkMock.GetObjectCalls()
+			So(len(calls), ShouldEqual, 3)
+			So(calls[0].In.Range, ShouldBeNil)
+			So(*calls[1].In.Range, ShouldEqual, "bytes=1000-")
+			So(*calls[1].In.IfMatch, ShouldEqual, `"abc"`)
+			So(*calls[2].In.Range, ShouldEqual, "bytes=2500-")
+		})
+
+		Convey("GetResumable fails once the retry budget is exhausted", func() {
+			ret, _, err := cli.GetResumable(context.Background(), testS3Key, dps3.ResumeOptions{MaxRetries: 1, Backoff: time.Millisecond})
+			So(err, ShouldBeNil)
+			_, err = io.ReadAll(ret)
+			So(errors.Is(err, syscall.ECONNRESET), ShouldBeTrue)
+			So(len(sdkMock.GetObjectCalls()), ShouldEqual, 2)
+		})
+	})
+
+	Convey("Given an S3 client whose object changes after the stream is interrupted", t, func() {
+		getObject := newResumableGetObjectFunc(testCSV, nil, 1000)
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				if in.IfMatch != nil {
+					in.IfMatch = aws.String(`"changed"`)
+				}
+				return getObject(ctx, in, optFns...)
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Reading fails with an ErrObjectChanged error", func() {
+			ret, _, err := cli.GetResumable(context.Background(), testS3Key, testResumeOptions)
+			So(err, ShouldBeNil)
+			_, err = io.ReadAll(ret)
+			var errChanged *dps3.ErrObjectChanged
+			So(errors.As(err, &errChanged), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an S3 client whose object stream fails with an error that is not retryable", t, func() {
+		errRead := errors.New("read failed")
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				return &s3.GetObjectOutput{
+					Body:          io.NopCloser(io.MultiReader(bytes.NewReader(testCSV[:100]), &failingReader{err: errRead})),
+					ContentLength: aws.Int64(int64(len(testCSV))),
+					ETag:          aws.String(`"abc"`),
+				}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Reading fails with the error without resuming the stream", func() {
+			ret, _, err := cli.GetResumable(context.Background(), testS3Key, testResumeOptions)
+			So(err, ShouldBeNil)
+			_, err = io.ReadAll(ret)
+			So(errors.Is(err, errRead), ShouldBeTrue)
+			So(len(sdkMock.GetObjectCalls()), ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given an S3 client whose object stream is interrupted, and whose requests to resume it fail", t, func() {
+		getObject := newResumableGetObjectFunc(testCSV, nil, 1000)
+		resumeErrs := []error{}
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				if in.Range != nil && len(resumeErrs) > 0 {
+					err := resumeErrs[0]
+					resumeErrs = resumeErrs[1:]
+					return nil, err
+				}
+				return getObject(ctx, in, optFns...)
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("A server error is retried, and the stream is resumed", func() {
+			resumeErrs = []error{newResponseError(http.StatusServiceUnavailable, "SlowDown")}
+			ret, _, err := cli.GetResumable(context.Background(), testS3Key, testResumeOptions)
+			So(err, ShouldBeNil)
+			So(readBytes(ret), ShouldResemble, testCSV)
+			So(len(sdkMock.GetObjectCalls()), ShouldEqual, 3)
+		})
+
+		Convey("A client error fails the read without further attempts", func() {
+			resumeErrs = []error{newResponseError(http.StatusForbidden, "AccessDenied")}
+			ret, _, err := cli.GetResumable(context.Background(), testS3Key, testResumeOptions)
+			So(err, ShouldBeNil)
+			_, err = io.ReadAll(ret)
+			So(err, ShouldNotBeNil)
+			So(len(sdkMock.GetObjectCalls()), ShouldEqual, 2)
+		})
+
+		Convey("The stream is not resumed once the context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			ret, _, err := cli.GetResumable(ctx, testS3Key, testResumeOptions)
+			So(err, ShouldBeNil)
+			cancel()
+			_, err = io.ReadAll(ret)
+			So(errors.Is(err, syscall.ECONNRESET), ShouldBeTrue)
+			So(len(sdkMock.GetObjectCalls()), ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given an S3 client whose response is truncated without a read error", t, func() {
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				if in.Range == nil {
+					return &s3.GetObjectOutput{
+						Body:          io.NopCloser(bytes.NewReader(testCSV[:100])),
+						ContentLength: aws.Int64(int64(len(testCSV))),
+						ETag:          aws.String(`"abc"`),
+					}, nil
+				}
+				return newResumableGetObjectFunc(testCSV, nil)(ctx, in, optFns...)
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetResumable detects the missing content and resumes the stream", func() {
+			ret, _, err := cli.GetResumable(context.Background(), testS3Key, testResumeOptions)
+			So(err, ShouldBeNil)
+			So(readBytes(ret), ShouldResemble, testCSV)
+			So(*sdkMock.GetObjectCalls()[1].In.Range, ShouldEqual, "bytes=100-")
+		})
+	})
+}
+
+func TestGetResumableWithPSK(t *testing.T) {
+	Convey("Given an S3 client whose stream of an object encrypted in chunks is interrupted mid-chunk", t, func() {
+		psk := []byte("0123456789abcdef")
+		chunkSize := 64
+		metadata := crypto.SetPSKMetadata(nil, psk, chunkSize)
+
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectFunc: newResumableGetObjectFunc(encryptChunks(psk, testCSV, chunkSize), metadata, 1000),
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetResumableWithPSK returns the decrypted content, resuming from the start of the interrupted chunk", func() {
+			ret, cLen, err := cli.GetResumableWithPSK(context.Background(), testS3Key, psk, testResumeOptions)
+			So(err, ShouldBeNil)
+			So(*cLen, ShouldEqual, len(testCSV))
+			So(readBytes(ret), ShouldResemble, testCSV)
+
+			calls := sdkMock.GetObjectCalls()
+			So(len(calls), ShouldEqual, 2)
+			So(*calls[1].In.Range, ShouldEqual, fmt.Sprintf("bytes=%d-", 1000/chunkSize*chunkSize))
+		})
+
+		Convey("GetResumableWithPSK fails with ErrWrongPSK if the psk is wrong", func() {
+			_, _, err := cli.GetResumableWithPSK(context.Background(), testS3Key, []byte("fedcba9876543210"), testResumeOptions)
+			var errWrongPSK *dps3.ErrWrongPSK
+			So(errors.As(err, &errWrongPSK), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,586 @@
+// file: sync.go
+//
+// Contains methods to synchronise a local directory with the objects under a prefix of the bucket configured for the client,
+// like `aws s3 sync` does: files that are new or changed are uploaded or downloaded with bounded concurrency,
+// and extraneous files can be deleted from the destination.
+//
+// Requires "s3:ListBucket" action allowed by IAM policy for the bucket, and "s3:PutObject" (SyncUp) or "s3:GetObject" (SyncDown)
+// for objects under the prefix, as well as "s3:DeleteObject" to delete extraneous objects.
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DefaultSyncConcurrency is the default number of files compared and transferred in parallel by SyncUp and SyncDown
+const DefaultSyncConcurrency = 8
+
+// SyncCompare is the method used to decide whether a file has changed between the source and the destination of a sync
+type SyncCompare int
+
+const (
+	// SyncCompareSizeAndTime transfers a file if its size differs, or if the source is newer than the destination.
+	// Listed sizes are compared, so objects compressed by this library are always considered changed.
+	SyncCompareSizeAndTime SyncCompare = iota
+
+	// SyncCompareChecksum transfers a file if its content differs, comparing the digest of the local file
+	// with the digest recorded for objects encrypted with a psk, or with the object ETag when it is an MD5 digest.
+	// Objects whose content cannot be compared (e.g. multipart uploads or compressed objects) are considered changed.
+	SyncCompareChecksum
+)
+
+// SyncAction is the operation performed on a file by a sync
+type SyncAction string
+
+const (
+	SyncUpload   SyncAction = "upload"
+	SyncDownload SyncAction = "download"
+	SyncDelete   SyncAction = "delete"
+)
+
+// SyncOptions represents the optional configuration of SyncUp and SyncDown
+type SyncOptions struct {
+	// Compare is the method used to decide whether a file has changed
+	Compare SyncCompare
+
+	// Delete removes the files of the destination that do not exist in the source (and are not filtered out)
+	Delete bool
+
+	// Include and Exclude are glob patterns (see path.Match) of the files to sync, matched against their slash-separated
+	// path relative to the directory or prefix, or against their name for patterns without a slash.
+	// If Include is empty, all the files are included. Excluded files are neither transferred nor deleted.
+	Include []string
+	Exclude []string
+
+	// DryRun plans the sync without transferring or deleting any file
+	DryRun bool
+
+	// Concurrency is the number of files compared and transferred in parallel. If it is zero, DefaultSyncConcurrency is used.
+	Concurrency int
+
+	// PSK encrypts uploaded files, and decrypts downloaded ones, with the provided psk
+	PSK []byte
+}
+
+// SyncOperation represents an operation planned by a sync
+type SyncOperation struct {
+	Action SyncAction
+
+	// Key and Path are the object key and the local path of the file
+	Key  string
+	Path string
+
+	// Size is the size of the source file, or of the deleted file
+	Size int64
+
+	// Reason explains why the operation is needed: "new", "size", "modified", "checksum" or "extraneous"
+	Reason string
+}
+
+// SyncResult represents the outcome of a sync
+type SyncResult struct {
+	// Operations are the operations planned by the sync, sorted by key, which were performed unless they failed or it was a dry run
+	Operations []SyncOperation
+
+	// Failed are the operations that could not be performed, sorted by key
+	Failed []SyncFailure
+}
+
+// SyncFailure represents an operation of a sync that could not be performed, and the reason
+type SyncFailure struct {
+	Operation SyncOperation
+	Err       error
+}
+
+// syncFile represents a file found at the source or the destination of a sync, by its relative path
+type syncFile struct {
+	rel     string
+	size    int64
+	modTime time.Time
+}
+
+// SyncUp uploads the files of the provided local directory to the objects under the provided prefix (inside the bucket configured for this client),
+// for the files that are new or have changed according to the compare method of the options.
+// A prefix that does not end in a slash is treated as a folder, like `aws s3 sync` does, so "site" syncs the objects under "site/".
+// Object keys are the prefix followed by the slash-separated path of the files relative to the directory.
+// The result reports the planned operations and the ones that failed, in which case an error is also returned.
+func (cli *Client) SyncUp(ctx context.Context, localDir, prefix string, opts SyncOptions) (*SyncResult, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"prefix":      prefix,
+		"local_dir":   localDir,
+		"dry_run":     opts.DryRun,
+		"user_psk":    opts.PSK != nil,
+	}
+
+	if err := validateSync(prefix, opts); err != nil {
+		return nil, NewError(err, logData)
+	}
+	prefix = syncFolder(prefix)
+
+	local, err := listLocalFiles(localDir, opts)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error listing local files: %w", err), logData)
+	}
+	remote, err := cli.listSyncObjects(ctx, prefix, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	operations, err := planSync(local, remote, opts.Delete, opts.Concurrency, func(src, dst *syncFile) (string, error) {
+		if opts.Compare == SyncCompareChecksum {
+			return cli.compareChecksum(ctx, filepath.Join(localDir, filepath.FromSlash(src.rel)), prefix+src.rel, opts.PSK)
+		}
+		return compareSizeAndTime(src, dst), nil
+	})
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error comparing files: %w", err), logData)
+	}
+
+	result := &SyncResult{Operations: make([]SyncOperation, len(operations)), Failed: []SyncFailure{}}
+	for i, op := range operations {
+		result.Operations[i] = SyncOperation{
+			Action: SyncUpload,
+			Key:    prefix + op.file.rel,
+			Path:   filepath.Join(localDir, filepath.FromSlash(op.file.rel)),
+			Size:   op.file.size,
+			Reason: op.reason,
+		}
+		if op.reason == "extraneous" {
+			result.Operations[i].Action = SyncDelete
+			result.Operations[i].Path = ""
+		}
+	}
+
+	return cli.runSync(ctx, result, opts, logData, func(ctx context.Context, op SyncOperation) error {
+		return cli.uploadSyncFile(ctx, op, opts.PSK)
+	}, func(ctx context.Context, ops []SyncOperation) []SyncFailure {
+		return cli.deleteSyncObjects(ctx, ops)
+	})
+}
+
+// SyncDown downloads the objects under the provided prefix (inside the bucket configured for this client) to the provided local directory,
+// for the objects that are new or have changed according to the compare method of the options.
+// A prefix that does not end in a slash is treated as a folder, like `aws s3 sync` does, so "site" syncs the objects under "site/".
+// Local paths are the directory joined with the keys relative to the prefix. Objects whose relative key is not a local path
+// (e.g. containing ".." elements) are ignored, so that they are never read or written outside the directory. Downloaded files are written like DownloadToFile does,
+// and their modification time is set to the last modified time of the object.
+// The result reports the planned operations and the ones that failed, in which case an error is also returned.
+func (cli *Client) SyncDown(ctx context.Context, prefix, localDir string, opts SyncOptions) (*SyncResult, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"prefix":      prefix,
+		"local_dir":   localDir,
+		"dry_run":     opts.DryRun,
+		"user_psk":    opts.PSK != nil,
+	}
+
+	if err := validateSync(prefix, opts); err != nil {
+		return nil, NewError(err, logData)
+	}
+	prefix = syncFolder(prefix)
+
+	remote, err := cli.listSyncObjects(ctx, prefix, opts)
+	if err != nil {
+		return nil, err
+	}
+	local, err := listLocalFiles(localDir, opts)
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error listing local files: %w", err), logData)
+	}
+
+	operations, err := planSync(remote, local, opts.Delete, opts.Concurrency, func(src, dst *syncFile) (string, error) {
+		if opts.Compare == SyncCompareChecksum {
+			return cli.compareChecksum(ctx, filepath.Join(localDir, filepath.FromSlash(src.rel)), prefix+src.rel, opts.PSK)
+		}
+		return compareSizeAndTime(src, dst), nil
+	})
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error comparing files: %w", err), logData)
+	}
+
+	result := &SyncResult{Operations: make([]SyncOperation, len(operations)), Failed: []SyncFailure{}}
+	for i, op := range operations {
+		result.Operations[i] = SyncOperation{
+			Action: SyncDownload,
+			Key:    prefix + op.file.rel,
+			Path:   filepath.Join(localDir, filepath.FromSlash(op.file.rel)),
+			Size:   op.file.size,
+			Reason: op.reason,
+		}
+		if op.reason == "extraneous" {
+			result.Operations[i].Action = SyncDelete
+			result.Operations[i].Key = ""
+		}
+	}
+
+	return cli.runSync(ctx, result, opts, logData, func(ctx context.Context, op SyncOperation) error {
+		return cli.downloadSyncFile(ctx, op, remote[strings.TrimPrefix(op.Key, prefix)], opts.PSK)
+	}, func(ctx context.Context, ops []SyncOperation) []SyncFailure {
+		return deleteSyncFiles(ops)
+	})
+}
+
+// validateSync returns an error if the provided prefix and options cannot be used for a sync
+func validateSync(prefix string, opts SyncOptions) error {
+	if opts.Delete && prefix == "" {
+		return errors.New("a prefix must be provided to delete extraneous files in a sync, as it would apply to the whole bucket")
+	}
+	for _, pattern := range slices.Concat(opts.Include, opts.Exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid sync pattern %q: %w", pattern, err)
+		}
+	}
+	if opts.PSK != nil && len(opts.PSK) == 0 {
+		return errors.New("empty psk provided for sync")
+	}
+	return nil
+}
+
+// syncFolder returns the provided prefix ending in a slash, unless it is empty,
+// so that it does not match the keys of sibling prefixes (e.g. "site" and "site-old/")
+func syncFolder(prefix string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		return prefix + "/"
+	}
+	return prefix
+}
+
+// syncIncluded returns true if the file with the provided slash-separated relative path is included by the filters of the options
+func syncIncluded(rel string, opts SyncOptions) bool {
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			name := rel
+			if !strings.Contains(pattern, "/") {
+				name = path.Base(rel)
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(opts.Include) == 0 || matches(opts.Include)) && !matches(opts.Exclude)
+}
+
+// listLocalFiles returns the regular files under the provided directory that are included by the filters of the options,
+// by their slash-separated path relative to the directory. A directory that does not exist has no files.
+// The temporary files of interrupted downloads are ignored, so that they are neither uploaded nor deleted, and the downloads can be resumed.
+func listLocalFiles(dir string, opts SyncOptions) (map[string]*syncFile, error) {
+	files := map[string]*syncFile{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if !d.Type().IsRegular() || isTempDownload(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !syncIncluded(rel, opts) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[rel] = &syncFile{rel: rel, size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+// listSyncObjects returns the objects under the provided prefix that are included by the filters of the options,
+// by their key relative to the prefix. Directory markers, the staging objects of buffered multipart uploads,
+// and objects whose relative key is not a local path (e.g. containing ".." elements) are ignored.
+func (cli *Client) listSyncObjects(ctx context.Context, prefix string, opts SyncOptions) (map[string]*syncFile, error) {
+	objects := map[string]*syncFile{}
+	for obj, err := range cli.List(ctx, prefix, ListOptions{}) {
+		if err != nil {
+			return nil, err
+		}
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") || strings.HasPrefix(obj.Key, MultipartStagingPrefix) || !syncIncluded(rel, opts) {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(rel)) {
+			log.Warn(ctx, "ignoring s3 object whose key is not a local path relative to the sync prefix", log.Data{
+				"bucket_name": cli.bucketName,
+				"s3_key":      obj.Key, // key is the s3 filename with path (it's not a cryptographic key)
+			})
+			continue
+		}
+		objects[rel] = &syncFile{rel: rel, size: obj.Size, modTime: obj.LastModified}
+	}
+	return objects, nil
+}
+
+// plannedSync is an operation planned by planSync, for a source file or an extraneous destination file
+type plannedSync struct {
+	file   *syncFile
+	reason string
+}
+
+// planSync returns the source files that need to be transferred, with the reason, and the extraneous destination files
+// if they are to be deleted, sorted by relative path. The files that exist in both are compared in parallel with the provided function,
+// which returns the reason for the transfer, or an empty string if the file has not changed.
+func planSync(src, dst map[string]*syncFile, withDeletions bool, concurrency int, compare func(src, dst *syncFile) (string, error)) ([]plannedSync, error) {
+	if concurrency <= 0 {
+		concurrency = DefaultSyncConcurrency
+	}
+
+	planned := []plannedSync{}
+	mutex := &sync.Mutex{}
+	errs := []error{}
+	sem := make(chan struct{}, concurrency)
+	wg := &sync.WaitGroup{}
+	for rel, file := range src {
+		existing, ok := dst[rel]
+		if !ok {
+			planned = append(planned, plannedSync{file: file, reason: "new"})
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			reason, err := compare(file, existing)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", rel, err))
+				return
+			}
+			if reason != "" {
+				planned = append(planned, plannedSync{file: file, reason: reason})
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	for rel, file := range dst {
+		if _, ok := src[rel]; !ok && withDeletions {
+			planned = append(planned, plannedSync{file: file, reason: "extraneous"})
+		}
+	}
+	sort.Slice(planned, func(i, j int) bool {
+		return planned[i].file.rel < planned[j].file.rel
+	})
+	return planned, nil
+}
+
+// compareSizeAndTime returns the reason to transfer the source file over the destination file, if its size differs,
+// or if it is newer. S3 modification times have a precision of one second.
+func compareSizeAndTime(src, dst *syncFile) string {
+	if src.size != dst.size {
+		return "size"
+	}
+	if src.modTime.Truncate(time.Second).After(dst.modTime.Truncate(time.Second)) {
+		return "modified"
+	}
+	return ""
+}
+
+// compareChecksum returns "checksum" if the content of the local file for the provided path differs from the content of the object,
+// or if it cannot be compared, and an empty string otherwise
+func (cli *Client) compareChecksum(ctx context.Context, localPath, key string, psk []byte) (string, error) {
+	head, err := cli.sdkClient.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error trying to obtain s3 object metadata with HeadObject call: %w", err)
+	}
+
+	// the content is only considered unchanged if the object is encrypted with a psk when one is provided, and only then
+	var digest hash.Hash
+	var expected string
+	recorded, hasDigest := crypto.MetadataValue(head.Metadata, crypto.DigestMetadataKey)
+	switch {
+	case psk != nil && isPSKEncrypted(head.Metadata) && hasDigest:
+		digest, expected = crypto.NewDigest(psk), recorded
+	case psk == nil && !isPSKEncrypted(head.Metadata) && !isCompressed(head.Metadata) && isMD5ETag(head):
+		digest, expected = md5.New(), strings.Trim(aws.ToString(head.ETag), `"`)
+	default:
+		return "checksum", nil
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(digest, f); err != nil {
+		return "", err
+	}
+	if hex.EncodeToString(digest.Sum(nil)) != expected {
+		return "checksum", nil
+	}
+	return "", nil
+}
+
+// runSync performs the transfers of the provided result in parallel, followed by the deletions, unless it is a dry run,
+// recording the operations that failed in the result
+func (cli *Client) runSync(ctx context.Context, result *SyncResult, opts SyncOptions, logData log.Data,
+	transfer func(ctx context.Context, op SyncOperation) error, remove func(ctx context.Context, ops []SyncOperation) []SyncFailure,
+) (*SyncResult, error) {
+	if opts.DryRun {
+		return result, nil
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultSyncConcurrency
+	}
+
+	deletions := []SyncOperation{}
+	mutex := &sync.Mutex{}
+	sem := make(chan struct{}, concurrency)
+	wg := &sync.WaitGroup{}
+	for _, op := range result.Operations {
+		if op.Action == SyncDelete {
+			deletions = append(deletions, op)
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := transfer(ctx, op); err != nil {
+				mutex.Lock()
+				result.Failed = append(result.Failed, SyncFailure{Operation: op, Err: err})
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(deletions) > 0 {
+		result.Failed = append(result.Failed, remove(ctx, deletions)...)
+	}
+
+	sort.Slice(result.Failed, func(i, j int) bool {
+		a, b := result.Failed[i].Operation, result.Failed[j].Operation
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Path < b.Path
+	})
+	if len(result.Failed) > 0 {
+		logData["num_failed"] = len(result.Failed)
+		return result, NewError(fmt.Errorf("failed to sync %d files: %w", len(result.Failed), result.Failed[0].Err), logData)
+	}
+	return result, nil
+}
+
+// uploadSyncFile uploads the local file of the provided operation to its key, encrypted with the psk if one is provided.
+// The content type is guessed from the file extension.
+func (cli *Client) uploadSyncFile(ctx context.Context, op SyncOperation, psk []byte) error {
+	f, err := os.Open(op.Path)
+	if err != nil {
+		return fmt.Errorf("error opening local file: %w", err)
+	}
+	defer f.Close()
+
+	input := &s3.PutObjectInput{
+		Key:  aws.String(op.Key),
+		Body: f,
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(op.Path)); contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	if psk != nil {
+		_, err = cli.UploadWithPSK(ctx, input, psk)
+	} else {
+		_, err = cli.Upload(ctx, input)
+	}
+	return err
+}
+
+// downloadSyncFile downloads the object of the provided operation to its local path, decrypted with the psk if one is provided,
+// creating its directory if needed, and sets the modification time of the file to the last modified time of the object.
+func (cli *Client) downloadSyncFile(ctx context.Context, op SyncOperation, obj *syncFile, psk []byte) error {
+	if err := os.MkdirAll(filepath.Dir(op.Path), 0o755); err != nil {
+		return fmt.Errorf("error creating local directory: %w", err)
+	}
+
+	var err error
+	if psk != nil {
+		_, err = cli.DownloadToFileWithPSK(ctx, op.Key, op.Path, psk, DownloadToFileOptions{})
+	} else {
+		_, err = cli.DownloadToFile(ctx, op.Key, op.Path, DownloadToFileOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chtimes(op.Path, obj.modTime, obj.modTime); err != nil {
+		return fmt.Errorf("error setting modification time of local file: %w", err)
+	}
+	return nil
+}
+
+// deleteSyncObjects deletes the objects of the provided operations, returning the ones that failed
+func (cli *Client) deleteSyncObjects(ctx context.Context, ops []SyncOperation) []SyncFailure {
+	keys := make([]string, len(ops))
+	byKey := make(map[string]SyncOperation, len(ops))
+	for i, op := range ops {
+		keys[i] = op.Key
+		byKey[op.Key] = op
+	}
+
+	result, _ := cli.DeleteMany(ctx, keys)
+	failed := make([]SyncFailure, len(result.Failed))
+	for i, f := range result.Failed {
+		failed[i] = SyncFailure{Operation: byKey[f.Key], Err: f.Err}
+	}
+	return failed
+}
+
+// deleteSyncFiles deletes the local files of the provided operations, returning the ones that failed
+func deleteSyncFiles(ops []SyncOperation) []SyncFailure {
+	failed := []SyncFailure{}
+	for _, op := range ops {
+		if err := os.Remove(op.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			failed = append(failed, SyncFailure{Operation: op, Err: fmt.Errorf("error deleting local file: %w", err)})
+		}
+	}
+	return failed
+}
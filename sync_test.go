@@ -0,0 +1,343 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const syncPrefix = "site/"
+
+var syncTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// syncBucket is a fake bucket served by the mocks returned by newSyncMocks, holding the content of its objects by key
+type syncBucket struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+}
+
+// newSyncMocks returns an S3 client mock and an uploader mock that list, get, head, upload and delete the objects of the provided bucket,
+// which were all last modified at syncTime and have MD5 ETags
+func newSyncMocks(bucket *syncBucket) (*mock.S3SDKClientMock, *mock.S3SDKUploaderMock) {
+	sdkMock := &mock.S3SDKClientMock{
+		ListObjectsV2Func: func(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			bucket.mutex.Lock()
+			defer bucket.mutex.Unlock()
+			keys := []string{}
+			for key := range bucket.objects {
+				if strings.HasPrefix(key, aws.ToString(in.Prefix)) {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			out := &s3.ListObjectsV2Output{}
+			for _, key := range keys {
+				out.Contents = append(out.Contents, types.Object{
+					Key:          aws.String(key),
+					Size:         aws.Int64(int64(len(bucket.objects[key]))),
+					ETag:         aws.String(md5ETag(bucket.objects[key])),
+					LastModified: aws.Time(syncTime),
+				})
+			}
+			return out, nil
+		},
+		HeadObjectFunc: func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			bucket.mutex.Lock()
+			defer bucket.mutex.Unlock()
+			content := bucket.objects[*in.Key]
+			return &s3.HeadObjectOutput{
+				ContentLength: aws.Int64(int64(len(content))),
+				ETag:          aws.String(md5ETag(content)),
+				LastModified:  aws.Time(syncTime),
+			}, nil
+		},
+		GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			bucket.mutex.Lock()
+			defer bucket.mutex.Unlock()
+			content := bucket.objects[*in.Key]
+			return &s3.GetObjectOutput{
+				Body:          io.NopCloser(bytes.NewReader(content)),
+				ContentLength: aws.Int64(int64(len(content))),
+				ETag:          aws.String(md5ETag(content)),
+			}, nil
+		},
+		DeleteObjectsFunc: func(ctx context.Context, in *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+			bucket.mutex.Lock()
+			defer bucket.mutex.Unlock()
+			for _, obj := range in.Delete.Objects {
+				delete(bucket.objects, *obj.Key)
+			}
+			return &s3.DeleteObjectsOutput{}, nil
+		},
+	}
+	uploaderMock := &mock.S3SDKUploaderMock{
+		UploadFunc: func(ctx context.Context, in *s3.PutObjectInput, options ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+			content, err := io.ReadAll(in.Body)
+			if err != nil {
+				return nil, err
+			}
+			bucket.mutex.Lock()
+			defer bucket.mutex.Unlock()
+			bucket.objects[*in.Key] = content
+			return &manager.UploadOutput{}, nil
+		},
+	}
+	return sdkMock, uploaderMock
+}
+
+// writeSyncFile writes the provided content to the file for the provided relative path under the directory, modified at the provided time
+func writeSyncFile(dir, rel, content string, modTime time.Time) {
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+	So(os.MkdirAll(filepath.Dir(p), 0o755), ShouldBeNil)
+	So(os.WriteFile(p, []byte(content), 0o644), ShouldBeNil)
+	So(os.Chtimes(p, modTime, modTime), ShouldBeNil)
+}
+
+// syncPlan returns the action, key or path, and reason of the provided operations
+func syncPlan(result *dps3.SyncResult, dir string) []string {
+	plan := []string{}
+	for _, op := range result.Operations {
+		target := op.Key
+		if target == "" {
+			target, _ = filepath.Rel(dir, op.Path)
+		}
+		plan = append(plan, string(op.Action)+" "+filepath.ToSlash(target)+" "+op.Reason)
+	}
+	return plan
+}
+
+func TestSyncUp(t *testing.T) {
+	Convey("Given a local directory and a bucket prefix with some of its files", t, func() {
+		ctx := context.Background()
+		dir := t.TempDir()
+		writeSyncFile(dir, "index.html", "<html></html>", syncTime.Add(-time.Hour))
+		writeSyncFile(dir, "data/new.csv", "a,b,c", syncTime)
+		writeSyncFile(dir, "data/resized.csv", "a,b,c,d", syncTime.Add(-time.Hour))
+		writeSyncFile(dir, "data/touched.csv", "1,2,3", syncTime.Add(time.Hour))
+		writeSyncFile(dir, "notes.tmp", "draft", syncTime)
+
+		bucket := &syncBucket{objects: map[string][]byte{
+			syncPrefix + "index.html":       []byte("<html></html>"),
+			syncPrefix + "data/resized.csv": []byte("a,b"),
+			syncPrefix + "data/touched.csv": []byte("1,2,3"),
+			syncPrefix + "old.csv":          []byte("old"),
+			syncPrefix + "keep.tmp":         []byte("kept"),
+			"other/file.csv":                []byte("other"),
+		}}
+		sdkMock, uploaderMock := newSyncMocks(bucket)
+		cli := dps3.InstantiateClient(sdkMock, nil, uploaderMock, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+		opts := dps3.SyncOptions{Delete: true, Exclude: []string{"*.tmp"}, Concurrency: 2}
+
+		Convey("SyncUp uploads new and changed files, and deletes extraneous objects that are not excluded", func() {
+			result, err := cli.SyncUp(ctx, dir, syncPrefix, opts)
+			So(err, ShouldBeNil)
+			So(syncPlan(result, dir), ShouldResemble, []string{
+				"upload site/data/new.csv new",
+				"upload site/data/resized.csv size",
+				"upload site/data/touched.csv modified",
+				"delete site/old.csv extraneous",
+			})
+			So(result.Failed, ShouldBeEmpty)
+			So(result.Operations[0].Path, ShouldEqual, filepath.Join(dir, "data", "new.csv"))
+
+			So(uploaderMock.UploadCalls(), ShouldHaveLength, 3)
+			for _, call := range uploaderMock.UploadCalls() {
+				So(*call.In.ContentType, ShouldStartWith, "text/csv")
+			}
+			So(string(bucket.objects[syncPrefix+"data/new.csv"]), ShouldEqual, "a,b,c")
+			So(string(bucket.objects[syncPrefix+"data/resized.csv"]), ShouldEqual, "a,b,c,d")
+			So(bucket.objects, ShouldNotContainKey, syncPrefix+"old.csv")
+			So(bucket.objects, ShouldContainKey, syncPrefix+"keep.tmp")
+			So(bucket.objects, ShouldContainKey, "other/file.csv")
+		})
+
+		Convey("SyncUp with a prefix without a trailing slash syncs it as a folder, without deleting the objects of sibling prefixes", func() {
+			bucket.objects["site-old/data/new.csv"] = []byte("sibling")
+			result, err := cli.SyncUp(ctx, dir, strings.TrimSuffix(syncPrefix, "/"), opts)
+			So(err, ShouldBeNil)
+			So(syncPlan(result, dir), ShouldResemble, []string{
+				"upload site/data/new.csv new",
+				"upload site/data/resized.csv size",
+				"upload site/data/touched.csv modified",
+				"delete site/old.csv extraneous",
+			})
+			So(string(bucket.objects["site-old/data/new.csv"]), ShouldEqual, "sibling")
+			So(bucket.objects, ShouldNotContainKey, "sitedata/new.csv")
+		})
+
+		Convey("SyncUp does not upload the temporary files of interrupted downloads", func() {
+			writeSyncFile(dir, "data/big.csv.0123456789abcdef.part", "partial", syncTime)
+			result, err := cli.SyncUp(ctx, dir, syncPrefix, opts)
+			So(err, ShouldBeNil)
+			So(result.Operations, ShouldHaveLength, 4)
+			So(bucket.objects, ShouldNotContainKey, syncPrefix+"data/big.csv.0123456789abcdef.part")
+		})
+
+		Convey("SyncUp with DryRun returns the plan without uploading or deleting any object", func() {
+			opts.DryRun = true
+			result, err := cli.SyncUp(ctx, dir, syncPrefix, opts)
+			So(err, ShouldBeNil)
+			So(result.Operations, ShouldHaveLength, 4)
+			So(uploaderMock.UploadCalls(), ShouldBeEmpty)
+			So(sdkMock.DeleteObjectsCalls(), ShouldBeEmpty)
+		})
+
+		Convey("SyncUp without Delete keeps extraneous objects", func() {
+			opts.Delete = false
+			result, err := cli.SyncUp(ctx, dir, syncPrefix, opts)
+			So(err, ShouldBeNil)
+			So(result.Operations, ShouldHaveLength, 3)
+			So(sdkMock.DeleteObjectsCalls(), ShouldBeEmpty)
+			So(bucket.objects, ShouldContainKey, syncPrefix+"old.csv")
+		})
+
+		Convey("SyncUp with Include only syncs the matching files", func() {
+			opts.Include = []string{"data/*.csv"}
+			result, err := cli.SyncUp(ctx, dir, syncPrefix, opts)
+			So(err, ShouldBeNil)
+			So(syncPlan(result, dir), ShouldResemble, []string{
+				"upload site/data/new.csv new",
+				"upload site/data/resized.csv size",
+				"upload site/data/touched.csv modified",
+			})
+		})
+
+		Convey("SyncUp comparing checksums only uploads the files whose content differs from the MD5 ETag", func() {
+			opts.Compare = dps3.SyncCompareChecksum
+			result, err := cli.SyncUp(ctx, dir, syncPrefix, opts)
+			So(err, ShouldBeNil)
+			So(syncPlan(result, dir), ShouldResemble, []string{
+				"upload site/data/new.csv new",
+				"upload site/data/resized.csv checksum",
+				"delete site/old.csv extraneous",
+			})
+		})
+
+		Convey("SyncUp reports the files that fail to upload, after uploading the rest", func() {
+			errUpload := errors.New("upload failed")
+			uploaderMock.UploadFunc = func(ctx context.Context, in *s3.PutObjectInput, options ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+				if strings.HasSuffix(*in.Key, "new.csv") {
+					return nil, errUpload
+				}
+				return &manager.UploadOutput{}, nil
+			}
+			result, err := cli.SyncUp(ctx, dir, syncPrefix, opts)
+			So(errors.Is(err, errUpload), ShouldBeTrue)
+			So(result.Failed, ShouldHaveLength, 1)
+			So(result.Failed[0].Operation.Key, ShouldEqual, syncPrefix+"data/new.csv")
+			So(uploaderMock.UploadCalls(), ShouldHaveLength, 3)
+		})
+
+		Convey("SyncUp fails without sending any request if Delete is requested without a prefix, or a pattern is not valid", func() {
+			_, err := cli.SyncUp(ctx, dir, "", opts)
+			So(err, ShouldNotBeNil)
+			_, err = cli.SyncUp(ctx, dir, syncPrefix, dps3.SyncOptions{Exclude: []string{"[a-"}})
+			So(err, ShouldNotBeNil)
+			So(sdkMock.ListObjectsV2Calls(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestSyncDown(t *testing.T) {
+	Convey("Given a bucket prefix and a local directory with some of its files", t, func() {
+		ctx := context.Background()
+		dir := t.TempDir()
+		writeSyncFile(dir, "index.html", "<html></html>", syncTime)
+		writeSyncFile(dir, "data/stale.csv", "1,2,3", syncTime.Add(-time.Hour))
+		writeSyncFile(dir, "old.csv", "old", syncTime)
+
+		bucket := &syncBucket{objects: map[string][]byte{
+			syncPrefix + "index.html":     []byte("<html></html>"),
+			syncPrefix + "data/new.csv":   []byte("a,b,c"),
+			syncPrefix + "data/stale.csv": []byte("4,5,6"),
+			syncPrefix + "dir/":           {},
+		}}
+		sdkMock, uploaderMock := newSyncMocks(bucket)
+		cli := dps3.InstantiateClient(sdkMock, nil, uploaderMock, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("SyncDown downloads new and changed objects with their modification time, and deletes extraneous files", func() {
+			result, err := cli.SyncDown(ctx, syncPrefix, dir, dps3.SyncOptions{Delete: true})
+			So(err, ShouldBeNil)
+			So(syncPlan(result, dir), ShouldResemble, []string{
+				"download site/data/new.csv new",
+				"download site/data/stale.csv modified",
+				"delete old.csv extraneous",
+			})
+
+			content, err := os.ReadFile(filepath.Join(dir, "data", "new.csv"))
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "a,b,c")
+			info, err := os.Stat(filepath.Join(dir, "data", "stale.csv"))
+			So(err, ShouldBeNil)
+			So(info.ModTime().Equal(syncTime), ShouldBeTrue)
+			_, err = os.Stat(filepath.Join(dir, "old.csv"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+
+			Convey("And a second SyncDown has nothing to do", func() {
+				result, err := cli.SyncDown(ctx, syncPrefix, dir, dps3.SyncOptions{Delete: true})
+				So(err, ShouldBeNil)
+				So(result.Operations, ShouldBeEmpty)
+			})
+		})
+
+		Convey("SyncDown does not delete the temporary files of interrupted downloads", func() {
+			writeSyncFile(dir, "data/big.csv.0123456789abcdef.part", "partial", syncTime)
+			result, err := cli.SyncDown(ctx, syncPrefix, dir, dps3.SyncOptions{Delete: true})
+			So(err, ShouldBeNil)
+			So(syncPlan(result, dir), ShouldNotContain, "delete data/big.csv.0123456789abcdef.part extraneous")
+			_, err = os.Stat(filepath.Join(dir, "data", "big.csv.0123456789abcdef.part"))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("SyncDown to a directory that does not exist creates it", func() {
+			target := filepath.Join(dir, "new", "target")
+			result, err := cli.SyncDown(ctx, syncPrefix, target, dps3.SyncOptions{})
+			So(err, ShouldBeNil)
+			So(result.Operations, ShouldHaveLength, 3)
+			So(listDir(filepath.Join(target, "data")), ShouldResemble, []string{"new.csv", "stale.csv"})
+		})
+
+		Convey("SyncDown with a prefix without a trailing slash syncs it as a folder, ignoring the objects of sibling prefixes", func() {
+			bucket.objects["site-old/old.csv"] = []byte("old")
+			result, err := cli.SyncDown(ctx, strings.TrimSuffix(syncPrefix, "/"), dir, dps3.SyncOptions{Delete: true})
+			So(err, ShouldBeNil)
+			So(syncPlan(result, dir), ShouldResemble, []string{
+				"download site/data/new.csv new",
+				"download site/data/stale.csv modified",
+				"delete old.csv extraneous",
+			})
+			_, err = os.Stat(filepath.Join(dir, "-old"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("SyncDown ignores objects whose key is not a local path relative to the prefix, without reading or writing outside the directory", func() {
+			writeSyncFile(dir, "escape.csv", "outside", syncTime)
+			bucket.objects[syncPrefix+"../escape.csv"] = []byte("escape")
+			result, err := cli.SyncDown(ctx, syncPrefix, filepath.Join(dir, "target"), dps3.SyncOptions{Compare: dps3.SyncCompareChecksum})
+			So(err, ShouldBeNil)
+			So(result.Operations, ShouldHaveLength, 3)
+			for _, call := range sdkMock.HeadObjectCalls() {
+				So(*call.In.Key, ShouldNotEqual, syncPrefix+"../escape.csv")
+			}
+			content, err := os.ReadFile(filepath.Join(dir, "escape.csv"))
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "outside")
+		})
+	})
+}
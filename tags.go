@@ -0,0 +1,166 @@
+// file: tags.go
+//
+// Contains methods to read and write the tags of objects of the bucket configured for the client,
+// and to validate tags against the limits of S3 before they are sent.
+//
+// Requires "s3:GetObjectTagging", "s3:PutObjectTagging" and "s3:DeleteObjectTagging" actions allowed by IAM policy for objects inside the bucket.
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// MaxTags is the maximum number of tags S3 allows for an object
+	MaxTags = 10
+
+	// MaxTagKeyLength and MaxTagValueLength are the maximum lengths, in unicode characters, S3 allows for tag keys and values
+	MaxTagKeyLength   = 128
+	MaxTagValueLength = 256
+
+	// reservedTagPrefix is the prefix of tag keys reserved by AWS, which cannot be set by users
+	reservedTagPrefix = "aws:"
+)
+
+// GetTags returns the tags of the object for the given key (inside the bucket configured for this client)
+func (cli *Client) GetTags(ctx context.Context, key string) (map[string]string, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+	}
+
+	out, err := cli.sdkClient.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, NewError(fmt.Errorf("error getting object tags from s3: %w", err), logData)
+	}
+	return tagMap(out.TagSet), nil
+}
+
+// PutTags replaces the tags of the object for the given key (inside the bucket configured for this client) with the provided ones.
+// An ErrInvalidTags error is returned, without sending any request, if the tags exceed the limits of S3.
+func (cli *Client) PutTags(ctx context.Context, key string, tags map[string]string) error {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"num_tags":    len(tags),
+	}
+
+	if err := ValidateTags(tags); err != nil {
+		return NewInvalidTagsError(err, logData)
+	}
+
+	_, err := cli.sdkClient.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(cli.bucketName),
+		Key:     aws.String(key),
+		Tagging: &types.Tagging{TagSet: tagSet(tags)},
+	})
+	if err != nil {
+		return NewError(fmt.Errorf("error putting object tags to s3: %w", err), logData)
+	}
+	return nil
+}
+
+// DeleteTags removes all the tags of the object for the given key (inside the bucket configured for this client)
+func (cli *Client) DeleteTags(ctx context.Context, key string) error {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+	}
+
+	_, err := cli.sdkClient.DeleteObjectTagging(ctx, &s3.DeleteObjectTaggingInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return NewError(fmt.Errorf("error deleting object tags from s3: %w", err), logData)
+	}
+	return nil
+}
+
+// ValidateTags returns an error if the provided tags exceed the limits of S3: up to MaxTags tags,
+// with non-empty keys of up to MaxTagKeyLength characters that do not start with the reserved "aws:" prefix,
+// and values of up to MaxTagValueLength characters.
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > MaxTags {
+		return fmt.Errorf("%d tags provided, but objects can have up to %d tags", len(tags), MaxTags)
+	}
+	for k, v := range tags {
+		if k == "" {
+			return errors.New("tag keys cannot be empty")
+		}
+		if utf8.RuneCountInString(k) > MaxTagKeyLength {
+			return fmt.Errorf("tag key %q is longer than %d characters", k, MaxTagKeyLength)
+		}
+		if strings.HasPrefix(strings.ToLower(k), reservedTagPrefix) {
+			return fmt.Errorf("tag key %q uses the reserved prefix %q", k, reservedTagPrefix)
+		}
+		if utf8.RuneCountInString(v) > MaxTagValueLength {
+			return fmt.Errorf("value of tag %q is longer than %d characters", k, MaxTagValueLength)
+		}
+	}
+	return nil
+}
+
+// EncodeTags returns the provided tags encoded as URL query parameters,
+// as required by the Tagging field of the inputs of uploads and copies (x-amz-tagging header)
+func EncodeTags(tags map[string]string) string {
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+// decodeTags returns the tags encoded as URL query parameters in the provided string, which cannot contain duplicated keys
+func decodeTags(encoded string) (map[string]string, error) {
+	values, err := url.ParseQuery(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding tags: %w", err)
+	}
+	tags := make(map[string]string, len(values))
+	for k, v := range values {
+		if len(v) > 1 {
+			return nil, fmt.Errorf("tag key %q is duplicated", k)
+		}
+		tags[k] = v[0]
+	}
+	return tags, nil
+}
+
+// tagMap returns the provided tag set as a map of keys to values
+func tagMap(tagSet []types.Tag) map[string]string {
+	tags := make(map[string]string, len(tagSet))
+	for _, tag := range tagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags
+}
+
+// tagSet returns the provided tags as a tag set, sorted by key
+func tagSet(tags map[string]string) []types.Tag {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tagSet := make([]types.Tag, len(keys))
+	for i, k := range keys {
+		tagSet[i] = types.Tag{Key: aws.String(k), Value: aws.String(tags[k])}
+	}
+	return tagSet
+}
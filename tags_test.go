@@ -0,0 +1,190 @@
+package s3_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// shouldBeInvalidTags asserts that the provided error is an ErrInvalidTags error
+func shouldBeInvalidTags(actual any, expected ...any) string {
+	var errInvalid *dps3.ErrInvalidTags
+	if err, ok := actual.(error); ok && errors.As(err, &errInvalid) {
+		return ""
+	}
+	return fmt.Sprintf("expected an ErrInvalidTags error, but got: %v", actual)
+}
+
+func TestTags(t *testing.T) {
+	Convey("Given an S3 client and an object with tags", t, func() {
+		ctx := context.Background()
+		sdkMock := &mock.S3SDKClientMock{
+			GetObjectTaggingFunc: func(ctx context.Context, in *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+				return &s3.GetObjectTaggingOutput{TagSet: []types.Tag{
+					{Key: aws.String("scan-status"), Value: aws.String("pending")},
+					{Key: aws.String("dataset"), Value: aws.String("cpih")},
+				}}, nil
+			},
+			PutObjectTaggingFunc: func(ctx context.Context, in *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+				return &s3.PutObjectTaggingOutput{}, nil
+			},
+			DeleteObjectTaggingFunc: func(ctx context.Context, in *s3.DeleteObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectTaggingOutput, error) {
+				return &s3.DeleteObjectTaggingOutput{}, nil
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("GetTags returns its tags", func() {
+			tags, err := cli.GetTags(ctx, testS3Key)
+			So(err, ShouldBeNil)
+			So(tags, ShouldResemble, map[string]string{"scan-status": "pending", "dataset": "cpih"})
+			So(*sdkMock.GetObjectTaggingCalls()[0].In.Bucket, ShouldEqual, ExistingBucket)
+			So(*sdkMock.GetObjectTaggingCalls()[0].In.Key, ShouldEqual, testS3Key)
+		})
+
+		Convey("GetTags fails if S3 fails to return the tags", func() {
+			errTags := errors.New("access denied")
+			sdkMock.GetObjectTaggingFunc = func(ctx context.Context, in *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+				return nil, errTags
+			}
+			_, err := cli.GetTags(ctx, testS3Key)
+			So(errors.Is(err, errTags), ShouldBeTrue)
+		})
+
+		Convey("PutTags replaces its tags with the provided ones, sorted by key", func() {
+			err := cli.PutTags(ctx, testS3Key, map[string]string{"scan-status": "clean", "dataset": "cpih"})
+			So(err, ShouldBeNil)
+			So(sdkMock.PutObjectTaggingCalls(), ShouldHaveLength, 1)
+			in := sdkMock.PutObjectTaggingCalls()[0].In
+			So(*in.Bucket, ShouldEqual, ExistingBucket)
+			So(*in.Key, ShouldEqual, testS3Key)
+			So(in.Tagging.TagSet, ShouldResemble, []types.Tag{
+				{Key: aws.String("dataset"), Value: aws.String("cpih")},
+				{Key: aws.String("scan-status"), Value: aws.String("clean")},
+			})
+		})
+
+		Convey("PutTags fails with ErrInvalidTags, without sending any request, if the tags exceed the limits of S3", func() {
+			err := cli.PutTags(ctx, testS3Key, map[string]string{"aws:owner": "me"})
+			So(err, shouldBeInvalidTags)
+			So(sdkMock.PutObjectTaggingCalls(), ShouldBeEmpty)
+		})
+
+		Convey("DeleteTags removes its tags", func() {
+			err := cli.DeleteTags(ctx, testS3Key)
+			So(err, ShouldBeNil)
+			So(sdkMock.DeleteObjectTaggingCalls(), ShouldHaveLength, 1)
+			So(*sdkMock.DeleteObjectTaggingCalls()[0].In.Key, ShouldEqual, testS3Key)
+		})
+	})
+}
+
+func TestValidateTags(t *testing.T) {
+	Convey("ValidateTags accepts tags within the limits of S3", t, func() {
+		tags := map[string]string{"dataset": strings.Repeat("é", dps3.MaxTagValueLength), strings.Repeat("k", dps3.MaxTagKeyLength): ""}
+		for i := len(tags); i < dps3.MaxTags; i++ {
+			tags["tag-"+strconv.Itoa(i)] = "value"
+		}
+		So(dps3.ValidateTags(tags), ShouldBeNil)
+		So(dps3.ValidateTags(nil), ShouldBeNil)
+	})
+
+	Convey("ValidateTags rejects tags exceeding the limits of S3", t, func() {
+		tooMany := map[string]string{}
+		for i := 0; i <= dps3.MaxTags; i++ {
+			tooMany["tag-"+strconv.Itoa(i)] = "value"
+		}
+		So(dps3.ValidateTags(tooMany), ShouldNotBeNil)
+		So(dps3.ValidateTags(map[string]string{"": "value"}), ShouldNotBeNil)
+		So(dps3.ValidateTags(map[string]string{strings.Repeat("k", dps3.MaxTagKeyLength+1): "value"}), ShouldNotBeNil)
+		So(dps3.ValidateTags(map[string]string{"key": strings.Repeat("v", dps3.MaxTagValueLength+1)}), ShouldNotBeNil)
+		So(dps3.ValidateTags(map[string]string{"AWS:key": "value"}), ShouldNotBeNil)
+	})
+}
+
+func TestTagOptions(t *testing.T) {
+	Convey("Given an S3 client", t, func() {
+		ctx := context.Background()
+		tooMany := map[string]string{}
+		for i := 0; i <= dps3.MaxTags; i++ {
+			tooMany["tag-"+strconv.Itoa(i)] = "value"
+		}
+
+		Convey("Upload fails with ErrInvalidTags, without uploading, if the encoded tags of the input are not valid", func() {
+			uploaderMock := &mock.S3SDKUploaderMock{}
+			cli := dps3.InstantiateClient(nil, nil, uploaderMock, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			_, err := cli.Upload(ctx, &s3.PutObjectInput{Key: aws.String(testS3Key), Tagging: aws.String(dps3.EncodeTags(tooMany))})
+			So(err, shouldBeInvalidTags)
+			_, err = cli.Upload(ctx, &s3.PutObjectInput{Key: aws.String(testS3Key), Tagging: aws.String("a=1&a=2")})
+			So(err, shouldBeInvalidTags)
+			So(uploaderMock.UploadCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Upload uploads the object with valid encoded tags", func() {
+			uploaderMock := &mock.S3SDKUploaderMock{
+				UploadFunc: func(ctx context.Context, in *s3.PutObjectInput, options ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+					return &manager.UploadOutput{}, nil
+				},
+			}
+			cli := dps3.InstantiateClient(nil, nil, uploaderMock, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			_, err := cli.Upload(ctx, &s3.PutObjectInput{
+				Key:     aws.String(testS3Key),
+				Tagging: aws.String(dps3.EncodeTags(map[string]string{"scan-status": "pending", "dataset": "cpih"})),
+			})
+			So(err, ShouldBeNil)
+			So(*uploaderMock.UploadCalls()[0].In.Tagging, ShouldEqual, "dataset=cpih&scan-status=pending")
+		})
+
+		Convey("UploadPart creates the multipart upload with the tags of the request", func() {
+			sdkMock := &mock.S3SDKClientMock{
+				ListMultipartUploadsFunc: func(ctx context.Context, in *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+					return &s3.ListMultipartUploadsOutput{}, nil
+				},
+				CreateMultipartUploadFunc: func(ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+					return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+				},
+				UploadPartFunc: func(ctx context.Context, in *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+					return &s3.UploadPartOutput{ETag: aws.String(`"part"`)}, nil
+				},
+				ListPartsFunc: func(ctx context.Context, in *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+					return &s3.ListPartsOutput{}, nil
+				},
+			}
+			cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			req := &dps3.UploadPartRequest{UploadKey: testS3Key, ChunkNumber: 1, TotalChunks: 2, Tags: map[string]string{"scan-status": "pending"}}
+			_, err := cli.UploadPart(ctx, req, []byte("chunk"))
+			So(err, ShouldBeNil)
+			So(*sdkMock.CreateMultipartUploadCalls()[0].In.Tagging, ShouldEqual, "scan-status=pending")
+
+			req.Tags = tooMany
+			_, err = cli.UploadPart(ctx, req, []byte("chunk"))
+			So(err, shouldBeInvalidTags)
+			So(sdkMock.UploadPartCalls(), ShouldHaveLength, 1)
+		})
+
+		Convey("Copy and Compose fail with ErrInvalidTags, without sending any request, if the tags are not valid", func() {
+			sdkMock := &mock.S3SDKClientMock{}
+			cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+			_, err := cli.Copy(ctx, testS3Key, copyDstKey, dps3.CopyOptions{Tags: tooMany})
+			So(err, shouldBeInvalidTags)
+			_, err = cli.Compose(ctx, copyDstKey, []string{testS3Key}, dps3.ComposeOptions{Tags: map[string]string{"": "value"}})
+			So(err, shouldBeInvalidTags)
+			So(sdkMock.HeadObjectCalls(), ShouldBeEmpty)
+		})
+	})
+}
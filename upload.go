@@ -15,30 +15,54 @@ import (
 	"fmt"
 
 	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 // PutWithPSK uploads the provided contents to the key in the bucket configured for this client, using the provided PSK.
 // The 'key' parameter refers to the path for the file under the bucket.
+// If the client has a compression codec, the content is compressed before it is encrypted.
 func (cli *Client) PutWithPSK(ctx context.Context, key *string, reader *bytes.Reader, psk []byte) error {
+	_, err := cli.PutWithPSKVersion(ctx, key, reader, psk)
+	return err
+}
+
+// PutWithPSKVersion uploads the provided contents to the key in the bucket configured for this client, using the provided PSK,
+// like PutWithPSK does, and returns the version id of the new object, which is empty if the bucket is not versioned.
+func (cli *Client) PutWithPSKVersion(ctx context.Context, key *string, reader *bytes.Reader, psk []byte) (string, error) {
 	input := &s3.PutObjectInput{
 		Body:   reader,
 		Key:    key,
 		Bucket: &cli.bucketName,
 	}
 
-	if _, err := cli.cryptoClient.PutObjectWithPSK(ctx, input, psk); err != nil {
-		return NewError(fmt.Errorf("error putting object to s3: %w", err), log.Data{
+	if cli.compression != CompressionNone {
+		compressed, err := cli.compressInput(input, psk)
+		if err != nil {
+			return "", NewError(fmt.Errorf("error compressing object: %w", err), log.Data{
+				"bucket_name": cli.bucketName,
+				"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+				"user_psk":    true,
+				"compression": cli.compression,
+			})
+		}
+		defer compressed.Close()
+	}
+
+	output, err := cli.cryptoClient.PutObjectWithPSK(ctx, input, psk)
+	if err != nil {
+		return "", lockError(fmt.Errorf("error putting object to s3: %w", err), log.Data{
 			"bucket_name": cli.bucketName,
 			"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
 			"user_psk":    true,
 		})
 	}
-	return nil
+	return aws.ToString(output.VersionId), nil
 }
 
 // Upload uploads a file to S3 using the AWS Manager, which will automatically split up large objects and upload them concurrently.
+// If the client has a compression codec, the content is compressed before it is uploaded.
 func (cli *Client) Upload(ctx context.Context, input *s3.PutObjectInput, options ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
 	logData, err := cli.ValidateUploadInput(input)
 	if err != nil {
@@ -48,9 +72,21 @@ func (cli *Client) Upload(ctx context.Context, input *s3.PutObjectInput, options
 		)
 	}
 
+	if cli.compression != CompressionNone {
+		logData["compression"] = cli.compression
+		compressed, err := cli.compressInput(input, nil)
+		if err != nil {
+			return nil, NewError(
+				fmt.Errorf("compression error for Upload: %w", err),
+				logData,
+			)
+		}
+		defer compressed.Close()
+	}
+
 	output, err := cli.sdkUploader.Upload(ctx, input, options...)
 	if err != nil {
-		return nil, NewError(
+		return nil, lockError(
 			fmt.Errorf("failed to upload: %w", err),
 			logData,
 		)
@@ -59,6 +95,7 @@ func (cli *Client) Upload(ctx context.Context, input *s3.PutObjectInput, options
 }
 
 // UploadWithPSK uploads a file to S3 using cryptoclient, which allows you to encrypt the file with a given psk.
+// If the client has a compression codec, the content is compressed before it is encrypted.
 func (cli *Client) UploadWithPSK(ctx context.Context, input *s3.PutObjectInput, psk []byte) (*manager.UploadOutput, error) {
 	logData, err := cli.ValidateUploadInput(input)
 	if err != nil {
@@ -74,9 +111,21 @@ func (cli *Client) UploadWithPSK(ctx context.Context, input *s3.PutObjectInput,
 		)
 	}
 
+	if cli.compression != CompressionNone {
+		logData["compression"] = cli.compression
+		compressed, err := cli.compressInput(input, psk)
+		if err != nil {
+			return nil, NewError(
+				fmt.Errorf("compression error for UploadWithPSK: %w", err),
+				logData,
+			)
+		}
+		defer compressed.Close()
+	}
+
 	output, err := cli.cryptoUploader.UploadWithPSK(ctx, input, psk)
 	if err != nil {
-		return nil, NewError(
+		return nil, lockError(
 			fmt.Errorf("failed to upload with psk: %w", err),
 			logData,
 		)
@@ -85,7 +134,9 @@ func (cli *Client) UploadWithPSK(ctx context.Context, input *s3.PutObjectInput,
 }
 
 // ValidateUploadInput checks the upload input and returns an error
-// if there is a bucket override mismatch or s3 key is not provided
+// if there is a bucket override mismatch or s3 key is not provided,
+// if the Object Lock mode and retain until date are not valid or not provided together,
+// or an ErrInvalidTags error if the tags (see EncodeTags) exceed the limits of S3
 func (cli *Client) ValidateUploadInput(input *s3.PutObjectInput) (log.Data, error) {
 	logData := log.Data{
 		"bucket_name": cli.bucketName,
@@ -107,5 +158,22 @@ func (cli *Client) ValidateUploadInput(input *s3.PutObjectInput) (log.Data, erro
 		return logData, errors.New("unexpected bucket name provided in upload input")
 	}
 
+	if input.ObjectLockMode != "" || input.ObjectLockRetainUntilDate != nil {
+		retention := Retention{Mode: input.ObjectLockMode, RetainUntil: aws.ToTime(input.ObjectLockRetainUntilDate)}
+		if err := retention.validate(); err != nil {
+			return logData, err
+		}
+	}
+
+	if input.Tagging != nil {
+		tags, err := decodeTags(*input.Tagging)
+		if err == nil {
+			err = ValidateTags(tags)
+		}
+		if err != nil {
+			return logData, NewInvalidTagsError(err, logData)
+		}
+	}
+
 	return logData, nil
 }
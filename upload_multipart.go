@@ -14,7 +14,9 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/ONSdigital/dp-s3/v3/crypto"
 	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
@@ -26,6 +28,18 @@ type UploadPartRequest struct {
 	ChunkNumber int32
 	TotalChunks int
 	FileName    string
+	// ChunkSize is the size of every chunk except the last one. It is recorded as the encryption chunk size of uploads with a psk.
+	// If it is not provided, the size of the payload is used instead, so it must be provided for the last chunk of uploads with more than one chunk.
+	ChunkSize int
+	// BufferSmallChunks allows chunks smaller than the 5 MB minimum part size of S3, by coalescing consecutive chunks
+	// into parts of at least 5 MB (see upload_multipart_buffered.go). It must be set for every chunk of an upload,
+	// and ChunkSize must be provided for its last chunk and to check if its chunks are uploaded.
+	BufferSmallChunks bool
+	// Tags are set for the object when the multipart upload is created, so they only need to be provided with the first chunk that is uploaded
+	Tags map[string]string
+	// Retention and LegalHold lock the object version with Object Lock. Like Tags, they are set when the multipart upload is created.
+	Retention *Retention
+	LegalHold bool
 }
 
 type MultipartUploadResponse struct {
@@ -48,8 +62,39 @@ func (cli *Client) UploadPartWithPsk(ctx context.Context, req *UploadPartRequest
 		"user_psk":     psk != nil,
 	}
 
+	if err := ValidateTags(req.Tags); err != nil {
+		return MultipartUploadResponse{}, NewInvalidTagsError(err, logData)
+	}
+	if err := validateObjectLock(req.Retention); err != nil {
+		return MultipartUploadResponse{}, NewError(err, logData)
+	}
+
+	if req.BufferSmallChunks {
+		group, buffered, err := bufferedChunkGroup(req, len(payload))
+		if err != nil {
+			return MultipartUploadResponse{}, NewError(err, logData)
+		}
+		if buffered {
+			return cli.uploadBufferedChunk(ctx, req, group, payload, psk, logData)
+		}
+	}
+
+	// Each part is encrypted independently, so the chunk size must be recorded for the object to be decrypted.
+	// The digest of the whole content cannot be known in advance, so only the key check value is recorded.
+	// The metadata is only needed by the call that creates the multipart upload, so the chunk size is only required then.
+	var metadata func() (map[string]string, error)
+	if psk != nil {
+		metadata = func() (map[string]string, error) {
+			chunkSize, err := requestChunkSize(req, len(payload))
+			if err != nil {
+				return nil, fmt.Errorf("%w to create multipart uploads with a psk", err)
+			}
+			return crypto.SetPSKMetadata(nil, psk, chunkSize), nil
+		}
+	}
+
 	// Get UploadID or create it if it does not exist (atomically)
-	uploadID, err := cli.doGetOrCreateMultipartUpload(ctx, req)
+	uploadID, err := cli.doGetOrCreateMultipartUpload(ctx, req, metadata)
 	if err != nil {
 		return MultipartUploadResponse{}, NewError(err, logData)
 	}
@@ -91,7 +136,7 @@ func (cli *Client) UploadPartWithPsk(ctx context.Context, req *UploadPartRequest
 		return MultipartUploadResponse{
 			Etag:             *uploadPartOutput.ETag,
 			AllPartsUploaded: true,
-		}, cli.completeUpload(ctx, uploadID, req, parts)
+		}, cli.completeUpload(ctx, uploadID, req, parts, req.TotalChunks)
 	}
 
 	// Otherwise we don't need to perform any other operation.
@@ -102,9 +147,9 @@ func (cli *Client) UploadPartWithPsk(ctx context.Context, req *UploadPartRequest
 }
 
 // doGetOrCreateMultipartUpload atomically gets the UploadId for the specified bucket
-// and S3 object key, and if it does not find it, it creates it.
+// and S3 object key, and if it does not find it, it creates it with the metadata returned by the provided function, if any.
 // The uploadID is returned. If an error happens, it will be wrapped and returned.
-func (cli *Client) doGetOrCreateMultipartUpload(ctx context.Context, req *UploadPartRequest) (string, error) {
+func (cli *Client) doGetOrCreateMultipartUpload(ctx context.Context, req *UploadPartRequest, metadata func() (map[string]string, error)) (string, error) {
 	cli.mutexUploadID.Lock()
 	defer cli.mutexUploadID.Unlock()
 
@@ -126,19 +171,45 @@ func (cli *Client) doGetOrCreateMultipartUpload(ctx context.Context, req *Upload
 	}
 
 	// If we didn't find the Multipart upload, create it
-	createMultiOutput, err := cli.sdkClient.CreateMultipartUpload(
-		ctx,
-		&s3.CreateMultipartUploadInput{
-			Bucket:      &cli.bucketName,
-			Key:         &req.UploadKey,
-			ContentType: &req.Type,
-		})
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:      &cli.bucketName,
+		Key:         &req.UploadKey,
+		ContentType: &req.Type,
+	}
+	if metadata != nil {
+		if createInput.Metadata, err = metadata(); err != nil {
+			return "", err
+		}
+	}
+	if len(req.Tags) > 0 {
+		createInput.Tagging = aws.String(EncodeTags(req.Tags))
+	}
+	if req.Retention != nil {
+		createInput.ObjectLockMode = req.Retention.Mode
+		createInput.ObjectLockRetainUntilDate = aws.Time(req.Retention.RetainUntil)
+	}
+	if req.LegalHold {
+		createInput.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+	}
+	createMultiOutput, err := cli.sdkClient.CreateMultipartUpload(ctx, createInput)
 	if err != nil {
 		return "", fmt.Errorf("error creating multipart upload: %w", err)
 	}
 	return *createMultiOutput.UploadId, nil
 }
 
+// requestChunkSize returns the chunk size of the request or, if it is not provided, the size of the payload,
+// which is only known to be the chunk size if the payload is not empty and is not the last chunk of an upload with more than one chunk
+func requestChunkSize(req *UploadPartRequest, payloadSize int) (int, error) {
+	if req.ChunkSize != 0 {
+		return req.ChunkSize, nil
+	}
+	if (int(req.ChunkNumber) == req.TotalChunks && req.TotalChunks > 1) || payloadSize == 0 {
+		return 0, errors.New("chunk size must be provided")
+	}
+	return payloadSize, nil
+}
+
 // doUploadPart performs the upload using the sdkClient if no psk is provided, or the cryptoClient if psk is provided
 // The UploadPartOutput is returned. If an error happens, it will be wrapped and returned.
 func (cli *Client) doUploadPart(ctx context.Context, input *s3.UploadPartInput, psk []byte) (*s3.UploadPartOutput, error) {
@@ -205,26 +276,53 @@ func (cli *Client) CheckPartUploaded(ctx context.Context, req *UploadPartRequest
 	// TODO: If there are more than 1000 parts, they will be paginated, so we would need to call ListParts again with the provided Marker until we have all of them.
 	// Reference: https://docs.aws.amazon.com/sdk-for-go/api/service/s3/#S3.ListParts
 
+	// In buffered multipart uploads, chunks are uploaded when the part of their group is, or when they are staged
+	numParts, partNumber, buffered := req.TotalChunks, req.ChunkNumber, false
+	if req.BufferSmallChunks {
+		group, ok, err := bufferedChunkGroup(req, 0)
+		if err != nil {
+			return false, NewError(err, logData)
+		}
+		numParts, partNumber, buffered = group.numParts, group.partNumber, ok
+	}
+
 	parts := output.Parts
-	if len(parts) == req.TotalChunks {
-		if err = cli.completeUpload(ctx, uploadID, req, parts); err != nil {
+	if len(parts) == numParts {
+		if buffered {
+			err = cli.completeBufferedUpload(ctx, uploadID, req, parts, numParts)
+		} else {
+			err = cli.completeUpload(ctx, uploadID, req, parts, numParts)
+		}
+		if err != nil {
 			return false, err
 		}
 		return true, nil
 	}
 
 	for _, part := range parts {
-		if *part.PartNumber == req.ChunkNumber {
+		if *part.PartNumber == partNumber {
 			log.Info(ctx, "chunk already uploaded", logData)
 			return true, nil
 		}
 	}
 
+	if buffered {
+		staged, err := cli.isChunkStaged(ctx, req, uploadID)
+		if err != nil {
+			return false, NewError(err, logData)
+		}
+		if staged {
+			log.Info(ctx, "chunk already staged", logData)
+			return true, nil
+		}
+	}
+
 	return false, NewChunkNumberNotFound(errors.New("chunk number not found"), logData)
 }
 
 // completeUpload if all parts have been uploaded, we complete the multipart upload.
-func (cli *Client) completeUpload(ctx context.Context, uploadID string, req *UploadPartRequest, parts []types.Part) error {
+// If any part except the last one is smaller than 5 MB, an ErrChunkTooSmall error is returned.
+func (cli *Client) completeUpload(ctx context.Context, uploadID string, req *UploadPartRequest, parts []types.Part, numParts int) error {
 	var completedParts []types.CompletedPart
 
 	for _, part := range parts {
@@ -234,7 +332,7 @@ func (cli *Client) completeUpload(ctx context.Context, uploadID string, req *Upl
 		})
 	}
 
-	if len(completedParts) == req.TotalChunks {
+	if len(completedParts) == numParts {
 		_, err := cli.sdkClient.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
 			Key:      &req.UploadKey,
 			UploadId: &uploadID,
@@ -243,6 +341,12 @@ func (cli *Client) completeUpload(ctx context.Context, uploadID string, req *Upl
 			},
 			Bucket: &cli.bucketName,
 		})
+		if errorCode(err) == "EntityTooSmall" {
+			return NewChunkTooSmallError(fmt.Errorf("error completing multipart upload, chunks must be at least 5 MB except the last one: %w", err), log.Data{
+				"bucket_name": cli.bucketName,
+				"upload_key":  req.UploadKey,
+			})
+		}
 		if err != nil {
 			return fmt.Errorf("error completing multipart upload: %w", err)
 		}
@@ -0,0 +1,240 @@
+// file: upload_multipart_buffered.go
+//
+// Contains the buffered mode of multipart uploads, which accepts chunks smaller than the 5 MB minimum part size of S3.
+// Consecutive chunks are grouped so that each group is at least 5 MB. Every chunk of a group is stored as a staging object
+// under MultipartStagingPrefix, and once all of them are stored, they are coalesced into the part of the group.
+// Staging objects are deleted when the multipart upload is completed. Staging objects of abandoned uploads are not,
+// so a lifecycle rule expiring objects under MultipartStagingPrefix is recommended.
+//
+// Requires "s3:PutObject", "s3:GetObject" and "s3:DeleteObject" actions allowed by IAM policy for the staging objects,
+// and "s3:ListBucket" for the bucket, on top of the multipart upload requirements.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// MultipartStagingPrefix is the prefix of the staging objects of buffered multipart uploads
+const MultipartStagingPrefix = ".multipart-staging/"
+
+// chunkGroup represents the consecutive chunks, from first to last, that are coalesced into a part of a buffered multipart upload
+type chunkGroup struct {
+	chunkSize   int
+	partNumber  int32
+	first, last int32
+	numParts    int
+}
+
+// bufferedChunkGroup returns the group of the requested chunk in a buffered multipart upload, or false if the chunks
+// are large enough to be uploaded as parts themselves. The chunk size is the one of the request or, for any chunk except
+// the last one, the size of the payload.
+func bufferedChunkGroup(req *UploadPartRequest, payloadSize int) (chunkGroup, bool, error) {
+	chunkSize, err := requestChunkSize(req, payloadSize)
+	if err != nil {
+		return chunkGroup{}, false, fmt.Errorf("%w for buffered multipart uploads", err)
+	}
+	if chunkSize >= minPartSize {
+		return chunkGroup{}, false, nil
+	}
+
+	chunksPerPart := (minPartSize + chunkSize - 1) / chunkSize
+	partNumber := (int(req.ChunkNumber)-1)/chunksPerPart + 1
+	first := (partNumber-1)*chunksPerPart + 1
+	return chunkGroup{
+		chunkSize:  chunkSize,
+		partNumber: int32(partNumber),
+		first:      int32(first),
+		last:       int32(min(first+chunksPerPart-1, req.TotalChunks)),
+		numParts:   (req.TotalChunks + chunksPerPart - 1) / chunksPerPart,
+	}, true, nil
+}
+
+// stagingPrefix returns the prefix of the staging objects of the provided multipart upload
+func stagingPrefix(uploadKey, uploadID string) string {
+	return MultipartStagingPrefix + uploadKey + "/" + uploadID + "/"
+}
+
+// stagingKey returns the key of the staging object of a chunk of the provided multipart upload.
+// Chunk numbers are zero padded, so that staging objects are listed in order.
+func stagingKey(uploadKey, uploadID string, chunkNumber int32) string {
+	return fmt.Sprintf("%s%010d", stagingPrefix(uploadKey, uploadID), chunkNumber)
+}
+
+// uploadBufferedChunk uploads a chunk of a buffered multipart upload. Chunks that are the only one of their group are uploaded
+// as parts. Otherwise, they are stored as staging objects, encrypted independently with the psk if one is provided,
+// and the part of the group is uploaded with their content once all of them are stored.
+// The multipart upload is completed, and its staging objects deleted, once all the parts are uploaded.
+func (cli *Client) uploadBufferedChunk(ctx context.Context, req *UploadPartRequest, group chunkGroup, payload []byte, psk []byte, logData log.Data) (MultipartUploadResponse, error) {
+	logData["part_number"] = group.partNumber
+
+	var metadata func() (map[string]string, error)
+	if psk != nil {
+		metadata = func() (map[string]string, error) {
+			return crypto.SetPSKMetadata(nil, psk, group.chunkSize), nil
+		}
+	}
+
+	uploadID, err := cli.doGetOrCreateMultipartUpload(ctx, req, metadata)
+	if err != nil {
+		return MultipartUploadResponse{}, NewError(err, logData)
+	}
+
+	var etag *string
+	if group.first == group.last {
+		out, err := cli.doUploadPart(ctx, &s3.UploadPartInput{
+			UploadId:   &uploadID,
+			Bucket:     &cli.bucketName,
+			Key:        &req.UploadKey,
+			Body:       bytes.NewReader(payload),
+			PartNumber: &group.partNumber,
+		}, psk)
+		if err != nil {
+			return MultipartUploadResponse{}, NewError(err, logData)
+		}
+		etag = out.ETag
+	} else {
+		if etag, err = cli.stageChunk(ctx, req, uploadID, payload, psk); err != nil {
+			return MultipartUploadResponse{}, NewError(err, logData)
+		}
+		if err = cli.coalesceChunks(ctx, req, uploadID, group); err != nil {
+			return MultipartUploadResponse{}, NewError(err, logData)
+		}
+	}
+
+	log.Info(ctx, "chunk accepted", logData)
+
+	output, err := cli.sdkClient.ListParts(ctx, &s3.ListPartsInput{
+		Key:      &req.UploadKey,
+		Bucket:   &cli.bucketName,
+		UploadId: &uploadID,
+	})
+	if err != nil {
+		return MultipartUploadResponse{}, NewError(fmt.Errorf("error listing parts: %w", err), logData)
+	}
+
+	if len(output.Parts) == group.numParts {
+		return MultipartUploadResponse{
+			Etag:             aws.ToString(etag),
+			AllPartsUploaded: true,
+		}, cli.completeBufferedUpload(ctx, uploadID, req, output.Parts, group.numParts)
+	}
+
+	return MultipartUploadResponse{
+		Etag:             aws.ToString(etag),
+		AllPartsUploaded: false,
+	}, nil
+}
+
+// stageChunk stores the content of a chunk as a staging object, encrypted with the psk if one is provided, returning its ETag
+func (cli *Client) stageChunk(ctx context.Context, req *UploadPartRequest, uploadID string, payload []byte, psk []byte) (*string, error) {
+	if psk != nil {
+		var err error
+		if payload, err = crypto.EncryptChunk(psk, payload); err != nil {
+			return nil, fmt.Errorf("error encrypting chunk: %w", err)
+		}
+	}
+
+	out, err := cli.sdkClient.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(cli.bucketName),
+		Key:           aws.String(stagingKey(req.UploadKey, uploadID, req.ChunkNumber)),
+		Body:          bytes.NewReader(payload),
+		ContentLength: aws.Int64(int64(len(payload))),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error storing staging chunk: %w", err)
+	}
+	return out.ETag, nil
+}
+
+// coalesceChunks uploads the part of the provided group with the content of the staging objects of its chunks,
+// if all of them are stored. Otherwise, nothing is done, as the part will be uploaded with the last chunk of the group that is stored.
+// Chunks that are stored concurrently may both upload the part, which is harmless as its content is the same.
+func (cli *Client) coalesceChunks(ctx context.Context, req *UploadPartRequest, uploadID string, group chunkGroup) error {
+	prefix := stagingPrefix(req.UploadKey, uploadID)
+	lastKey := stagingKey(req.UploadKey, uploadID, group.last)
+
+	keys := []string{}
+	for obj, err := range cli.List(ctx, prefix, ListOptions{StartAfter: stagingKey(req.UploadKey, uploadID, group.first-1)}) {
+		if err != nil {
+			return fmt.Errorf("error listing staging chunks: %w", err)
+		}
+		if obj.Key > lastKey {
+			break
+		}
+		keys = append(keys, obj.Key)
+	}
+	if len(keys) < int(group.last-group.first+1) {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	for _, key := range keys {
+		out, err := cli.sdkClient.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(cli.bucketName),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return fmt.Errorf("error getting staging chunk: %w", err)
+		}
+		_, err = io.Copy(buf, out.Body)
+		out.Body.Close()
+		if err != nil {
+			return fmt.Errorf("error reading staging chunk: %w", err)
+		}
+	}
+
+	// the content of the chunks is already encrypted, so the part is uploaded as it is
+	_, err := cli.sdkClient.UploadPart(ctx, &s3.UploadPartInput{
+		UploadId:      &uploadID,
+		Bucket:        &cli.bucketName,
+		Key:           &req.UploadKey,
+		Body:          bytes.NewReader(buf.Bytes()),
+		ContentLength: aws.Int64(int64(buf.Len())),
+		PartNumber:    &group.partNumber,
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading coalesced part: %w", err)
+	}
+	return nil
+}
+
+// isChunkStaged returns true if the staging object of the requested chunk exists
+func (cli *Client) isChunkStaged(ctx context.Context, req *UploadPartRequest, uploadID string) (bool, error) {
+	_, err := cli.sdkClient.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(cli.bucketName),
+		Key:    aws.String(stagingKey(req.UploadKey, uploadID, req.ChunkNumber)),
+	})
+	if err != nil {
+		if httpStatusCode(err) == http.StatusNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("error checking staging chunk: %w", err)
+	}
+	return true, nil
+}
+
+// completeBufferedUpload completes a buffered multipart upload, and deletes its staging objects.
+// The object is complete even if the staging objects cannot be deleted, so that failure is only logged.
+func (cli *Client) completeBufferedUpload(ctx context.Context, uploadID string, req *UploadPartRequest, parts []types.Part, numParts int) error {
+	if err := cli.completeUpload(ctx, uploadID, req, parts, numParts); err != nil {
+		return err
+	}
+
+	if _, err := cli.DeletePrefix(ctx, stagingPrefix(req.UploadKey, uploadID), false); err != nil {
+		log.Error(ctx, "failed to delete staging chunks of completed multipart upload", err, log.Data{
+			"bucket_name": cli.bucketName,
+			"upload_key":  req.UploadKey,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const bufferedUploadID = "upload-1"
+
+// bufferedUpload is the state of a multipart upload and its staging objects, stored by a mock returned by newBufferedUploadMock
+type bufferedUpload struct {
+	mutex    sync.Mutex
+	created  bool
+	objects  map[string][]byte
+	parts    map[int32][]byte
+	complete []types.CompletedPart
+}
+
+// newBufferedUploadMock returns an S3 client mock that stores the multipart upload and objects in the provided state
+func newBufferedUploadMock(state *bufferedUpload) *mock.S3SDKClientMock {
+	state.objects = map[string][]byte{}
+	state.parts = map[int32][]byte{}
+	return &mock.S3SDKClientMock{
+		ListMultipartUploadsFunc: func(ctx context.Context, in *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			if !state.created {
+				return &s3.ListMultipartUploadsOutput{}, nil
+			}
+			return createUploads(bufferedUploadID, testS3Key), nil
+		},
+		CreateMultipartUploadFunc: func(ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			state.created = true
+			return &s3.CreateMultipartUploadOutput{UploadId: aws.String(bufferedUploadID)}, nil
+		},
+		PutObjectFunc: func(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			b, _ := io.ReadAll(in.Body)
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			state.objects[*in.Key] = b
+			return &s3.PutObjectOutput{ETag: aws.String(`"staged"`)}, nil
+		},
+		GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(state.objects[*in.Key]))}, nil
+		},
+		HeadObjectFunc: func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			if _, ok := state.objects[*in.Key]; !ok {
+				return nil, newResponseError(http.StatusNotFound, "NotFound")
+			}
+			return &s3.HeadObjectOutput{}, nil
+		},
+		ListObjectsV2Func: func(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			keys := []string{}
+			for key := range state.objects {
+				if strings.HasPrefix(key, *in.Prefix) && key > aws.ToString(in.StartAfter) {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+			for _, key := range keys {
+				out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+			}
+			return out, nil
+		},
+		DeleteObjectsFunc: func(ctx context.Context, in *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			for _, obj := range in.Delete.Objects {
+				delete(state.objects, *obj.Key)
+			}
+			return &s3.DeleteObjectsOutput{}, nil
+		},
+		UploadPartFunc: func(ctx context.Context, in *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+			b, _ := io.ReadAll(in.Body)
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			state.parts[*in.PartNumber] = b
+			return &s3.UploadPartOutput{ETag: aws.String(`"part"`)}, nil
+		},
+		ListPartsFunc: func(ctx context.Context, in *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			out := &s3.ListPartsOutput{}
+			for n := range state.parts {
+				out.Parts = append(out.Parts, types.Part{PartNumber: aws.Int32(n), ETag: aws.String(`"part"`)})
+			}
+			sort.Slice(out.Parts, func(i, j int) bool {
+				return *out.Parts[i].PartNumber < *out.Parts[j].PartNumber
+			})
+			return out, nil
+		},
+		CompleteMultipartUploadFunc: func(ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			state.complete = in.MultipartUpload.Parts
+			return &s3.CompleteMultipartUploadOutput{}, nil
+		},
+	}
+}
+
+func TestUploadPartBuffered(t *testing.T) {
+	Convey("Given an S3 client and a file uploaded in chunks smaller than 5 MB, with buffering", t, func() {
+		ctx := context.Background()
+		state := &bufferedUpload{}
+		sdkMock := newBufferedUploadMock(state)
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		chunkSize := 2 * mb
+		chunks := [][]byte{
+			bytes.Repeat([]byte("1"), chunkSize),
+			bytes.Repeat([]byte("2"), chunkSize),
+			bytes.Repeat([]byte("3"), chunkSize),
+			bytes.Repeat([]byte("4"), 100),
+		}
+		request := func(n int32) *dps3.UploadPartRequest {
+			return &dps3.UploadPartRequest{
+				UploadKey:         testS3Key,
+				Type:              "text/csv",
+				ChunkNumber:       n,
+				TotalChunks:       len(chunks),
+				FileName:          "file.csv",
+				ChunkSize:         chunkSize,
+				BufferSmallChunks: true,
+			}
+		}
+		stagingKey := func(n string) string {
+			return dps3.MultipartStagingPrefix + testS3Key + "/" + bufferedUploadID + "/000000000" + n
+		}
+
+		Convey("Chunks are staged and coalesced into parts of at least 5 MB, in any order, and the upload is completed with them", func() {
+			resp, err := cli.UploadPart(ctx, request(2), chunks[1])
+			So(err, ShouldBeNil)
+			So(resp, ShouldResemble, dps3.MultipartUploadResponse{Etag: `"staged"`, AllPartsUploaded: false})
+			So(state.objects, ShouldContainKey, stagingKey("2"))
+			So(state.parts, ShouldBeEmpty)
+
+			// the last chunk is the only one of the second part, so it is uploaded directly
+			resp, err = cli.UploadPart(ctx, request(4), chunks[3])
+			So(err, ShouldBeNil)
+			So(resp.AllPartsUploaded, ShouldBeFalse)
+			So(state.parts[2], ShouldResemble, chunks[3])
+
+			_, err = cli.UploadPart(ctx, request(1), chunks[0])
+			So(err, ShouldBeNil)
+			So(state.parts, ShouldHaveLength, 1)
+
+			resp, err = cli.UploadPart(ctx, request(3), chunks[2])
+			So(err, ShouldBeNil)
+			So(resp.AllPartsUploaded, ShouldBeTrue)
+			So(state.parts[1], ShouldResemble, bytes.Join(chunks[:3], nil))
+			So(state.complete, ShouldHaveLength, 2)
+			So(sdkMock.CreateMultipartUploadCalls(), ShouldHaveLength, 1)
+
+			// staging objects are deleted once the upload is completed
+			So(state.objects, ShouldBeEmpty)
+		})
+
+		Convey("Chunks are encrypted independently with the psk, so the object can be decrypted in chunks of the recorded size", func() {
+			psk := []byte("0123456789abcdef")
+			chunks = chunks[:3]
+			for i := range chunks {
+				resp, err := cli.UploadPartWithPsk(ctx, request(int32(i+1)), chunks[i], psk)
+				So(err, ShouldBeNil)
+				So(resp.AllPartsUploaded, ShouldEqual, i == 2)
+			}
+
+			So(sdkMock.CreateMultipartUploadCalls()[0].In.Metadata, ShouldResemble, crypto.SetPSKMetadata(nil, psk, chunkSize))
+			part := state.parts[1]
+			So(part, ShouldHaveLength, 3*chunkSize)
+			for i := range chunks {
+				decrypted, err := crypto.DecryptChunk(psk, part[i*chunkSize:(i+1)*chunkSize])
+				So(err, ShouldBeNil)
+				So(decrypted, ShouldResemble, chunks[i])
+			}
+		})
+
+		Convey("CheckPartUploaded reports staged chunks and chunks of uploaded parts as uploaded", func() {
+			_, err := cli.UploadPart(ctx, request(2), chunks[1])
+			So(err, ShouldBeNil)
+			_, err = cli.UploadPart(ctx, request(4), chunks[3])
+			So(err, ShouldBeNil)
+
+			uploaded, err := cli.CheckPartUploaded(ctx, request(2))
+			So(err, ShouldBeNil)
+			So(uploaded, ShouldBeTrue)
+
+			uploaded, err = cli.CheckPartUploaded(ctx, request(4))
+			So(err, ShouldBeNil)
+			So(uploaded, ShouldBeTrue)
+
+			uploaded, err = cli.CheckPartUploaded(ctx, request(1))
+			So(uploaded, ShouldBeFalse)
+			var errNotFound *dps3.ErrChunkNumberNotFound
+			So(errors.As(err, &errNotFound), ShouldBeTrue)
+		})
+
+		Convey("The last chunk fails without being uploaded if the chunk size is not provided", func() {
+			req := request(4)
+			req.ChunkSize = 0
+			_, err := cli.UploadPart(ctx, req, chunks[3])
+			So(err, ShouldNotBeNil)
+			So(sdkMock.ListMultipartUploadsCalls(), ShouldBeEmpty)
+		})
+
+		Convey("Chunks of at least 5 MB are uploaded as parts, without staging", func() {
+			req := request(1)
+			req.ChunkSize = 5 * mb
+			_, err := cli.UploadPart(ctx, req, bytes.Repeat([]byte("1"), 5*mb))
+			So(err, ShouldBeNil)
+			So(sdkMock.PutObjectCalls(), ShouldBeEmpty)
+			So(state.parts, ShouldContainKey, int32(1))
+		})
+	})
+
+	Convey("Given an S3 client and a file uploaded in chunks smaller than 5 MB, without buffering", t, func() {
+		ctx := context.Background()
+		state := &bufferedUpload{}
+		sdkMock := newBufferedUploadMock(state)
+		sdkMock.CompleteMultipartUploadFunc = func(ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+			return nil, newResponseError(http.StatusBadRequest, "EntityTooSmall")
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, ExistingBucket, ExpectedRegion, aws.Config{})
+
+		Convey("Uploading the last chunk fails with ErrChunkTooSmall", func() {
+			for n := int32(1); n <= 2; n++ {
+				_, err := cli.UploadPart(ctx, &dps3.UploadPartRequest{UploadKey: testS3Key, ChunkNumber: n, TotalChunks: 2}, []byte("chunk"))
+				if n == 2 {
+					var errTooSmall *dps3.ErrChunkTooSmall
+					So(errors.As(err, &errTooSmall), ShouldBeTrue)
+				}
+			}
+		})
+	})
+}
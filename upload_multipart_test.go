@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/crypto"
 	"github.com/ONSdigital/dp-s3/v3/mock"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -123,6 +124,7 @@ func TestUploadPart(t *testing.T) {
 			So(len(sdkMock.ListMultipartUploadsCalls()), ShouldEqual, 1)
 			So(*sdkMock.ListMultipartUploadsCalls()[0].In.Bucket, ShouldResemble, bucket)
 			So(len(sdkMock.CreateMultipartUploadCalls()), ShouldEqual, 1)
+			So(sdkMock.CreateMultipartUploadCalls()[0].In.Metadata, ShouldBeNil)
 			So(len(sdkMock.UploadPartCalls()), ShouldEqual, 1)
 			So(*sdkMock.UploadPartCalls()[0].In.UploadId, ShouldEqual, testUploadId)
 			So(*sdkMock.UploadPartCalls()[0].In.Bucket, ShouldEqual, bucket)
@@ -215,10 +217,118 @@ func TestUploadPart(t *testing.T) {
 			So(len(sdkMock.ListMultipartUploadsCalls()), ShouldEqual, 1)
 			So(*sdkMock.ListMultipartUploadsCalls()[0].In.Bucket, ShouldResemble, bucket)
 			So(len(sdkMock.CreateMultipartUploadCalls()), ShouldEqual, 1)
+			So(sdkMock.CreateMultipartUploadCalls()[0].In.Metadata, ShouldResemble, map[string]string{
+				crypto.FormatMetadataKey:    crypto.FormatAESCFB,
+				crypto.ChunkSizeMetadataKey: "9",
+				crypto.KeyCheckMetadataKey:  crypto.KeyCheckValue(psk),
+			})
 			So(len(cryptoMock.UploadPartWithPSKCalls()), ShouldEqual, 1)
 			So(len(sdkMock.ListPartsCalls()), ShouldEqual, 1)
 		})
 
+		Convey("UploadWithPsk records the chunk size provided in the request when creating the multipart upload", func() {
+			psk := []byte("test psk")
+
+			sdkMock := &mock.S3SDKClientMock{
+				ListMultipartUploadsFunc: func(ctx context.Context, in1 *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+					return &s3.ListMultipartUploadsOutput{}, nil
+				},
+				CreateMultipartUploadFunc: func(ctx context.Context, in1 *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+					return &s3.CreateMultipartUploadOutput{UploadId: &testUploadId}, nil
+				},
+				ListPartsFunc: func(ctx context.Context, in1 *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+					return createListPartsOutput(&expectedPart), nil
+				},
+			}
+
+			cryptoMock := &mock.S3CryptoClientMock{
+				UploadPartWithPSKFunc: func(ctx context.Context, in1 *s3.UploadPartInput, in2 []byte) (*s3.UploadPartOutput, error) {
+					return &s3.UploadPartOutput{ETag: aws.String(`"1234567890"`)}, nil
+				},
+			}
+
+			// Instantiate and call UploadWithPsk with the final chunk, which is smaller than the others
+			s3Cli := dps3.InstantiateClient(sdkMock, cryptoMock, nil, nil, bucket, ExpectedRegion, aws.Config{})
+			_, err := s3Cli.UploadPartWithPsk(context.Background(), &dps3.UploadPartRequest{
+				UploadKey:   testKey,
+				Type:        "text/plain",
+				ChunkNumber: 2,
+				TotalChunks: 2,
+				FileName:    "helloworld",
+				ChunkSize:   5242880,
+			}, payload, psk)
+
+			// Validate
+			So(err, ShouldBeNil)
+			So(len(sdkMock.CreateMultipartUploadCalls()), ShouldEqual, 1)
+			So(sdkMock.CreateMultipartUploadCalls()[0].In.Metadata, ShouldResemble, map[string]string{
+				crypto.FormatMetadataKey:    crypto.FormatAESCFB,
+				crypto.ChunkSizeMetadataKey: "5242880",
+				crypto.KeyCheckMetadataKey:  crypto.KeyCheckValue(psk),
+			})
+		})
+
+		Convey("UploadWithPsk fails without creating the multipart upload if the last chunk is received first without the chunk size", func() {
+			psk := []byte("test psk")
+
+			sdkMock := &mock.S3SDKClientMock{
+				ListMultipartUploadsFunc: func(ctx context.Context, in1 *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+					return &s3.ListMultipartUploadsOutput{}, nil
+				},
+			}
+			cryptoMock := &mock.S3CryptoClientMock{}
+
+			// Instantiate and call UploadWithPsk with the final chunk, whose size is not the chunk size
+			s3Cli := dps3.InstantiateClient(sdkMock, cryptoMock, nil, nil, bucket, ExpectedRegion, aws.Config{})
+			_, err := s3Cli.UploadPartWithPsk(context.Background(), &dps3.UploadPartRequest{
+				UploadKey:   testKey,
+				Type:        "text/plain",
+				ChunkNumber: 2,
+				TotalChunks: 2,
+				FileName:    "helloworld",
+			}, payload, psk)
+
+			// Validate
+			So(err, ShouldNotBeNil)
+			So(len(sdkMock.CreateMultipartUploadCalls()), ShouldEqual, 0)
+			So(len(cryptoMock.UploadPartWithPSKCalls()), ShouldEqual, 0)
+		})
+
+		Convey("UploadWithPsk uploads the last chunk without the chunk size if the multipart upload already exists", func() {
+			psk := []byte("test psk")
+
+			sdkMock := &mock.S3SDKClientMock{
+				ListMultipartUploadsFunc: func(ctx context.Context, in1 *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+					return createUploads(testUploadId, testKey), nil
+				},
+				ListPartsFunc: func(ctx context.Context, in1 *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+					return createListPartsOutput(&expectedPart), nil
+				},
+			}
+			cryptoMock := &mock.S3CryptoClientMock{
+				UploadPartWithPSKFunc: func(ctx context.Context, in1 *s3.UploadPartInput, in2 []byte) (*s3.UploadPartOutput, error) {
+					return &s3.UploadPartOutput{ETag: aws.String(`"1234567890"`)}, nil
+				},
+			}
+
+			// Instantiate and call UploadWithPsk with the final chunk, after the previous chunk created the multipart upload
+			s3Cli := dps3.InstantiateClient(sdkMock, cryptoMock, nil, nil, bucket, ExpectedRegion, aws.Config{})
+			response, err := s3Cli.UploadPartWithPsk(context.Background(), &dps3.UploadPartRequest{
+				UploadKey:   testKey,
+				Type:        "text/plain",
+				ChunkNumber: 2,
+				TotalChunks: 2,
+				FileName:    "helloworld",
+			}, payload, psk)
+
+			// Validate
+			So(err, ShouldBeNil)
+			So(response.Etag, ShouldEqual, `"1234567890"`)
+			So(len(sdkMock.CreateMultipartUploadCalls()), ShouldEqual, 0)
+			So(len(cryptoMock.UploadPartWithPSKCalls()), ShouldEqual, 1)
+			So(*cryptoMock.UploadPartWithPSKCalls()[0].In.UploadId, ShouldEqual, testUploadId)
+		})
+
 		Convey("UploadWithPsk performs an upload with the provided PSK - all parts uploaded", func() {
 			psk := []byte("test psk")
 			// Create S3 client with SDK Mock with empty list of Multipart uploads
@@ -0,0 +1,165 @@
+// file: versions.go
+//
+// Contains methods to manage the versions of objects in buckets with versioning enabled,
+// so that an object can be rolled back to a previous version.
+// Individual versions can be read by providing the VersionID read option to Get, GetWithPSK, Head and FileExists.
+//
+// Requires "s3:ListBucketVersions" action allowed by IAM policy for the bucket,
+// and "s3:GetObjectVersion", "s3:PutObject" and "s3:DeleteObjectVersion" for objects inside the bucket.
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ObjectVersion represents a version of an object, or a delete marker, in a versioned bucket
+type ObjectVersion struct {
+	Key            string
+	VersionID      string
+	IsLatest       bool
+	IsDeleteMarker bool
+	LastModified   time.Time
+	ETag           string // empty for delete markers
+	Size           int64  // zero for delete markers
+	StorageClass   string // empty for delete markers
+}
+
+// ListVersions returns the versions and delete markers of the object for the given key (inside the bucket configured for this client),
+// sorted from the most recent to the oldest. Listing stops as soon as it moves past the key, so that other keys with the same prefix are not paged through.
+func (cli *Client) ListVersions(ctx context.Context, key string) ([]ObjectVersion, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+	}
+
+	versions := []ObjectVersion{}
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(cli.bucketName),
+		Prefix: aws.String(key),
+	}
+	for {
+		result, err := cli.sdkClient.ListObjectVersions(ctx, input)
+		if err != nil {
+			return nil, NewError(fmt.Errorf("error listing object versions from s3: %w", err), logData)
+		}
+
+		// the prefix may match other keys, which are ignored
+		for _, v := range result.Versions {
+			if aws.ToString(v.Key) != key {
+				continue
+			}
+			versions = append(versions, ObjectVersion{
+				Key:          key,
+				VersionID:    aws.ToString(v.VersionId),
+				IsLatest:     aws.ToBool(v.IsLatest),
+				LastModified: aws.ToTime(v.LastModified),
+				ETag:         aws.ToString(v.ETag),
+				Size:         aws.ToInt64(v.Size),
+				StorageClass: string(v.StorageClass),
+			})
+		}
+		for _, m := range result.DeleteMarkers {
+			if aws.ToString(m.Key) != key {
+				continue
+			}
+			versions = append(versions, ObjectVersion{
+				Key:            key,
+				VersionID:      aws.ToString(m.VersionId),
+				IsLatest:       aws.ToBool(m.IsLatest),
+				IsDeleteMarker: true,
+				LastModified:   aws.ToTime(m.LastModified),
+			})
+		}
+
+		// keys are listed in order, so the versions of the key are complete once the next page starts at a different key
+		if !aws.ToBool(result.IsTruncated) || aws.ToString(result.NextKeyMarker) != key {
+			break
+		}
+		input.KeyMarker = result.NextKeyMarker
+		input.VersionIdMarker = result.NextVersionIdMarker
+	}
+
+	// S3 returns versions and delete markers separately, so they are merged by modification time
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].IsLatest != versions[j].IsLatest {
+			return versions[i].IsLatest
+		}
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+	return versions, nil
+}
+
+// RestoreVersion makes the provided version of the object for the given key (inside the bucket configured for this client)
+// its latest version, by copying it server-side. The metadata of the version is preserved, so objects written with a psk
+// can still be read with it. The version id of the new latest version is returned.
+// Versions larger than 5 GB cannot be restored with a single copy request.
+func (cli *Client) RestoreVersion(ctx context.Context, key, versionID string) (string, error) {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"version_id":  versionID,
+	}
+
+	if versionID == "" {
+		return "", NewError(errors.New("a version id must be provided to restore a version"), logData)
+	}
+
+	result, err := cli.sdkClient.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(cli.bucketName),
+		Key:        aws.String(key),
+		CopySource: aws.String(copySource(cli.bucketName, key, versionID)),
+	})
+	if err != nil {
+		return "", NewError(fmt.Errorf("error restoring object version in s3: %w", err), logData)
+	}
+	return aws.ToString(result.VersionId), nil
+}
+
+// DeleteVersion permanently deletes the provided version (or delete marker) of the object for the given key
+// (inside the bucket configured for this client). Deleting the latest delete marker of an object restores its previous version.
+// An ErrObjectLocked error is returned if the version is protected by Object Lock retention or legal hold.
+func (cli *Client) DeleteVersion(ctx context.Context, key, versionID string) error {
+	logData := log.Data{
+		"bucket_name": cli.bucketName,
+		"s3_key":      key, // key is the s3 filename with path (it's not a cryptographic key)
+		"version_id":  versionID,
+	}
+
+	// without a version id, S3 would add a delete marker instead of deleting a version
+	if versionID == "" {
+		return NewError(errors.New("a version id must be provided to delete a version"), logData)
+	}
+
+	_, err := cli.sdkClient.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(cli.bucketName),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	})
+	if err != nil {
+		return lockError(fmt.Errorf("error deleting object version from s3: %w", err), logData)
+	}
+	return nil
+}
+
+// copySource returns the URL-encoded value of the x-amz-copy-source header for the provided object and optional version
+func copySource(bucket, key, versionID string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	source := bucket + "/" + strings.Join(segments, "/")
+	if versionID != "" {
+		source += "?versionId=" + url.QueryEscape(versionID)
+	}
+	return source
+}
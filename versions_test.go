@@ -0,0 +1,366 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dps3 "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/dp-s3/v3/crypto"
+	"github.com/ONSdigital/dp-s3/v3/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeVersion is a version, or delete marker, of an object in a versionedBucket
+type fakeVersion struct {
+	id           string
+	content      []byte
+	metadata     map[string]string
+	deleteMarker bool
+	modified     time.Time
+}
+
+// versionedBucket is a fake S3 bucket with versioning enabled, which keeps the versions of each key from the oldest to the newest
+type versionedBucket struct {
+	mutex    sync.Mutex
+	objects  map[string][]*fakeVersion
+	clock    time.Time
+	nextID   int
+	pageSize int
+}
+
+func newVersionedBucket() *versionedBucket {
+	return &versionedBucket{
+		objects:  map[string][]*fakeVersion{},
+		clock:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		pageSize: 2,
+	}
+}
+
+// add adds a new version (or delete marker) to the provided key, returning its id. The caller must hold the mutex.
+func (b *versionedBucket) add(key string, v *fakeVersion) string {
+	b.nextID++
+	b.clock = b.clock.Add(time.Minute)
+	v.id = fmt.Sprintf("v%d", b.nextID)
+	v.modified = b.clock
+	b.objects[key] = append(b.objects[key], v)
+	return v.id
+}
+
+// put adds a new version with the provided content and metadata to the provided key, returning its id
+func (b *versionedBucket) put(key string, content []byte, metadata map[string]string) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.add(key, &fakeVersion{content: content, metadata: metadata})
+}
+
+// find returns the provided version of the provided key, or its latest version if versionID is nil
+func (b *versionedBucket) find(key string, versionID *string) (*fakeVersion, error) {
+	versions := b.objects[key]
+	if versionID == nil {
+		if len(versions) == 0 || versions[len(versions)-1].deleteMarker {
+			return nil, &types.NotFound{}
+		}
+		return versions[len(versions)-1], nil
+	}
+	for _, v := range versions {
+		if v.id == *versionID {
+			if v.deleteMarker {
+				return nil, newResponseError(http.StatusMethodNotAllowed, "MethodNotAllowed")
+			}
+			return v, nil
+		}
+	}
+	return nil, newResponseError(http.StatusNotFound, "NoSuchVersion")
+}
+
+func (b *versionedBucket) mock() *mock.S3SDKClientMock {
+	return &mock.S3SDKClientMock{
+		GetObjectFunc: func(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			v, err := b.find(*in.Key, in.VersionId)
+			if err != nil {
+				return nil, err
+			}
+			return &s3.GetObjectOutput{
+				Body:          io.NopCloser(bytes.NewReader(v.content)),
+				ContentLength: aws.Int64(int64(len(v.content))),
+				VersionId:     &v.id,
+				LastModified:  &v.modified,
+				Metadata:      v.metadata,
+			}, nil
+		},
+		HeadObjectFunc: func(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			v, err := b.find(*in.Key, in.VersionId)
+			if err != nil {
+				return nil, err
+			}
+			return &s3.HeadObjectOutput{
+				ContentLength: aws.Int64(int64(len(v.content))),
+				VersionId:     &v.id,
+				LastModified:  &v.modified,
+				Metadata:      v.metadata,
+			}, nil
+		},
+		CopyObjectFunc: func(ctx context.Context, in *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			source, err := url.Parse(*in.CopySource)
+			if err != nil {
+				return nil, err
+			}
+			_, key, _ := strings.Cut(source.Path, "/")
+			var versionID *string
+			if source.Query().Has("versionId") {
+				versionID = aws.String(source.Query().Get("versionId"))
+			}
+			v, err := b.find(key, versionID)
+			if err != nil {
+				return nil, err
+			}
+			id := b.add(*in.Key, &fakeVersion{content: v.content, metadata: v.metadata})
+			return &s3.CopyObjectOutput{VersionId: &id}, nil
+		},
+		DeleteObjectFunc: func(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			if in.VersionId == nil {
+				id := b.add(*in.Key, &fakeVersion{deleteMarker: true})
+				return &s3.DeleteObjectOutput{VersionId: &id, DeleteMarker: aws.Bool(true)}, nil
+			}
+			versions := b.objects[*in.Key]
+			for i, v := range versions {
+				if v.id == *in.VersionId {
+					b.objects[*in.Key] = append(versions[:i:i], versions[i+1:]...)
+					return &s3.DeleteObjectOutput{VersionId: in.VersionId, DeleteMarker: aws.Bool(v.deleteMarker)}, nil
+				}
+			}
+			return &s3.DeleteObjectOutput{}, nil
+		},
+		ListObjectVersionsFunc: func(ctx context.Context, in *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+
+			// entries are sorted by key, and from the newest to the oldest version of each key, like S3 does
+			type entry struct {
+				key string
+				v   *fakeVersion
+			}
+			keys := []string{}
+			for key := range b.objects {
+				if strings.HasPrefix(key, aws.ToString(in.Prefix)) {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			entries := []entry{}
+			for _, key := range keys {
+				versions := b.objects[key]
+				for i := len(versions) - 1; i >= 0; i-- {
+					entries = append(entries, entry{key: key, v: versions[i]})
+				}
+			}
+
+			start := 0
+			if in.VersionIdMarker != nil {
+				for i, e := range entries {
+					if e.key == *in.KeyMarker && e.v.id == *in.VersionIdMarker {
+						start = i + 1
+					}
+				}
+			}
+			end := min(start+b.pageSize, len(entries))
+
+			out := &s3.ListObjectVersionsOutput{IsTruncated: aws.Bool(end < len(entries))}
+			for _, e := range entries[start:end] {
+				isLatest := e.v == b.objects[e.key][len(b.objects[e.key])-1]
+				if e.v.deleteMarker {
+					out.DeleteMarkers = append(out.DeleteMarkers, types.DeleteMarkerEntry{
+						Key:          aws.String(e.key),
+						VersionId:    aws.String(e.v.id),
+						IsLatest:     aws.Bool(isLatest),
+						LastModified: aws.Time(e.v.modified),
+					})
+					continue
+				}
+				out.Versions = append(out.Versions, types.ObjectVersion{
+					Key:          aws.String(e.key),
+					VersionId:    aws.String(e.v.id),
+					IsLatest:     aws.Bool(isLatest),
+					LastModified: aws.Time(e.v.modified),
+					Size:         aws.Int64(int64(len(e.v.content))),
+				})
+			}
+			if end < len(entries) {
+				out.NextKeyMarker = aws.String(entries[end-1].key)
+				out.NextVersionIdMarker = aws.String(entries[end-1].v.id)
+			}
+			return out, nil
+		},
+	}
+}
+
+func TestVersions(t *testing.T) {
+	Convey("Given a versioned bucket with several versions of an object, a delete marker and another object with the same prefix", t, func() {
+		ctx := context.Background()
+		bucket := newVersionedBucket()
+		v1 := bucket.put(testS3Key, []byte("release 1"), nil)
+		v2 := bucket.put(testS3Key, []byte("release 2"), nil)
+		bucket.put(testS3Key+".bak", []byte("backup"), nil)
+		v3 := bucket.put(testS3Key, []byte("bad release"), nil)
+
+		sdkMock := bucket.mock()
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("ListVersions returns the versions of the object only, from the newest to the oldest, across pages", func() {
+			versions, err := cli.ListVersions(ctx, testS3Key)
+			So(err, ShouldBeNil)
+			So(len(versions), ShouldEqual, 3)
+			So(versions[0].VersionID, ShouldEqual, v3)
+			So(versions[0].IsLatest, ShouldBeTrue)
+			So(versions[0].Size, ShouldEqual, len("bad release"))
+			So(versions[1].VersionID, ShouldEqual, v2)
+			So(versions[2].VersionID, ShouldEqual, v1)
+			So(versions[2].IsLatest, ShouldBeFalse)
+			So(len(sdkMock.ListObjectVersionsCalls()), ShouldBeGreaterThan, 1)
+		})
+
+		Convey("ListVersions stops listing once it moves past the object, without paging through the other keys with the same prefix", func() {
+			for i := range 10 {
+				bucket.put(fmt.Sprintf("%s.bak%d", testS3Key, i), []byte("backup"), nil)
+			}
+			versions, err := cli.ListVersions(ctx, testS3Key)
+			So(err, ShouldBeNil)
+			So(len(versions), ShouldEqual, 3)
+			So(len(sdkMock.ListObjectVersionsCalls()), ShouldEqual, 2)
+		})
+
+		Convey("Get, Head and FileExists read the version selected by the VersionID option", func() {
+			ret, _, err := cli.Get(ctx, testS3Key, dps3.VersionID(v1))
+			So(err, ShouldBeNil)
+			So(string(readBytes(ret)), ShouldEqual, "release 1")
+
+			info, err := cli.Head(ctx, testS3Key, dps3.VersionID(v2))
+			So(err, ShouldBeNil)
+			So(info.VersionID, ShouldEqual, v2)
+
+			exists, err := cli.FileExists(ctx, testS3Key, dps3.VersionID(v1))
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeTrue)
+		})
+
+		Convey("RestoreVersion copies the provided version server-side, making it the latest one", func() {
+			newVersion, err := cli.RestoreVersion(ctx, testS3Key, v2)
+			So(err, ShouldBeNil)
+			So(newVersion, ShouldNotEqual, v2)
+			So(*sdkMock.CopyObjectCalls()[0].In.CopySource, ShouldEqual, testBucket+"/"+testS3Key+"?versionId="+v2)
+
+			ret, _, err := cli.Get(ctx, testS3Key)
+			So(err, ShouldBeNil)
+			So(string(readBytes(ret)), ShouldEqual, "release 2")
+
+			versions, err := cli.ListVersions(ctx, testS3Key)
+			So(err, ShouldBeNil)
+			So(len(versions), ShouldEqual, 4)
+			So(versions[0].VersionID, ShouldEqual, newVersion)
+		})
+
+		Convey("DeleteVersion permanently deletes the provided version, so the previous one becomes the latest", func() {
+			err := cli.DeleteVersion(ctx, testS3Key, v3)
+			So(err, ShouldBeNil)
+
+			ret, _, err := cli.Get(ctx, testS3Key)
+			So(err, ShouldBeNil)
+			So(string(readBytes(ret)), ShouldEqual, "release 2")
+
+			exists, err := cli.FileExists(ctx, testS3Key, dps3.VersionID(v3))
+			So(err, ShouldNotBeNil)
+			So(exists, ShouldBeFalse)
+		})
+
+		Convey("DeleteVersion fails without calling S3 if no version id is provided", func() {
+			err := cli.DeleteVersion(ctx, testS3Key, "")
+			So(err, ShouldNotBeNil)
+			So(len(sdkMock.DeleteObjectCalls()), ShouldEqual, 0)
+		})
+
+		Convey("Given the object has been deleted with a delete marker", func() {
+			out, err := sdkMock.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &testBucket, Key: &testS3Key})
+			So(err, ShouldBeNil)
+			marker := *out.VersionId
+
+			Convey("FileExists returns false and ListVersions returns the delete marker as the latest version", func() {
+				exists, err := cli.FileExists(ctx, testS3Key)
+				So(err, ShouldBeNil)
+				So(exists, ShouldBeFalse)
+
+				versions, err := cli.ListVersions(ctx, testS3Key)
+				So(err, ShouldBeNil)
+				So(len(versions), ShouldEqual, 4)
+				So(versions[0].VersionID, ShouldEqual, marker)
+				So(versions[0].IsDeleteMarker, ShouldBeTrue)
+				So(versions[0].IsLatest, ShouldBeTrue)
+			})
+
+			Convey("Deleting the delete marker with DeleteVersion restores the latest version", func() {
+				So(cli.DeleteVersion(ctx, testS3Key, marker), ShouldBeNil)
+				ret, _, err := cli.Get(ctx, testS3Key)
+				So(err, ShouldBeNil)
+				So(string(readBytes(ret)), ShouldEqual, "bad release")
+			})
+		})
+	})
+
+	Convey("Given a versioned bucket with a previous version of an object encrypted with a psk", t, func() {
+		ctx := context.Background()
+		psk := []byte("0123456789abcdef")
+		encrypted := encryptChunks(psk, testCSV, crypto.DefaultChunkSize)
+
+		bucket := newVersionedBucket()
+		v1 := bucket.put(testS3Key, encrypted, crypto.SetPSKMetadata(nil, psk, crypto.DefaultChunkSize))
+		bucket.put(testS3Key, []byte("overwritten"), nil)
+
+		sdkMock := bucket.mock()
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("RestoreVersion preserves the encryption metadata of the restored version", func() {
+			_, err := cli.RestoreVersion(ctx, testS3Key, v1)
+			So(err, ShouldBeNil)
+
+			info, err := cli.Head(ctx, testS3Key)
+			So(err, ShouldBeNil)
+			So(info.PSKEncrypted, ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an S3 client that fails to copy objects", t, func() {
+		errCopy := errors.New("copy error")
+		sdkMock := &mock.S3SDKClientMock{
+			CopyObjectFunc: func(ctx context.Context, in *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				return nil, errCopy
+			},
+		}
+		cli := dps3.InstantiateClient(sdkMock, nil, nil, nil, testBucket, ExpectedRegion, aws.Config{})
+
+		Convey("RestoreVersion returns the error, with a URL-encoded copy source", func() {
+			_, err := cli.RestoreVersion(context.Background(), "my dir/file+1.csv", "v=1")
+			So(errors.Is(err, errCopy), ShouldBeTrue)
+			So(*sdkMock.CopyObjectCalls()[0].In.CopySource, ShouldEqual, testBucket+"/my%20dir/file+1.csv?versionId=v%3D1")
+		})
+	})
+}